@@ -78,6 +78,10 @@ func newApp() *Application {
 		cacheMgr: cache.NewManager(),
 	}
 	aahApp.cli.Commands = make([]console.Command, 0)
+	_ = aahApp.cacheMgr.AddProvider("inmemory", new(cache.InMemoryProvider))
+	_ = aahApp.cacheMgr.AddProvider("redis", new(cache.RedisProvider))
+	_ = aahApp.cacheMgr.AddProvider("memcache", new(cache.MemcacheProvider))
+	_ = aahApp.cacheMgr.AddProvider("tiered", cache.NewTieredProvider(aahApp.cacheMgr))
 
 	aahApp.he = &HTTPEngine{
 		a:       aahApp,
@@ -682,7 +686,13 @@ func (a *Application) initApp() error {
 			return err
 		}
 	}
-	if err := a.CacheManager().InitProviders(a.Config(), a.Log()); err != nil {
+	if err = a.initCache(); err != nil {
+		return err
+	}
+	if err = a.initLoginThrottleStore(); err != nil {
+		return err
+	}
+	if err = a.initTwoFactorStepStore(); err != nil {
 		return err
 	}
 	a.settings.Initialized = true
@@ -739,6 +749,18 @@ func (a *Application) initConfig() error {
 	return nil
 }
 
+func (a *Application) initCache() error {
+	if a.settings.HotReload {
+		// only the providers with changed config are re-initialized
+		if err := a.CacheManager().ReinitProviders(a.Config(), a.Log()); err != nil {
+			return err
+		}
+	} else if err := a.CacheManager().InitProviders(a.Config(), a.Log()); err != nil {
+		return err
+	}
+	return a.CacheManager().CreateCaches(a.Config())
+}
+
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // Log Definitions
 //______________________________________________________________________________
@@ -932,6 +954,21 @@ func (a *Application) performHotReload() {
 	}
 	a.Log().Info("Security reinitialize succeeded")
 
+	if err = a.initCache(); err != nil {
+		a.Log().Errorf("Unable to reinitialize application caches: %v", err)
+		return
+	}
+	a.Log().Info("Cache reinitialize succeeded")
+
+	if err = a.initLoginThrottleStore(); err != nil {
+		a.Log().Errorf("Unable to reinitialize login throttle store: %v", err)
+		return
+	}
+	if err = a.initTwoFactorStepStore(); err != nil {
+		a.Log().Errorf("Unable to reinitialize two-factor step store: %v", err)
+		return
+	}
+
 	if a.settings.AccessLogEnabled {
 		if err = a.initAccessLog(); err != nil {
 			a.Log().Errorf("Unable to reinitialize application access log: %v", err)
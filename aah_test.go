@@ -18,7 +18,9 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -284,7 +286,14 @@ func TestHotAppReload(t *testing.T) {
 
 	t.Logf("Test Server URL [Hot Reload]: %s", ts.URL)
 
+	products := ts.app.CacheManager().Cache("products")
+	assert.NotNil(t, products)
+	assert.NotNil(t, ts.app.CacheManager().Cache("sessions"))
+
 	ts.app.performHotReload()
+
+	assert.True(t, products == ts.app.CacheManager().Cache("products"), "unchanged cache retained")
+	assert.NotNil(t, ts.app.CacheManager().Cache("sessions"))
 }
 
 func TestLogInitRelativeFilePath(t *testing.T) {
@@ -479,6 +488,7 @@ func (ts *testServer) manualInit() {
 		BindMiddleware,
 		AntiCSRFMiddleware,
 		AuthcAuthzMiddleware,
+		ResponseCacheMiddleware,
 		ActionMiddleware,
 	)
 
@@ -517,6 +527,13 @@ func (ts *testServer) manualInit() {
 		{Name: "BinaryBytes"},
 		{Name: "SendFile"},
 		{Name: "Cookies"},
+		{
+			Name: "CachedPage",
+			Parameters: []*ainsp.Parameter{
+				{Name: "id", Type: reflect.TypeOf((*string)(nil))},
+				{Name: "mode", Type: reflect.TypeOf((*string)(nil))},
+			},
+		},
 	})
 
 	// reset controller namespace and key
@@ -663,6 +680,25 @@ func (s *testSiteController) Cookies() {
 		}).Text("Hey I'm sending cookies for you :)")
 }
 
+var cachedPageRenders int32
+
+func (s *testSiteController) CachedPage(id, mode string) {
+	n := atomic.AddInt32(&cachedPageRenders, 1)
+	s.Reply().Header("X-Rendered", strconv.Itoa(int(n)))
+	switch mode {
+	case "vary":
+		s.Reply().HeaderAppend(ahttp.HeaderVary, ahttp.HeaderAcceptLanguage)
+	case "nostore":
+		s.Reply().Header(ahttp.HeaderCacheControl, "no-store")
+	case "session":
+		s.Session().Set("visited", true)
+	case "html":
+		s.Reply().HTMLf("/testsite/index.html", Data{"Message": fmt.Sprintf("page %s render %d", id, n)})
+		return
+	}
+	s.Reply().Text("page %s render %d", id, n)
+}
+
 func (s *testSiteController) HandleError(err *Error) bool {
 	s.Log().Infof("we got the callbakc from error handler: %s", err)
 	s.Reply().Header("X-Cntrl-ErrorHandler", "true")
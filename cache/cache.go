@@ -4,7 +4,8 @@
 
 // Package cache provides simple and extensible cache feature for aah application.
 //
-// OOTB aah pluggable implementation of cache stores In-memory, Redis and Memcache.
+// OOTB aah pluggable implementation of cache stores In-memory, Redis, Memcache
+// and two-tier (in-memory in front of other store).
 // Refer to documentation for configuration and usage
 // https://docs.aahframework.org/cache.html
 package cache
@@ -12,26 +13,40 @@ package cache
 import (
 	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"aahframe.work/config"
+	"aahframe.work/essentials"
 	"aahframe.work/log"
 )
 
 // Cache errors
 var (
-	ErrEntryExists = errors.New("aah/cache: entry exists")
+	ErrEntryExists   = errors.New("aah/cache: entry exists")
+	ErrEntryTooLarge = errors.New("aah/cache: entry size exceeds the max bytes")
+	ErrNotSupported  = errors.New("aah/cache: operation not supported by the cache")
 )
 
 // EvictionMode for cache entries.
 type EvictionMode uint8
 
 // Eviction modes
+//
+// `EvictionModeLRU` and `EvictionModeLFU` are size bounded modes, entries still
+// expire as per given duration. In-memory cache evicts the least recently or
+// least frequently used entries to stay within `Config.MaxEntries` and
+// `Config.MaxBytes`. Redis and Memcache caches treat them as `EvictionModeTTL`,
+// memory is bounded by the server eviction policy.
 const (
 	EvictionModeTTL EvictionMode = 1 + iota
 	EvictionModeNoTTL
 	EvictionModeSlide
+	EvictionModeLRU
+	EvictionModeLFU
 )
 
 // Cache interface represents operation methods for cache store.
@@ -77,6 +92,26 @@ type Config struct {
 
 	// SweepInterval only applicable to in-memory cache provider.
 	SweepInterval time.Duration
+
+	// MaxEntries and MaxBytes are the size limits for eviction modes
+	// `EvictionModeLRU` and `EvictionModeLFU`, zero means no limit. Atleast
+	// one of them is required. Only applicable to in-memory cache provider.
+	MaxEntries int
+	MaxBytes   int64
+
+	// OnEvict callback is invoked when entry gets evicted from the in-memory
+	// cache due to size limits or expiration. It is not invoked on `Delete`
+	// and `Flush`.
+	OnEvict func(key string, value interface{})
+
+	// StaleWhileRevalidate is the window after the entry duration, in which
+	// `Loader.GetOrLoad` serves the stale entry and reloads it in the background.
+	// Zero means disabled. Not applicable to eviction mode `EvictionModeNoTTL`.
+	StaleWhileRevalidate time.Duration
+
+	// Options holds the provider specific cache options, it is populated from
+	// `cache.stores.<name>.options { ... }` for declarative caches.
+	Options *config.Config
 }
 
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
@@ -89,6 +124,8 @@ func NewManager() *Manager {
 		mu:        sync.RWMutex{},
 		caches:    make(map[string]Cache),
 		providers: make(map[string]Provider),
+		declared:  make(map[string]*Config),
+		snapshots: make(map[string]string),
 	}
 	return m
 }
@@ -102,6 +139,9 @@ type Manager struct {
 	mu        sync.RWMutex
 	caches    map[string]Cache
 	providers map[string]Provider
+	declared  map[string]*Config
+	snapshots map[string]string
+	logger    log.Loggerer
 }
 
 // AddProvider method adds given provider by name. If provider name exists
@@ -120,16 +160,57 @@ func (m *Manager) AddProvider(name string, provider Provider) error {
 // InitProviders method initializes the cache providers.
 func (m *Manager) InitProviders(appCfg *config.Config, logger log.Loggerer) error {
 	m.mu.Lock()
+	m.logger = logger
 	for n, p := range m.providers {
 		if err := p.Init(n, appCfg, logger); err != nil {
 			m.mu.Unlock()
 			return err
 		}
+		m.snapshots[n] = providerSnapshot(appCfg, n)
 	}
 	m.mu.Unlock()
 	return nil
 }
 
+// ReinitProviders method re-initializes the cache providers whose
+// configuration `cache.providers.<name>` is changed since the last
+// initialization, it is used on application config hot-reload. Caches of the
+// re-initialized providers and tiered caches are created again on next
+// `CreateCaches`.
+func (m *Manager) ReinitProviders(appCfg *config.Config, logger log.Loggerer) error {
+	m.mu.Lock()
+	m.logger = logger
+	var changed []string
+	for n, p := range m.providers {
+		snapshot := providerSnapshot(appCfg, n)
+		if m.snapshots[n] == snapshot {
+			continue
+		}
+		if err := p.Init(n, appCfg, logger); err != nil {
+			m.mu.Unlock()
+			return err
+		}
+		m.snapshots[n] = snapshot
+		changed = append(changed, n)
+	}
+
+	var stale []string
+	if len(changed) > 0 {
+		for name, cfg := range m.declared {
+			_, tiered := m.providers[cfg.ProviderName].(*TieredProvider)
+			if tiered || ess.IsSliceContainsString(changed, cfg.ProviderName) {
+				stale = append(stale, name)
+			}
+		}
+	}
+	m.mu.Unlock()
+
+	for _, name := range stale {
+		m.removeCache(name)
+	}
+	return nil
+}
+
 // Provider method returns provider by given name if exists otherwise nil.
 func (m *Manager) Provider(name string) Provider {
 	m.mu.RLock()
@@ -161,6 +242,9 @@ func (m *Manager) CreateCache(cfg *Config) error {
 	if cfg.SweepInterval == 0 {
 		cfg.SweepInterval = 60 * time.Minute
 	}
+	if cfg.SweepInterval < 0 || cfg.StaleWhileRevalidate < 0 {
+		return fmt.Errorf("aah/cache: sweep interval and stale while revalidate cannot be negative for cache '%s'", cfg.Name)
+	}
 
 	p := m.Provider(cfg.ProviderName)
 	if p == nil {
@@ -170,14 +254,84 @@ func (m *Manager) CreateCache(cfg *Config) error {
 	if err != nil {
 		return err
 	}
+	var swr time.Duration
+	if cfg.EvictionMode != EvictionModeNoTTL {
+		swr = cfg.StaleWhileRevalidate
+	}
 
 	m.mu.Lock()
+	if _, ok := c.(StatsReporter); !ok {
+		c = &statsCache{Cache: c, loadThrough: loadThrough{swr: swr, flight: flightGroup{logger: m.logger}}}
+	} else if _, ok := c.(Loader); !ok {
+		c = &loaderCache{reportingCache: c.(reportingCache), loadThrough: loadThrough{swr: swr, flight: flightGroup{logger: m.logger}}}
+	}
 	m.caches[cfg.Name] = c
 	m.mu.Unlock()
 
 	return nil
 }
 
+// CreateCaches method creates the caches declared in the application
+// configuration section `cache.stores { ... }`. Env profile values are
+// honored, profile can override the declared cache or declare new one.
+//
+//	cache {
+//	  stores {
+//	    products {
+//	      provider = "inmemory"
+//	      eviction_mode = "slide"
+//	      sweep_interval = "30m"
+//	      stale_while_revalidate = "1m"
+//	      options { ... }
+//	    }
+//	  }
+//	}
+//
+// On subsequent calls (e.g. application hot-reload) unchanged caches are
+// retained as-is, modified ones are recreated and the ones no longer declared
+// are removed from cache manager.
+func (m *Manager) CreateCaches(appCfg *config.Config) error {
+	cfgs := make(map[string]*Config)
+	for _, name := range keysByPath(appCfg, keyPrefixStores) {
+		cfg, err := parseConfig(appCfg, name)
+		if err != nil {
+			return err
+		}
+		cfgs[name] = cfg
+	}
+
+	m.mu.RLock()
+	var removed []string
+	for name := range m.declared {
+		if _, found := cfgs[name]; !found {
+			removed = append(removed, name)
+		}
+	}
+	m.mu.RUnlock()
+	for _, name := range removed {
+		m.removeCache(name)
+	}
+
+	for name, cfg := range cfgs {
+		m.mu.RLock()
+		existing, found := m.declared[name]
+		m.mu.RUnlock()
+		if found && existing.equal(cfg) {
+			continue
+		}
+		m.removeCache(name)
+		// CreateCache applies defaults, keep the declared values as-is for comparison
+		c := *cfg
+		if err := m.CreateCache(&c); err != nil {
+			return err
+		}
+		m.mu.Lock()
+		m.declared[name] = cfg
+		m.mu.Unlock()
+	}
+	return nil
+}
+
 // Cache method return cache by given name if exists otherwise nil.
 func (m *Manager) Cache(name string) Cache {
 	m.mu.RLock()
@@ -196,3 +350,185 @@ func (m *Manager) CacheNames() []string {
 	m.mu.RUnlock()
 	return names
 }
+
+// Stats method returns the statistics of all the caches in the cache manager
+// sorted by cache name, along with their aggregate.
+func (m *Manager) Stats() *ManagerStats {
+	names := m.CacheNames()
+	sort.Strings(names)
+	ms := &ManagerStats{Caches: make([]Stats, 0, len(names))}
+	for _, name := range names {
+		c, ok := m.Cache(name).(StatsReporter)
+		if !ok {
+			continue
+		}
+		s := c.Stats()
+		ms.Total.Hits += s.Hits
+		ms.Total.Misses += s.Misses
+		ms.Total.Puts += s.Puts
+		ms.Total.Evictions += s.Evictions
+		if s.Entries > 0 {
+			ms.Total.Entries += s.Entries
+		}
+		if s.Bytes > 0 {
+			ms.Total.Bytes += s.Bytes
+		}
+		ms.Caches = append(ms.Caches, s)
+	}
+	return ms
+}
+
+// Close method releases the resources held by the cache providers, such as
+// in-memory sweepers, network connections, etc. Providers have to implement
+// `io.Closer` to participate. aah invokes it on application shutdown.
+func (m *Manager) Close() error {
+	var err error
+	m.mu.RLock()
+	for _, p := range m.providers {
+		if c, ok := p.(io.Closer); ok {
+			if er := c.Close(); er != nil {
+				err = er
+			}
+		}
+	}
+	m.mu.RUnlock()
+	return err
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//______________________________________________________________________________
+
+const (
+	keyPrefixStores    = "cache.stores"
+	keyPrefixProviders = "cache.providers"
+)
+
+// removeCache method removes the cache from manager and releases its
+// resources if cache implements `io.Closer`.
+func (m *Manager) removeCache(name string) {
+	m.mu.Lock()
+	c, found := m.caches[name]
+	delete(m.caches, name)
+	delete(m.declared, name)
+	m.mu.Unlock()
+	if found {
+		if cl, ok := c.(io.Closer); ok {
+			_ = cl.Close()
+		}
+	}
+}
+
+func (c *Config) equal(o *Config) bool {
+	return c.Name == o.Name &&
+		c.ProviderName == o.ProviderName &&
+		c.EvictionMode == o.EvictionMode &&
+		c.SweepInterval == o.SweepInterval &&
+		c.MaxEntries == o.MaxEntries &&
+		c.MaxBytes == o.MaxBytes &&
+		c.StaleWhileRevalidate == o.StaleWhileRevalidate &&
+		c.Options.ToJSON() == o.Options.ToJSON()
+}
+
+func parseConfig(appCfg *config.Config, name string) (*Config, error) {
+	keyPrefix := keyPrefixStores + "." + name
+	cfg := &Config{
+		Name:         name,
+		ProviderName: appCfg.StringDefault(keyPrefix+".provider", ""),
+	}
+	if len(cfg.ProviderName) == 0 {
+		return nil, fmt.Errorf("aah/cache: '%s.provider' is required", keyPrefix)
+	}
+
+	mode := appCfg.StringDefault(keyPrefix+".eviction_mode", "ttl")
+	switch strings.ToLower(mode) {
+	case "ttl":
+		cfg.EvictionMode = EvictionModeTTL
+	case "nottl", "no_ttl":
+		cfg.EvictionMode = EvictionModeNoTTL
+	case "slide":
+		cfg.EvictionMode = EvictionModeSlide
+	case "lru":
+		cfg.EvictionMode = EvictionModeLRU
+	case "lfu":
+		cfg.EvictionMode = EvictionModeLFU
+	default:
+		return nil, fmt.Errorf("aah/cache: '%s.eviction_mode' unsupported value '%s'", keyPrefix, mode)
+	}
+
+	var err error
+	if cfg.SweepInterval, err = parseDuration(appCfg, keyPrefix+".sweep_interval", "0s"); err != nil {
+		return nil, err
+	}
+	if cfg.StaleWhileRevalidate, err = parseDuration(appCfg, keyPrefix+".stale_while_revalidate", "0s"); err != nil {
+		return nil, err
+	}
+	cfg.MaxEntries = appCfg.IntDefault(keyPrefix+".max_entries", 0)
+	if cfg.MaxBytes, err = ess.StrToBytes(appCfg.StringDefault(keyPrefix+".max_bytes", "0b")); err != nil {
+		return nil, fmt.Errorf("aah/cache: '%s.max_bytes' %s", keyPrefix, err)
+	}
+
+	// options are collected key by key to honor the env profile values
+	opts := config.NewEmpty()
+	keyPrefix += ".options"
+	for _, k := range keysByPath(appCfg, keyPrefix) {
+		v, _ := appCfg.Get(keyPrefix + "." + k)
+		switch tv := v.(type) {
+		case string:
+			opts.SetString("options."+k, tv)
+		case bool:
+			opts.SetBool("options."+k, tv)
+		case int64:
+			opts.SetInt64("options."+k, tv)
+		case float64:
+			opts.SetFloat64("options."+k, tv)
+		default:
+			return nil, fmt.Errorf("aah/cache: '%s.%s' only scalar option values are supported", keyPrefix, k)
+		}
+	}
+	var found bool
+	if cfg.Options, found = opts.GetSubConfig("options"); !found {
+		cfg.Options = config.NewEmpty()
+	}
+	return cfg, nil
+}
+
+func parseDuration(appCfg *config.Config, key, defaultValue string) (time.Duration, error) {
+	d, err := time.ParseDuration(appCfg.StringDefault(key, defaultValue))
+	if err != nil {
+		return 0, fmt.Errorf("aah/cache: '%s' %s", key, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("aah/cache: '%s' cannot be negative", key)
+	}
+	return d, nil
+}
+
+// providerSnapshot method returns the comparable form of provider configuration
+// `cache.providers.<name>` including the active env profile values.
+func providerSnapshot(appCfg *config.Config, name string) string {
+	keyPrefix := keyPrefixProviders + "." + name
+	keys := keysByPath(appCfg, keyPrefix)
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		v, _ := appCfg.Get(keyPrefix + "." + k)
+		fmt.Fprintf(&b, "%s=%v;", k, v)
+	}
+	return b.String()
+}
+
+// keysByPath method returns the key names for the given path from app config
+// and the active env profile.
+func keysByPath(appCfg *config.Config, path string) []string {
+	keys := appCfg.KeysByPath(path)
+	if appCfg.IsProfileEnabled() {
+		for _, k := range appCfg.KeysByPath(appCfg.Profile() + "." + path) {
+			if !ess.IsSliceContainsString(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -89,13 +89,17 @@ func TestCacheManagerValidations(t *testing.T) {
 }
 
 type dummyProvider struct {
-	name string
+	name  string
+	inits int
 }
 
 var _ Provider = (*dummyProvider)(nil)
 
 // Init method is not applicable for in-memory cache provider.
-func (p *dummyProvider) Init(name string, _ *config.Config, _ log.Loggerer) error { return nil }
+func (p *dummyProvider) Init(name string, _ *config.Config, _ log.Loggerer) error {
+	p.inits++
+	return nil
+}
 
 // Create method creates new in-memory cache with given options.
 func (p *dummyProvider) Create(cfg *Config) (Cache, error) {
@@ -146,3 +150,148 @@ func (c *dummyCache) Delete(k string) error { return nil }
 func (c *dummyCache) Exists(k string) bool { return false }
 
 func (c *dummyCache) Flush() error { return nil }
+
+func TestCacheManagerCreateCaches(t *testing.T) {
+	appCfg, err := config.ParseString(`
+cache {
+  stores {
+    products {
+      provider = "inmemory"
+      eviction_mode = "slide"
+      sweep_interval = "30m"
+      options {
+        max_entries = 1000
+        label = "products"
+      }
+    }
+    users {
+      provider = "inmemory"
+    }
+    tenants {
+      provider = "inmemory"
+      eviction_mode = "lru"
+      max_entries = 500
+      max_bytes = "10mb"
+    }
+  }
+}
+env {
+  dev {
+    cache {
+      stores {
+        products {
+          sweep_interval = "10m"
+        }
+        orders {
+          provider = "inmemory"
+          eviction_mode = "nottl"
+        }
+      }
+    }
+  }
+}`)
+	assert.Nil(t, err)
+	assert.Nil(t, appCfg.SetProfile("env.dev"))
+
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.CreateCaches(appCfg))
+	cacheNames := mgr.CacheNames()
+	assert.Equal(t, 4, len(cacheNames))
+	assert.True(t, ess.IsSliceContainsString(cacheNames, "orders"))
+
+	tenants := mgr.Cache("tenants").(*inMemoryCache)
+	assert.Equal(t, EvictionModeLRU, tenants.cfg.EvictionMode)
+	assert.Equal(t, 500, tenants.cfg.MaxEntries)
+	assert.Equal(t, int64(10*1024*1024), tenants.cfg.MaxBytes)
+
+	products := mgr.Cache("products").(*inMemoryCache)
+	assert.Equal(t, EvictionModeSlide, products.cfg.EvictionMode)
+	assert.Equal(t, 10*time.Minute, products.cfg.SweepInterval)
+	assert.Equal(t, 1000, products.cfg.Options.IntDefault("max_entries", 0))
+	assert.Equal(t, "products", products.cfg.Options.StringDefault("label", ""))
+	assert.Equal(t, EvictionModeNoTTL, mgr.Cache("orders").(*inMemoryCache).cfg.EvictionMode)
+	assert.Equal(t, EvictionModeTTL, mgr.Cache("users").(*inMemoryCache).cfg.EvictionMode)
+
+	t.Log("Recreate on config change")
+	users := mgr.Cache("users").(*inMemoryCache)
+	appCfg.SetString("env.dev.cache.stores.products.sweep_interval", "5m")
+	appCfg.ClearProfile()
+	assert.Nil(t, mgr.CreateCaches(appCfg))
+	assert.Equal(t, 3, len(mgr.CacheNames()))
+	assert.Nil(t, mgr.Cache("orders"))
+	assert.True(t, users == mgr.Cache("users"), "unchanged cache retained")
+	assert.Equal(t, 30*time.Minute, mgr.Cache("products").(*inMemoryCache).cfg.SweepInterval)
+	_, open := <-products.stopCh
+	assert.False(t, open, "replaced cache closed")
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheManagerReinitProviders(t *testing.T) {
+	mgr := NewManager()
+	provider1 := &dummyProvider{name: "provider1"}
+	provider2 := &dummyProvider{name: "provider2"}
+	assert.Nil(t, mgr.AddProvider("provider1", provider1))
+	assert.Nil(t, mgr.AddProvider("provider2", provider2))
+
+	appCfg, err := config.ParseString("cache {\n providers {\n provider1 {\n address = \"localhost:6379\"\n }\n }\n stores {\n c1 {\n provider = \"provider1\"\n }\n c2 {\n provider = \"provider2\"\n }\n }\n}")
+	assert.Nil(t, err)
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	assert.Nil(t, mgr.CreateCaches(appCfg))
+	c1, c2 := mgr.Cache("c1"), mgr.Cache("c2")
+
+	t.Log("Unchanged provider config")
+	assert.Nil(t, mgr.ReinitProviders(appCfg, l))
+	assert.Nil(t, mgr.CreateCaches(appCfg))
+	assert.Equal(t, 1, provider1.inits)
+	assert.True(t, c1 == mgr.Cache("c1"))
+
+	t.Log("Changed provider config")
+	appCfg.SetString("cache.providers.provider1.address", "localhost:6380")
+	assert.Nil(t, mgr.ReinitProviders(appCfg, l))
+	assert.Nil(t, mgr.CreateCaches(appCfg))
+	assert.Equal(t, 2, provider1.inits)
+	assert.Equal(t, 1, provider2.inits)
+	assert.False(t, c1 == mgr.Cache("c1"), "cache of re-initialized provider is recreated")
+	assert.True(t, c2 == mgr.Cache("c2"))
+
+	t.Log("Provider init error")
+	assert.Nil(t, mgr.AddProvider("provider3", &dummyProvider2{}))
+	appCfg.SetString("cache.providers.provider3.address", "localhost")
+	assert.Equal(t, errors.New("aah/cache: provider provider3 init error"), mgr.ReinitProviders(appCfg, l))
+}
+
+func TestCacheManagerCreateCachesErrors(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	testcases := []struct {
+		cfg string
+		err string
+	}{
+		{cfg: `cache { stores { c1 { eviction_mode = "ttl"; } } }`,
+			err: "aah/cache: 'cache.stores.c1.provider' is required"},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; eviction_mode = "lru1"; } } }`,
+			err: "aah/cache: 'cache.stores.c1.eviction_mode' unsupported value 'lru1'"},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; sweep_interval = "1x"; } } }`,
+			err: `aah/cache: 'cache.stores.c1.sweep_interval' time: unknown unit "x" in duration "1x"`},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; sweep_interval = "-1m"; } } }`,
+			err: "aah/cache: 'cache.stores.c1.sweep_interval' cannot be negative"},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; stale_while_revalidate = "-5s"; } } }`,
+			err: "aah/cache: 'cache.stores.c1.stale_while_revalidate' cannot be negative"},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; options { hosts = ["a", "b"]; } } } }`,
+			err: "aah/cache: 'cache.stores.c1.options.hosts' only scalar option values are supported"},
+		{cfg: `cache { stores { c1 { provider = "inmemory"; eviction_mode = "lfu"; max_bytes = "ten"; } } }`,
+			err: "aah/cache: 'cache.stores.c1.max_bytes' format: invalid input 'ten'"},
+		{cfg: `cache { stores { c1 { provider = "redis"; } } }`,
+			err: "aah/cache: provider 'redis' not exists"},
+	}
+	for _, tc := range testcases {
+		appCfg, err := config.ParseString(tc.cfg)
+		assert.Nil(t, err)
+		err = mgr.CreateCaches(appCfg)
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Equal(t, tc.err, err.Error())
+		}
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"reflect"
+)
+
+// Sizer interface can be implemented by the cache values to report its
+// size in bytes. It is used by the in-memory cache for `Config.MaxBytes`,
+// otherwise size is estimated.
+type Sizer interface {
+	Size() int64
+}
+
+// evictionPolicy interface tracks the cache entries access to choose the
+// entry to evict, when in-memory cache reaches its size limits.
+type evictionPolicy interface {
+	add(e *entry)
+	access(e *entry)
+	remove(e *entry)
+	victim() *entry
+}
+
+func newEvictionPolicy(mode EvictionMode) evictionPolicy {
+	switch mode {
+	case EvictionModeLRU:
+		return &lruPolicy{l: list.New()}
+	case EvictionModeLFU:
+		return &lfuPolicy{}
+	}
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Least Recently Used
+//______________________________________________________________________________
+
+// lruPolicy keeps the entries in access order, most recent at front.
+type lruPolicy struct {
+	l *list.List
+}
+
+func (p *lruPolicy) add(e *entry) {
+	e.elem = p.l.PushFront(e)
+}
+
+func (p *lruPolicy) access(e *entry) {
+	p.l.MoveToFront(e.elem)
+}
+
+func (p *lruPolicy) remove(e *entry) {
+	p.l.Remove(e.elem)
+}
+
+func (p *lruPolicy) victim() *entry {
+	if el := p.l.Back(); el != nil {
+		return el.Value.(*entry)
+	}
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Least Frequently Used
+//______________________________________________________________________________
+
+// lfuPolicy keeps the entries in min-heap by access count, ties are broken
+// by least recent access.
+type lfuPolicy struct {
+	h   lfuHeap
+	seq uint64
+}
+
+func (p *lfuPolicy) add(e *entry) {
+	p.seq++
+	e.hits, e.seq = 1, p.seq
+	heap.Push(&p.h, e)
+}
+
+func (p *lfuPolicy) access(e *entry) {
+	p.seq++
+	e.hits++
+	e.seq = p.seq
+	heap.Fix(&p.h, e.idx)
+}
+
+func (p *lfuPolicy) remove(e *entry) {
+	heap.Remove(&p.h, e.idx)
+}
+
+func (p *lfuPolicy) victim() *entry {
+	if len(p.h) > 0 {
+		return p.h[0]
+	}
+	return nil
+}
+
+type lfuHeap []*entry
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].hits == h[j].hits {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].hits < h[j].hits
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].idx, h[j].idx = i, j
+}
+
+func (h *lfuHeap) Push(x interface{}) {
+	e := x.(*entry)
+	e.idx = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *lfuHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Size estimation
+//______________________________________________________________________________
+
+// sizeOf method returns the approximate memory size of the value in bytes.
+func sizeOf(v interface{}) int64 {
+	if s, ok := v.(Sizer); ok {
+		return s.Size()
+	}
+	switch tv := v.(type) {
+	case string:
+		return int64(len(tv))
+	case []byte:
+		return int64(len(tv))
+	}
+	return sizeOfValue(reflect.ValueOf(v), 0)
+}
+
+func sizeOfValue(rv reflect.Value, depth int) int64 {
+	if !rv.IsValid() {
+		return 0
+	}
+	if depth > 8 {
+		return int64(rv.Type().Size())
+	}
+	switch rv.Kind() {
+	case reflect.String:
+		return int64(rv.Type().Size()) + int64(rv.Len())
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return int64(rv.Type().Size())
+		}
+		return int64(rv.Type().Size()) + sizeOfValue(rv.Elem(), depth+1)
+	case reflect.Slice, reflect.Array:
+		var size int64
+		if rv.Kind() == reflect.Slice {
+			size = int64(rv.Type().Size())
+		}
+		for i := 0; i < rv.Len(); i++ {
+			size += sizeOfValue(rv.Index(i), depth+1)
+		}
+		return size
+	case reflect.Map:
+		size := int64(rv.Type().Size())
+		iter := rv.MapRange()
+		for iter.Next() {
+			size += sizeOfValue(iter.Key(), depth+1) + sizeOfValue(iter.Value(), depth+1)
+		}
+		return size
+	case reflect.Struct:
+		var size int64
+		for i := 0; i < rv.NumField(); i++ {
+			size += sizeOfValue(rv.Field(i), depth+1)
+		}
+		return size
+	}
+	return int64(rv.Type().Size())
+}
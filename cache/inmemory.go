@@ -0,0 +1,476 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"container/list"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/log"
+)
+
+var _ Provider = (*InMemoryProvider)(nil)
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// In-Memory Provider
+//______________________________________________________________________________
+
+// InMemoryProvider struct represents the aah in-memory cache provider.
+// It is registered with aah application cache manager in the name of `inmemory`.
+type InMemoryProvider struct {
+	name   string
+	logger log.Loggerer
+	mu     sync.Mutex
+	caches []*inMemoryCache
+}
+
+// Init method is not applicable for in-memory cache provider, it just
+// keeps the provider name and logger.
+func (p *InMemoryProvider) Init(name string, _ *config.Config, logger log.Loggerer) error {
+	p.name = name
+	p.logger = logger
+	return nil
+}
+
+// Create method creates new in-memory cache with given options. Sweeper is
+// started for all eviction modes except `EvictionModeNoTTL`.
+func (p *InMemoryProvider) Create(cfg *Config) (Cache, error) {
+	if (cfg.EvictionMode == EvictionModeLRU || cfg.EvictionMode == EvictionModeLFU) &&
+		cfg.MaxEntries <= 0 && cfg.MaxBytes <= 0 {
+		return nil, fmt.Errorf("aah/cache: max entries or max bytes is required for cache '%s'", cfg.Name)
+	}
+	c := &inMemoryCache{
+		p:      p,
+		cfg:    cfg,
+		e:      make(map[string]*entry),
+		tags:   make(map[string]map[string]struct{}),
+		policy: newEvictionPolicy(cfg.EvictionMode),
+		stopCh: make(chan struct{}),
+		logger: p.logger,
+		flight: flightGroup{logger: p.logger},
+	}
+	if cfg.EvictionMode != EvictionModeNoTTL {
+		go c.sweeper()
+	}
+
+	p.mu.Lock()
+	p.caches = append(p.caches, c)
+	p.mu.Unlock()
+	return c, nil
+}
+
+// Close method stops the sweeper of all the caches created by this provider.
+// aah invokes it on application shutdown via `Manager.Close`.
+func (p *InMemoryProvider) Close() error {
+	p.mu.Lock()
+	for _, c := range p.caches {
+		c.stop()
+	}
+	p.caches = nil
+	p.mu.Unlock()
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// In-Memory Cache
+//______________________________________________________________________________
+
+var (
+	_ Cache         = (*inMemoryCache)(nil)
+	_ StatsReporter = (*inMemoryCache)(nil)
+	_ Loader        = (*inMemoryCache)(nil)
+	_ Invalidator   = (*inMemoryCache)(nil)
+)
+
+type inMemoryCache struct {
+	counters
+	p        *InMemoryProvider
+	cfg      *Config
+	mu       sync.RWMutex
+	e        map[string]*entry
+	tags     map[string]map[string]struct{}
+	size     int64
+	policy   evictionPolicy
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	logger   log.Loggerer
+	flight   flightGroup
+}
+
+// entry struct holds the cache value and its expiration details.
+type entry struct {
+	k    string
+	v    interface{}
+	d    time.Duration
+	x    int64 // expires at in unix nano, zero means never expires
+	s    int64 // stale after in unix nano for stale-while-revalidate, zero means not applicable
+	size int64
+	tags []string
+
+	// eviction policy bookkeeping
+	elem *list.Element
+	idx  int
+	hits uint64
+	seq  uint64
+}
+
+func (e *entry) isExpired(now int64) bool {
+	return e.x > 0 && now > e.x
+}
+
+func (e *entry) isStale(now int64) bool {
+	return e.s > 0 && now > e.s
+}
+
+// Name method returns the cache store name.
+func (c *inMemoryCache) Name() string {
+	return c.cfg.Name
+}
+
+// Get method returns the cached entry for given key if it exists otherwise nil.
+// For eviction mode `EvictionModeSlide` expiration gets extended on each access.
+func (c *inMemoryCache) Get(k string) interface{} {
+	if c.cfg.EvictionMode == EvictionModeTTL || c.cfg.EvictionMode == EvictionModeNoTTL {
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		e := c.get(k, time.Now().UnixNano())
+		c.lookup(e != nil)
+		if e != nil {
+			return e.v
+		}
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.get(k, time.Now().UnixNano())
+	c.lookup(e != nil)
+	if e != nil {
+		c.touch(e)
+		return e.v
+	}
+	return nil
+}
+
+// GetOrPut method returns the cached entry for the given key if it exists otherwise
+// it puts the new entry into cache store and returns the value.
+func (c *inMemoryCache) GetOrPut(k string, v interface{}, d time.Duration) (interface{}, error) {
+	c.mu.Lock()
+	if e := c.get(k, time.Now().UnixNano()); e != nil {
+		c.touch(e)
+		c.mu.Unlock()
+		c.hit()
+		return e.v, nil
+	}
+	evicted, err := c.set(c.newEntry(k, v, d))
+	c.mu.Unlock()
+	c.miss()
+	c.notify(evicted)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// GetOrLoad method returns the cached entry for the given key if it exists
+// otherwise it invokes the loader function once for concurrent callers, puts
+// the result into cache store and returns it. Stale entry within
+// `Config.StaleWhileRevalidate` window is returned as-is and reloaded in the
+// background.
+func (c *inMemoryCache) GetOrLoad(k string, d time.Duration, fn LoaderFunc) (interface{}, error) {
+	now := time.Now().UnixNano()
+	c.mu.Lock()
+	if e := c.get(k, now); e != nil {
+		c.touch(e)
+		v, stale := e.v, e.isStale(now)
+		c.mu.Unlock()
+		c.hit()
+		if stale {
+			c.flight.doAsync(k, func() (interface{}, error) { return c.load(k, d, fn) })
+		}
+		return v, nil
+	}
+	c.mu.Unlock()
+	c.miss()
+	return c.flight.do(k, func() (interface{}, error) { return c.load(k, d, fn) })
+}
+
+// Put method adds the cache entry with specified expiration. Returns error
+// if cache entry exists.
+func (c *inMemoryCache) Put(k string, v interface{}, d time.Duration) error {
+	c.mu.Lock()
+	if c.get(k, time.Now().UnixNano()) != nil {
+		c.mu.Unlock()
+		return ErrEntryExists
+	}
+	evicted, err := c.set(c.newEntry(k, v, d))
+	c.mu.Unlock()
+	c.notify(evicted)
+	return err
+}
+
+// PutWithTags method adds the cache entry with specified expiration and
+// associates it with given tags. Returns error if cache entry exists.
+func (c *inMemoryCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	c.mu.Lock()
+	if c.get(k, time.Now().UnixNano()) != nil {
+		c.mu.Unlock()
+		return ErrEntryExists
+	}
+	e := c.newEntry(k, v, d)
+	e.tags = tags
+	evicted, err := c.set(e)
+	c.mu.Unlock()
+	c.notify(evicted)
+	return err
+}
+
+// Delete method deletes the cache entry from cache store.
+func (c *inMemoryCache) Delete(k string) error {
+	c.mu.Lock()
+	if e, found := c.e[k]; found {
+		c.remove(e)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// DeleteByTag method deletes all the cache entries associated with given tag.
+func (c *inMemoryCache) DeleteByTag(tag string) error {
+	c.mu.Lock()
+	for k := range c.tags[tag] {
+		c.remove(c.e[k])
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// DeleteByPrefix method deletes all the cache entries whose key starts
+// with given prefix.
+func (c *inMemoryCache) DeleteByPrefix(prefix string) error {
+	c.mu.Lock()
+	for k, e := range c.e {
+		if strings.HasPrefix(k, prefix) {
+			c.remove(e)
+		}
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// Exists method checks given key exists in cache store and its not expried.
+func (c *inMemoryCache) Exists(k string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.get(k, time.Now().UnixNano()) != nil
+}
+
+// Flush methods flushes(deletes) all the cache entries from cache.
+func (c *inMemoryCache) Flush() error {
+	c.mu.Lock()
+	c.e = make(map[string]*entry)
+	c.tags = make(map[string]map[string]struct{})
+	c.size = 0
+	c.policy = newEvictionPolicy(c.cfg.EvictionMode)
+	c.mu.Unlock()
+	return nil
+}
+
+// Stats method returns the cache statistics. Bytes is estimated on each call
+// unless the cache has `Config.MaxBytes` limit.
+func (c *inMemoryCache) Stats() Stats {
+	s := c.snapshot(c.cfg.Name)
+	c.mu.RLock()
+	s.Entries, s.Bytes = int64(len(c.e)), c.size
+	if c.cfg.MaxBytes <= 0 {
+		s.Bytes = 0
+		for k, e := range c.e {
+			s.Bytes += int64(len(k)) + sizeOf(e.v)
+		}
+	}
+	c.mu.RUnlock()
+	return s
+}
+
+// Close method stops the cache sweeper and removes the cache from provider,
+// so its entries are released. Cache manager invokes it when the cache gets
+// removed or recreated on configuration reload.
+func (c *inMemoryCache) Close() error {
+	c.stop()
+	c.p.mu.Lock()
+	for i, pc := range c.p.caches {
+		if pc == c {
+			c.p.caches = append(c.p.caches[:i], c.p.caches[i+1:]...)
+			break
+		}
+	}
+	c.p.mu.Unlock()
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// In-Memory Cache unexported methods
+//______________________________________________________________________________
+
+// get method returns the entry if exists and not expired otherwise nil.
+// Caller must hold the lock.
+func (c *inMemoryCache) get(k string, now int64) *entry {
+	e, found := c.e[k]
+	if !found || e.isExpired(now) {
+		return nil
+	}
+	return e
+}
+
+func (c *inMemoryCache) newEntry(k string, v interface{}, d time.Duration) *entry {
+	e := &entry{k: k, v: v, d: d}
+	if c.cfg.EvictionMode != EvictionModeNoTTL && d > 0 {
+		e.x = time.Now().Add(d).UnixNano()
+	}
+	if c.cfg.MaxBytes > 0 {
+		e.size = int64(len(k)) + sizeOf(v)
+	}
+	return e
+}
+
+// load method invokes the loader function and sets its result into cache,
+// replacing the existing entry if any.
+func (c *inMemoryCache) load(k string, d time.Duration, fn LoaderFunc) (interface{}, error) {
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	e := c.newEntry(k, v, d)
+	if e.x > 0 && c.cfg.StaleWhileRevalidate > 0 {
+		e.s = e.x
+		e.x += int64(c.cfg.StaleWhileRevalidate)
+	}
+	c.mu.Lock()
+	evicted, err := c.set(e)
+	c.mu.Unlock()
+	c.notify(evicted)
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// set method adds the entry, replacing expired one if any. It evicts the
+// entries beforehand to stay within size limits, so that new entry does not
+// become the victim. Caller must hold the lock.
+func (c *inMemoryCache) set(e *entry) ([]*entry, error) {
+	if c.cfg.MaxBytes > 0 && e.size > c.cfg.MaxBytes {
+		return nil, ErrEntryTooLarge
+	}
+	if old, found := c.e[e.k]; found {
+		c.remove(old)
+	}
+
+	var evicted []*entry
+	if c.policy != nil {
+		for len(c.e) > 0 && ((c.cfg.MaxEntries > 0 && len(c.e) >= c.cfg.MaxEntries) ||
+			(c.cfg.MaxBytes > 0 && c.size+e.size > c.cfg.MaxBytes)) {
+			v := c.policy.victim()
+			c.remove(v)
+			evicted = append(evicted, v)
+		}
+		c.policy.add(e)
+	}
+	c.e[e.k] = e
+	c.size += e.size
+	for _, t := range e.tags {
+		keys, found := c.tags[t]
+		if !found {
+			keys = make(map[string]struct{})
+			c.tags[t] = keys
+		}
+		keys[e.k] = struct{}{}
+	}
+	c.put()
+	return evicted, nil
+}
+
+// remove method removes the entry from cache. Caller must hold the lock.
+func (c *inMemoryCache) remove(e *entry) {
+	delete(c.e, e.k)
+	c.size -= e.size
+	for _, t := range e.tags {
+		if keys, found := c.tags[t]; found {
+			delete(keys, e.k)
+			if len(keys) == 0 {
+				delete(c.tags, t)
+			}
+		}
+	}
+	if c.policy != nil {
+		c.policy.remove(e)
+	}
+}
+
+// touch method records the entry access. Caller must hold the lock.
+func (c *inMemoryCache) touch(e *entry) {
+	switch c.cfg.EvictionMode {
+	case EvictionModeSlide:
+		if e.d > 0 {
+			e.x = time.Now().Add(e.d).UnixNano()
+			if e.s > 0 {
+				e.s = e.x
+				e.x += int64(c.cfg.StaleWhileRevalidate)
+			}
+		}
+	case EvictionModeLRU, EvictionModeLFU:
+		c.policy.access(e)
+	}
+}
+
+// notify method records the puts and evictions, also invokes the eviction
+// callback. It must be called without holding the lock.
+func (c *inMemoryCache) notify(evicted []*entry) {
+	c.evicted(len(evicted))
+	if c.cfg.OnEvict == nil {
+		return
+	}
+	for _, e := range evicted {
+		c.cfg.OnEvict(e.k, e.v)
+	}
+}
+
+func (c *inMemoryCache) sweeper() {
+	ticker := time.NewTicker(c.cfg.SweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.sweep()
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+func (c *inMemoryCache) sweep() {
+	now := time.Now().UnixNano()
+	var expired []*entry
+	c.mu.Lock()
+	for _, e := range c.e {
+		if e.isExpired(now) {
+			c.remove(e)
+			expired = append(expired, e)
+		}
+	}
+	c.mu.Unlock()
+	c.notify(expired)
+	if c.logger != nil && len(expired) > 0 {
+		c.logger.Debugf("cache: %d expired entries swept from '%s'", len(expired), c.cfg.Name)
+	}
+}
+
+func (c *inMemoryCache) stop() {
+	c.stopOnce.Do(func() { close(c.stopCh) })
+}
@@ -0,0 +1,264 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInMemoryCacheTTL(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory"})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	assert.Equal(t, "cache1", c.Name())
+
+	assert.Nil(t, c.Put("key1", "value1", 50*time.Millisecond))
+	assert.Equal(t, ErrEntryExists, c.Put("key1", "value1", 50*time.Millisecond))
+	assert.Equal(t, "value1", c.Get("key1"))
+	assert.True(t, c.Exists("key1"))
+
+	v, err := c.GetOrPut("key1", "value2", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+
+	v, err = c.GetOrPut("key2", "value2", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, "value2", v)
+
+	time.Sleep(80 * time.Millisecond)
+	assert.Nil(t, c.Get("key1"))
+	assert.False(t, c.Exists("key1"))
+	assert.Equal(t, "value2", c.Get("key2"), "zero duration never expires")
+
+	assert.Nil(t, c.Put("key1", "value1 again", time.Minute))
+	assert.Nil(t, c.Delete("key1"))
+	assert.Nil(t, c.Get("key1"))
+
+	assert.Nil(t, c.Flush())
+	assert.False(t, c.Exists("key2"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheNoTTL(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeNoTTL})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	assert.Nil(t, c.Put("key1", 101, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	assert.Equal(t, 101, c.Get("key1"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheSlide(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeSlide})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	assert.Nil(t, c.Put("key1", "value1", 60*time.Millisecond))
+	for i := 0; i < 4; i++ {
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, "value1", c.Get("key1"), "access extends the expiration")
+	}
+	time.Sleep(90 * time.Millisecond)
+	assert.Nil(t, c.Get("key1"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheSweeper(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", SweepInterval: 20 * time.Millisecond})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1").(*inMemoryCache)
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, c.Put(fmt.Sprintf("key%d", i), i, 10*time.Millisecond))
+	}
+	assert.Nil(t, c.Put("long", "lived", time.Minute))
+
+	time.Sleep(80 * time.Millisecond)
+	c.mu.RLock()
+	assert.Equal(t, 1, len(c.e))
+	c.mu.RUnlock()
+
+	assert.Nil(t, mgr.Close())
+	_, open := <-c.stopCh
+	assert.False(t, open)
+}
+
+func TestInMemoryCacheClose(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	p := mgr.Provider("inmemory").(*InMemoryProvider)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "inmemory"}))
+	c1 := mgr.Cache("cache1").(*inMemoryCache)
+	assert.Equal(t, 2, len(p.caches))
+
+	t.Log("closed cache is released from provider")
+	assert.Nil(t, c1.Close())
+	assert.Equal(t, []*inMemoryCache{mgr.Cache("cache2").(*inMemoryCache)}, p.caches)
+	assert.Nil(t, c1.Close())
+	assert.Equal(t, 1, len(p.caches))
+
+	assert.Nil(t, mgr.Close())
+	assert.Equal(t, 0, len(p.caches))
+}
+
+func TestInMemoryCacheConcurrent(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeSlide})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				k := fmt.Sprintf("key%d", j%10)
+				_, _ = c.GetOrPut(k, n, time.Minute)
+				_ = c.Get(k)
+				if j%7 == 0 {
+					_ = c.Delete(k)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheLRU(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	var evicted []string
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeLRU,
+		MaxEntries: 3, OnEvict: func(k string, v interface{}) { evicted = append(evicted, fmt.Sprintf("%s=%v", k, v)) }})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, c.Put(fmt.Sprintf("key%d", i), i, time.Minute))
+	}
+	assert.Equal(t, 1, c.Get("key1"), "key1 becomes most recent")
+	assert.Nil(t, c.Put("key4", 4, time.Minute))
+	assert.Equal(t, []string{"key2=2"}, evicted)
+
+	_, err = c.GetOrPut("key3", 33, time.Minute)
+	assert.Nil(t, err)
+	_, err = c.GetOrPut("key5", 5, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"key2=2", "key1=1"}, evicted)
+	assert.True(t, c.Exists("key3"))
+	assert.True(t, c.Exists("key4"))
+	assert.True(t, c.Exists("key5"))
+
+	assert.Nil(t, c.Delete("key3"))
+	assert.Nil(t, c.Put("key6", 6, time.Minute))
+	assert.Equal(t, 2, len(evicted), "delete frees the slot, no callback")
+	assert.Nil(t, c.Flush())
+	assert.Nil(t, c.Put("key7", 7, time.Minute))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheLFU(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	var evicted []string
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeLFU,
+		MaxEntries: 3, OnEvict: func(k string, v interface{}) { evicted = append(evicted, k) }})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, c.Put(fmt.Sprintf("key%d", i), i, time.Minute))
+	}
+	for i := 0; i < 3; i++ {
+		_ = c.Get("key1")
+		_ = c.Get("key3")
+	}
+	_ = c.Get("key2")
+	assert.Nil(t, c.Put("key4", 4, time.Minute))
+	assert.Equal(t, []string{"key2"}, evicted, "least frequently used")
+
+	assert.Nil(t, c.Put("key5", 5, time.Minute))
+	assert.Equal(t, []string{"key2", "key4"}, evicted, "tie broken by least recent")
+	assert.Nil(t, mgr.Close())
+}
+
+func TestInMemoryCacheMaxBytes(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	var evicted []string
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeLRU,
+		MaxBytes: 64, OnEvict: func(k string, v interface{}) { evicted = append(evicted, k) }})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1").(*inMemoryCache)
+	assert.Nil(t, c.Put("k1", strings.Repeat("a", 30), time.Minute))
+	assert.Nil(t, c.Put("k2", strings.Repeat("b", 30), time.Minute))
+	assert.Equal(t, int64(64), c.size)
+	assert.Nil(t, c.Put("k3", []byte("cc"), time.Minute))
+	assert.Equal(t, []string{"k1"}, evicted)
+	assert.Equal(t, int64(36), c.size)
+
+	assert.Equal(t, ErrEntryTooLarge, c.Put("k4", strings.Repeat("d", 63), time.Minute))
+	_, err = c.GetOrPut("k4", sizedValue(100), time.Minute)
+	assert.Equal(t, ErrEntryTooLarge, err)
+	assert.Equal(t, 1, len(evicted))
+
+	assert.True(t, sizeOf(map[string]string{"ab": "cd"}) > sizeOf(map[string]string{}))
+	assert.True(t, sizeOf(struct {
+		Name string
+		Tags []string
+	}{Name: "aah", Tags: []string{"go"}}) > 5)
+	assert.Nil(t, mgr.Close())
+
+	err = mgr.CreateCache(&Config{Name: "cache2", ProviderName: "inmemory", EvictionMode: EvictionModeLFU})
+	assert.Equal(t, "aah/cache: max entries or max bytes is required for cache 'cache2'", err.Error())
+}
+
+func TestInMemoryCacheOnEvictExpired(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	evicted := make(chan string, 5)
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", SweepInterval: 20 * time.Millisecond,
+		OnEvict: func(k string, v interface{}) { evicted <- k }})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	assert.Nil(t, c.Put("key1", 1, 10*time.Millisecond))
+	select {
+	case k := <-evicted:
+		assert.Equal(t, "key1", k)
+	case <-time.After(time.Second):
+		t.Error("expired entry not notified")
+	}
+	assert.Nil(t, mgr.Close())
+}
+
+type sizedValue int64
+
+func (s sizedValue) Size() int64 { return int64(s) }
+
+func createInMemoryTestManager(t *testing.T) *Manager {
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("inmemory", new(InMemoryProvider)))
+
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(config.NewEmpty(), l))
+	return mgr
+}
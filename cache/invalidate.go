@@ -0,0 +1,97 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"time"
+)
+
+// Invalidator interface is implemented by the caches created via cache manager,
+// it provides tag and prefix based invalidation. For e.g.:
+//
+//	c := aah.App().CacheManager().Cache("products").(cache.Invalidator)
+//	err := c.PutWithTags("product:"+id, product, time.Hour, "tenant:"+tenantID)
+//
+//	// after the tenant data modification
+//	err = c.DeleteByTag("tenant:" + tenantID)
+//
+// aah OOTB cache providers implement it. Cache manager wraps the provider caches,
+// for the provider cache which does not implement it methods return `ErrNotSupported`.
+type Invalidator interface {
+	// PutWithTags method adds the cache entry with specified expiration and
+	// associates it with given tags. Returns error if cache entry exists.
+	PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error
+
+	// DeleteByTag method deletes all the cache entries associated with given tag.
+	DeleteByTag(tag string) error
+
+	// DeleteByPrefix method deletes all the cache entries whose key starts
+	// with given prefix.
+	DeleteByPrefix(prefix string) error
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//______________________________________________________________________________
+
+// invalidator method returns the invalidator of wrapped cache, it is used by
+// the cache manager wrappers.
+func invalidator(c Cache) (Invalidator, error) {
+	if ic, ok := c.(Invalidator); ok {
+		return ic, nil
+	}
+	return nil, ErrNotSupported
+}
+
+func (s *statsCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	ic, err := invalidator(s.Cache)
+	if err != nil {
+		return err
+	}
+	if err = ic.PutWithTags(k, v, d, tags...); err == nil {
+		s.put()
+	}
+	return err
+}
+
+func (s *statsCache) DeleteByTag(tag string) error {
+	ic, err := invalidator(s.Cache)
+	if err != nil {
+		return err
+	}
+	return ic.DeleteByTag(tag)
+}
+
+func (s *statsCache) DeleteByPrefix(prefix string) error {
+	ic, err := invalidator(s.Cache)
+	if err != nil {
+		return err
+	}
+	return ic.DeleteByPrefix(prefix)
+}
+
+func (l *loaderCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	ic, err := invalidator(l.reportingCache)
+	if err != nil {
+		return err
+	}
+	return ic.PutWithTags(k, v, d, tags...)
+}
+
+func (l *loaderCache) DeleteByTag(tag string) error {
+	ic, err := invalidator(l.reportingCache)
+	if err != nil {
+		return err
+	}
+	return ic.DeleteByTag(tag)
+}
+
+func (l *loaderCache) DeleteByPrefix(prefix string) error {
+	ic, err := invalidator(l.reportingCache)
+	if err != nil {
+		return err
+	}
+	return ic.DeleteByPrefix(prefix)
+}
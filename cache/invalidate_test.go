@@ -0,0 +1,120 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheInvalidateInMemory(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "inmemory", EvictionMode: EvictionModeLRU, MaxEntries: 2}))
+	testInvalidator(t, mgr.Cache("cache1"))
+
+	t.Log("evicted entry is removed from tag index")
+	c := mgr.Cache("cache2")
+	ic := c.(Invalidator)
+	assert.Nil(t, ic.PutWithTags("key1", "value1", time.Minute, "tag1"))
+	assert.Nil(t, ic.PutWithTags("key2", "value2", time.Minute, "tag1"))
+	assert.Nil(t, ic.PutWithTags("key3", "value3", time.Minute, "tag2"))
+	imc := c.(*inMemoryCache)
+	assert.Equal(t, 1, len(imc.tags["tag1"]))
+	assert.Nil(t, ic.DeleteByTag("tag1"))
+	assert.Nil(t, ic.DeleteByTag("tag2"))
+	assert.Equal(t, 0, len(imc.e))
+	assert.Equal(t, 0, len(imc.tags))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheInvalidateRedis(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"}))
+	testInvalidator(t, mgr.Cache("cache1"))
+
+	assert.Nil(t, mgr.Cache("cache1").(Invalidator).PutWithTags("key1", "value1", time.Minute, "tag1"))
+	assert.Nil(t, mgr.Cache("cache1").Flush())
+	assert.Equal(t, 0, len(srv.Keys()), "tag sets are flushed")
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheInvalidateMemcache(t *testing.T) {
+	srv, mgr := createMemcacheTestManager(t, "")
+	defer srv.Close()
+	opts, err := config.ParseString("prefix_delete = true;")
+	assert.Nil(t, err)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "memcache", Options: opts}))
+	testInvalidator(t, mgr.Cache("cache1"))
+
+	t.Log("index is compacted, deleted and expired keys are dropped")
+	c := mgr.Cache("cache1").(*loaderCache).reportingCache.(*memcacheCache)
+	assert.Nil(t, c.Put("key1", "value1", time.Second))
+	assert.Nil(t, c.Put("key2", "value2", time.Minute))
+	srv.Advance(2 * time.Second)
+	assert.Nil(t, c.rewriteIndex(indexKeys, func(k string) (bool, error) { return c.Exists(k), nil }))
+	srv.Set("myapp:cache1:1#keys", append(srv.Get("myapp:cache1:1#keys"), "\"key2\"\n"...))
+	assert.Nil(t, c.DeleteByPrefix("nokey"))
+	assert.Equal(t, "\"user:1\"\n\"key2\"\n", string(srv.Get("myapp:cache1:1#keys")))
+
+	t.Log("prefix deletion is not enabled")
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "memcache"}))
+	ic := mgr.Cache("cache2").(Invalidator)
+	assert.Nil(t, ic.PutWithTags("key1", "value1", time.Minute, "tag1"))
+	assert.Nil(t, mgr.Cache("cache2").Put("key2", "value2", time.Minute))
+	assert.Equal(t, ErrNotSupported, ic.DeleteByPrefix("key"))
+	assert.Nil(t, srv.Get("myapp:cache2:1#keys"))
+	assert.Equal(t, "\"key1\"\n", string(srv.Get("myapp:cache2:1#tag:tag1")))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheInvalidateNotSupported(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.AddProvider("provider1", &dummyProvider{name: "provider1"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "provider1"}))
+
+	ic := mgr.Cache("cache1").(Invalidator)
+	assert.Equal(t, ErrNotSupported, ic.PutWithTags("key1", "value1", time.Minute, "tag1"))
+	assert.Equal(t, ErrNotSupported, ic.DeleteByTag("tag1"))
+	assert.Equal(t, ErrNotSupported, ic.DeleteByPrefix("key"))
+}
+
+func testInvalidator(t *testing.T, c Cache) {
+	ic := c.(Invalidator)
+	assert.Nil(t, ic.PutWithTags("product:1", "p1", time.Minute, "tenant:a", "category:x"))
+	assert.Nil(t, ic.PutWithTags("product:2", "p2", time.Minute, "tenant:a"))
+	assert.Nil(t, ic.PutWithTags("product:3", "p3", time.Minute, "tenant:b", "category:x"))
+	assert.Equal(t, ErrEntryExists, ic.PutWithTags("product:3", "p3", time.Minute, "tenant:b"))
+	assert.Nil(t, c.Put("order:1", "o1", time.Minute))
+	_, err := c.GetOrPut("order:2", "o2", time.Minute)
+	assert.Nil(t, err)
+	assert.Nil(t, c.Put("user:1", "u1", time.Minute))
+
+	assert.Nil(t, ic.DeleteByTag("tenant:a"))
+	assert.False(t, c.Exists("product:1"))
+	assert.False(t, c.Exists("product:2"))
+	assert.True(t, c.Exists("product:3"))
+	assert.Nil(t, ic.DeleteByTag("tenant:a"), "tag without entries")
+	assert.Nil(t, ic.DeleteByTag("notag"))
+
+	assert.Nil(t, ic.DeleteByTag("category:x"))
+	assert.False(t, c.Exists("product:3"))
+
+	assert.Nil(t, ic.DeleteByPrefix("order:"))
+	assert.False(t, c.Exists("order:1"))
+	assert.False(t, c.Exists("order:2"))
+	assert.True(t, c.Exists("user:1"))
+	assert.Nil(t, ic.DeleteByPrefix("order:"))
+
+	t.Log("entry can be tagged again after invalidation")
+	assert.Nil(t, ic.PutWithTags("product:1", "p1 again", time.Minute, "tenant:a"))
+	assert.Equal(t, "p1 again", c.Get("product:1"))
+	assert.Nil(t, ic.DeleteByTag("tenant:a"))
+	assert.Nil(t, c.Get("product:1"))
+}
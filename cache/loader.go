@@ -0,0 +1,186 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"aahframe.work/log"
+)
+
+// LoaderFunc type is used by `Loader.GetOrLoad` to compute the value on
+// cache miss.
+type LoaderFunc func() (interface{}, error)
+
+// Loader interface is implemented by the caches created via cache manager.
+// For e.g.:
+//
+//	v, err := aah.App().CacheManager().Cache("users").(cache.Loader).GetOrLoad(
+//		userID, 10*time.Minute, func() (interface{}, error) {
+//			return models.FindUser(userID)
+//		})
+//
+// Provider caches which does not implement it, are wrapped by cache manager.
+type Loader interface {
+	// GetOrLoad method returns the cached entry for given key if it exists
+	// otherwise it invokes the loader function, puts the result into cache
+	// and returns it. Concurrent calls for the same key share the single
+	// loader invocation. Loader error is returned as-is and not cached, loader
+	// panic is recovered and returned as error.
+	//
+	// If the cache has `Config.StaleWhileRevalidate` window, entry is served
+	// as-is for the window after its duration and reloaded in the background.
+	GetOrLoad(k string, d time.Duration, fn LoaderFunc) (interface{}, error)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported types and methods
+//______________________________________________________________________________
+
+// flightGroup struct suppresses the duplicate loader invocations per key.
+type flightGroup struct {
+	mu     sync.Mutex
+	m      map[string]*flightCall
+	logger log.Loggerer
+}
+
+type flightCall struct {
+	wg  sync.WaitGroup
+	v   interface{}
+	err error
+}
+
+// do method invokes the fn for the key, concurrent callers of the same key
+// wait for the in-flight invocation and get its result.
+func (g *flightGroup) do(k string, fn LoaderFunc) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*flightCall)
+	}
+	if c, found := g.m[k]; found {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.v, c.err
+	}
+	c := new(flightCall)
+	c.wg.Add(1)
+	g.m[k] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.m, k)
+		g.mu.Unlock()
+		c.wg.Done()
+	}()
+	c.call(k, fn)
+	return c.v, c.err
+}
+
+// call method invokes the fn, panic is recovered and turned into an error,
+// so waiters of the key are not blocked forever.
+func (c *flightCall) call(k string, fn LoaderFunc) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.v, c.err = nil, fmt.Errorf("aah/cache: loader panic for key '%s': %v", k, r)
+		}
+	}()
+	c.v, c.err = fn()
+}
+
+// doAsync method invokes the fn for the key in the background, unless
+// invocation is already in-flight. Error is logged, since there is no caller
+// to receive it.
+func (g *flightGroup) doAsync(k string, fn LoaderFunc) {
+	g.mu.Lock()
+	_, found := g.m[k]
+	g.mu.Unlock()
+	if !found {
+		go func() {
+			if _, err := g.do(k, fn); err != nil && g.logger != nil {
+				g.logger.Errorf("aah/cache: background reload of '%s': %s", k, err)
+			}
+		}()
+	}
+}
+
+// loadThrough struct implements `Loader` on top of the `Cache` methods, it is
+// embedded by the cache manager wrappers. For stale-while-revalidate it keeps
+// the freshness marker entry along with the value entry, value entry lives
+// for the duration plus window.
+type loadThrough struct {
+	swr    time.Duration
+	flight flightGroup
+}
+
+func (l *loadThrough) getOrLoad(c Cache, k string, d time.Duration, fn LoaderFunc) (interface{}, error) {
+	if v := c.Get(k); v != nil {
+		if l.swr > 0 && d > 0 && !c.Exists(freshKey(k)) {
+			l.flight.doAsync(k, func() (interface{}, error) { return l.load(c, k, d, fn, true) })
+		}
+		return v, nil
+	}
+	return l.flight.do(k, func() (interface{}, error) { return l.load(c, k, d, fn, false) })
+}
+
+func (l *loadThrough) load(c Cache, k string, d time.Duration, fn LoaderFunc, refresh bool) (interface{}, error) {
+	v, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	if refresh {
+		_ = c.Delete(k)
+	}
+	if l.swr > 0 && d > 0 {
+		if err = c.Put(k, v, d+l.swr); err == nil || err == ErrEntryExists {
+			_ = c.Delete(freshKey(k))
+			err = c.Put(freshKey(k), true, d)
+		}
+	} else {
+		err = c.Put(k, v, d)
+	}
+	if err != nil && err != ErrEntryExists {
+		return nil, err
+	}
+	return v, nil
+}
+
+// reportingCache interface is satisfied by the caches from cache manager.
+type reportingCache interface {
+	Cache
+	StatsReporter
+}
+
+var (
+	_ Loader         = (*loaderCache)(nil)
+	_ reportingCache = (*loaderCache)(nil)
+	_ Invalidator    = (*loaderCache)(nil)
+)
+
+// loaderCache struct wraps the provider cache which reports statistics but
+// does not implement `Loader`.
+type loaderCache struct {
+	reportingCache
+	loadThrough
+}
+
+func (l *loaderCache) GetOrLoad(k string, d time.Duration, fn LoaderFunc) (interface{}, error) {
+	return l.getOrLoad(l.reportingCache, k, d, fn)
+}
+
+func (l *loaderCache) Close() error {
+	if c, ok := l.reportingCache.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// freshKey method returns the freshness marker key for stale-while-revalidate.
+func freshKey(k string) string {
+	return k + "\x00fresh"
+}
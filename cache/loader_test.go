@@ -0,0 +1,184 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheGetOrLoadInMemory(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory"}))
+	c := mgr.Cache("cache1").(Loader)
+	_, ok := c.(*inMemoryCache)
+	assert.True(t, ok, "in-memory cache implements loader natively")
+
+	testGetOrLoadConcurrent(t, c)
+	testGetOrLoadError(t, c)
+	testGetOrLoadPanic(t, c)
+
+	s := mgr.Cache("cache1").(StatsReporter).Stats()
+	assert.Equal(t, uint64(2), s.Puts)
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheGetOrLoadInMemoryStale(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory",
+		StaleWhileRevalidate: 300 * time.Millisecond}))
+	testGetOrLoadStale(t, mgr.Cache("cache1"))
+	testGetOrLoadStalePanic(t, mgr.Cache("cache1"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheGetOrLoadRedis(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "redis",
+		StaleWhileRevalidate: 300 * time.Millisecond}))
+
+	c := mgr.Cache("cache1").(Loader)
+	_, ok := c.(*loaderCache)
+	assert.True(t, ok, "provider cache without loader gets wrapped")
+	testGetOrLoadConcurrent(t, c)
+	testGetOrLoadError(t, c)
+	testGetOrLoadPanic(t, c)
+	_, ok = c.(StatsReporter)
+	assert.True(t, ok)
+
+	testGetOrLoadStale(t, mgr.Cache("cache2"))
+	testGetOrLoadStalePanic(t, mgr.Cache("cache2"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheGetOrLoadWrapper(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.AddProvider("provider1", &dummyProvider{name: "provider1"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "provider1"}))
+
+	var calls int32
+	c := mgr.Cache("cache1").(Loader)
+	for i := 0; i < 2; i++ {
+		v, err := c.GetOrLoad("key1", time.Minute, func() (interface{}, error) {
+			return fmt.Sprintf("value%d", atomic.AddInt32(&calls, 1)), nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("value%d", i+1), v)
+	}
+	assert.Equal(t, Stats{Name: "cache1", Misses: 2, Puts: 2, Entries: -1, Bytes: -1},
+		mgr.Cache("cache1").(StatsReporter).Stats())
+}
+
+func testGetOrLoadConcurrent(t *testing.T, c Loader) {
+	var calls int32
+	var wg sync.WaitGroup
+	results := make(chan interface{}, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad("key1", time.Minute, func() (interface{}, error) {
+				atomic.AddInt32(&calls, 1)
+				time.Sleep(50 * time.Millisecond)
+				return "value1", nil
+			})
+			assert.Nil(t, err)
+			results <- v
+		}()
+	}
+	wg.Wait()
+	close(results)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls), "loader invoked once")
+	for v := range results {
+		assert.Equal(t, "value1", v)
+	}
+
+	v, err := c.GetOrLoad("key1", time.Minute, func() (interface{}, error) {
+		return nil, errors.New("not invoked")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+}
+
+func testGetOrLoadError(t *testing.T, c Loader) {
+	loadErr := errors.New("load failed")
+	v, err := c.GetOrLoad("key2", time.Minute, func() (interface{}, error) { return nil, loadErr })
+	assert.Equal(t, loadErr, err)
+	assert.Nil(t, v)
+	assert.False(t, c.(Cache).Exists("key2"), "error is not cached")
+}
+
+func testGetOrLoadPanic(t *testing.T, c Loader) {
+	v, err := c.GetOrLoad("key3", time.Minute, func() (interface{}, error) { panic("loader panic") })
+	assert.Equal(t, errors.New("aah/cache: loader panic for key 'key3': loader panic"), err)
+	assert.Nil(t, v)
+
+	t.Log("key is not blocked after loader panic")
+	done := make(chan struct{})
+	go func() {
+		v, err = c.GetOrLoad("key3", time.Minute, func() (interface{}, error) { return "value3", nil })
+		close(done)
+	}()
+	select {
+	case <-done:
+		assert.Nil(t, err)
+		assert.Equal(t, "value3", v)
+	case <-time.After(time.Second):
+		t.Error("GetOrLoad is blocked after loader panic")
+	}
+}
+
+func testGetOrLoadStale(t *testing.T, c Cache) {
+	var calls int32
+	loader := func() (interface{}, error) {
+		return fmt.Sprintf("value%d", atomic.AddInt32(&calls, 1)), nil
+	}
+	l := c.(Loader)
+	v, err := l.GetOrLoad("key1", 100*time.Millisecond, loader)
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+
+	time.Sleep(150 * time.Millisecond)
+	v, err = l.GetOrLoad("key1", 100*time.Millisecond, loader)
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v, "stale value served")
+	assert.Eventually(t, func() bool { return c.Get("key1") == "value2" }, time.Second, 10*time.Millisecond)
+
+	time.Sleep(500 * time.Millisecond)
+	v, err = l.GetOrLoad("key1", 100*time.Millisecond, loader)
+	assert.Nil(t, err)
+	assert.Equal(t, "value3", v, "expired beyond the window gets loaded")
+}
+
+func testGetOrLoadStalePanic(t *testing.T, c Cache) {
+	l := c.(Loader)
+	v, err := l.GetOrLoad("key4", 100*time.Millisecond, func() (interface{}, error) { return "value1", nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+
+	time.Sleep(150 * time.Millisecond)
+	var calls int32
+	v, err = l.GetOrLoad("key4", 100*time.Millisecond, func() (interface{}, error) {
+		atomic.AddInt32(&calls, 1)
+		panic("background reload panic")
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v, "stale value served")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 1 }, time.Second, 10*time.Millisecond)
+
+	t.Log("background reload panic does not block next reload")
+	assert.Eventually(t, func() bool {
+		_, _ = l.GetOrLoad("key4", 100*time.Millisecond, func() (interface{}, error) { return "value2", nil })
+		return c.Get("key4") == "value2"
+	}, time.Second, 10*time.Millisecond)
+}
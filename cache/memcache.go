@@ -0,0 +1,557 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/memcache"
+	"aahframe.work/log"
+)
+
+var _ Provider = (*MemcacheProvider)(nil)
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Memcache Provider
+//______________________________________________________________________________
+
+// MemcacheProvider struct represents the aah Memcached cache provider, it talks
+// Memcached text protocol. Keys are distributed across the servers using
+// consistent hashing. It is registered with aah application cache manager in the
+// name of `memcache`. Provider configuration is read from `cache.providers.<name> { ... }`.
+//
+//	cache {
+//	  providers {
+//	    memcache {
+//	      servers = ["10.0.0.1:11211", "10.0.0.2:11211"]
+//	      dial_timeout = "5s"
+//	      timeout = "3s"
+//	      key_prefix = "myapp:"
+//	      serializer = "gob"
+//	      pool {
+//	        max_idle = 10
+//	      }
+//	    }
+//	  }
+//	}
+//
+// Memcached cannot enumerate the keys, so each cache maintains the generation
+// number and `Cache.Flush` increments it. Entries of previous generations are
+// unreachable and expire on their own or evicted by Memcached. Generation is
+// cached by each application instance and refreshed every second, so flush
+// from the other instance takes effect within a second.
+//
+// For the same reason each cache maintains the index of tags within the
+// generation, it is used by `DeleteByTag`. Index of keys is maintained only if
+// it is enabled for the cache, it is used by `DeleteByPrefix`.
+//
+//	cache {
+//	  stores {
+//	    products {
+//	      provider = "memcache"
+//	      options {
+//	        prefix_delete = true
+//	      }
+//	    }
+//	  }
+//	}
+type MemcacheProvider struct {
+	// Serializer is used to marshal and unmarshal the cache values. If not set,
+	// it is chosen by config `serializer`, supported values are `gob` and `json`.
+	Serializer Serializer
+
+	name      string
+	logger    log.Loggerer
+	keyPrefix string
+	client    *memcache.Client
+}
+
+// Init method initializes the Memcached client from the provider configuration.
+// Connections are established on demand.
+func (p *MemcacheProvider) Init(name string, appCfg *config.Config, logger log.Loggerer) error {
+	p.name = name
+	p.logger = logger
+	cfgPrefix := keyPrefixProviders + "." + name + "."
+
+	opts := &memcache.Options{MaxIdle: appCfg.IntDefault(cfgPrefix+"pool.max_idle", 10)}
+	if opts.Servers, _ = appCfg.StringList(cfgPrefix + "servers"); len(opts.Servers) == 0 {
+		opts.Servers = []string{"localhost:11211"}
+	}
+	var err error
+	if opts.DialTimeout, err = parseDuration(appCfg, cfgPrefix+"dial_timeout", "5s"); err != nil {
+		return err
+	}
+	if opts.Timeout, err = parseDuration(appCfg, cfgPrefix+"timeout", "3s"); err != nil {
+		return err
+	}
+
+	if p.Serializer == nil {
+		if p.Serializer, err = serializerByName(appCfg.StringDefault(cfgPrefix+"serializer", "gob")); err != nil {
+			return err
+		}
+	}
+	p.keyPrefix = appCfg.StringDefault(cfgPrefix+"key_prefix", "")
+	if p.client != nil {
+		_ = p.client.Close()
+	}
+	p.client, err = memcache.New(opts)
+	return err
+}
+
+// Create method creates new Memcached cache with given options. It verifies
+// the connectivity to Memcached server(s).
+func (p *MemcacheProvider) Create(cfg *Config) (Cache, error) {
+	if p.client == nil {
+		return nil, fmt.Errorf("aah/cache: memcache provider '%s' is not initialized", p.name)
+	}
+	c := &memcacheCache{p: p, cfg: cfg, keyPrefix: p.keyPrefix + cfg.Name + ":"}
+	if cfg.Options != nil {
+		c.prefixDelete = cfg.Options.BoolDefault("prefix_delete", false)
+	}
+	if _, err := c.generation(); err != nil {
+		return nil, fmt.Errorf("aah/cache: memcache: %s", err)
+	}
+	return c, nil
+}
+
+// Close method closes the Memcached connections. aah invokes it on application
+// shutdown via `Manager.Close`.
+func (p *MemcacheProvider) Close() error {
+	if p.client == nil {
+		return nil
+	}
+	return p.client.Close()
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Memcache Cache
+//______________________________________________________________________________
+
+var (
+	_ Cache         = (*memcacheCache)(nil)
+	_ StatsReporter = (*memcacheCache)(nil)
+	_ Invalidator   = (*memcacheCache)(nil)
+)
+
+// memcacheCache struct maps the cache eviction modes to Memcached expiry, it
+// has second precision. For `EvictionModeSlide` entry duration is stored along
+// with the value, so that expiry can be extended on access.
+type memcacheCache struct {
+	counters
+	p            *MemcacheProvider
+	cfg          *Config
+	keyPrefix    string
+	prefixDelete bool
+
+	genMu        sync.Mutex
+	gen          string
+	genRefreshAt time.Time
+}
+
+// Name method returns the cache store name.
+func (c *memcacheCache) Name() string {
+	return c.cfg.Name
+}
+
+// Stats method returns the cache statistics. Entries, bytes and evictions
+// are managed by the Memcached server, so they are not reported.
+func (c *memcacheCache) Stats() Stats {
+	return c.snapshot(c.cfg.Name)
+}
+
+// Get method returns the cached entry for given key if it exists otherwise nil.
+// For eviction mode `EvictionModeSlide` expiration gets extended on each access.
+func (c *memcacheCache) Get(k string) interface{} {
+	key, err := c.key(k)
+	if err != nil {
+		c.logError("get", k, err)
+		return nil
+	}
+	item, err := c.p.client.Get(key)
+	if err != nil {
+		c.miss()
+		if err != memcache.ErrCacheMiss {
+			c.logError("get", k, err)
+		}
+		return nil
+	}
+	v, err := c.decode(item)
+	c.lookup(err == nil)
+	if err != nil {
+		c.logError("get", k, err)
+		return nil
+	}
+	return v
+}
+
+// GetOrPut method returns the cached entry for the given key if it exists otherwise
+// it puts the new entry into cache store and returns the value. Entry which cannot be
+// decoded (for e.g. written with other serializer) is replaced atomically using `cas`.
+func (c *memcacheCache) GetOrPut(k string, v interface{}, d time.Duration) (interface{}, error) {
+	key, err := c.key(k)
+	if err != nil {
+		return nil, err
+	}
+	b, err := c.encode(v, d)
+	if err != nil {
+		return nil, err
+	}
+	// entry could change in between read and write, so retry
+	for i := 0; i < 3; i++ {
+		item, err := c.p.client.Get(key)
+		switch err {
+		case nil:
+			if ev, er := c.decode(item); er == nil {
+				c.hit()
+				return ev, nil
+			}
+			item.Value, item.Expiration = b, c.expiration(d)
+			err = c.p.client.CompareAndSwap(item)
+			if err == memcache.ErrCASConflict || err == memcache.ErrCacheMiss {
+				continue
+			}
+		case memcache.ErrCacheMiss:
+			err = c.p.client.Add(&memcache.Item{Key: key, Value: b, Expiration: c.expiration(d)})
+			if err == memcache.ErrNotStored {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		c.miss()
+		c.put()
+		c.index(k, nil)
+		return v, nil
+	}
+	return nil, fmt.Errorf("aah/cache: memcache: unable to get or put '%s' into '%s'", k, c.cfg.Name)
+}
+
+// Put method adds the cache entry with specified expiration. Returns error
+// if cache entry exists.
+func (c *memcacheCache) Put(k string, v interface{}, d time.Duration) error {
+	return c.PutWithTags(k, v, d)
+}
+
+// PutWithTags method adds the cache entry with specified expiration and
+// associates it with given tags. Returns error if cache entry exists. Index
+// update failure is logged, the entry is stored regardless.
+func (c *memcacheCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	key, err := c.key(k)
+	if err != nil {
+		return err
+	}
+	b, err := c.encode(v, d)
+	if err != nil {
+		return err
+	}
+	err = c.p.client.Add(&memcache.Item{Key: key, Value: b, Expiration: c.expiration(d)})
+	if err == memcache.ErrNotStored {
+		return ErrEntryExists
+	}
+	if err != nil {
+		return err
+	}
+	c.put()
+	c.index(k, tags)
+	return nil
+}
+
+// Delete method deletes the cache entry from cache store.
+func (c *memcacheCache) Delete(k string) error {
+	key, err := c.key(k)
+	if err != nil {
+		return err
+	}
+	if err = c.p.client.Delete(key); err == memcache.ErrCacheMiss {
+		return nil
+	}
+	return err
+}
+
+// DeleteByTag method deletes all the cache entries associated with given tag.
+func (c *memcacheCache) DeleteByTag(tag string) error {
+	return c.rewriteIndex(indexTagPrefix+tag, func(k string) (bool, error) {
+		return false, c.Delete(k)
+	})
+}
+
+// DeleteByPrefix method deletes all the cache entries whose key starts
+// with given prefix. Returns `ErrNotSupported` if option `prefix_delete` is
+// not enabled for the cache.
+func (c *memcacheCache) DeleteByPrefix(prefix string) error {
+	if !c.prefixDelete {
+		return ErrNotSupported
+	}
+	return c.rewriteIndex(indexKeys, func(k string) (bool, error) {
+		if strings.HasPrefix(k, prefix) {
+			return false, c.Delete(k)
+		}
+		return true, nil
+	})
+}
+
+// Exists method checks given key exists in cache store and its not expried.
+func (c *memcacheCache) Exists(k string) bool {
+	key, err := c.key(k)
+	if err != nil {
+		c.logError("exists", k, err)
+		return false
+	}
+	_, err = c.p.client.Get(key)
+	if err != nil && err != memcache.ErrCacheMiss {
+		c.logError("exists", k, err)
+	}
+	return err == nil
+}
+
+// Flush methods flushes(deletes) all the cache entries from cache by moving
+// the cache to next generation. Other caches on the same servers are not affected.
+func (c *memcacheCache) Flush() error {
+	gen, err := c.p.client.Increment(c.keyPrefix+"gen", 1)
+	if err == memcache.ErrCacheMiss {
+		if _, err = c.loadGeneration(); err != nil {
+			return err
+		}
+		gen, err = c.p.client.Increment(c.keyPrefix+"gen", 1)
+	}
+	if err != nil {
+		return err
+	}
+	c.setGeneration(strconv.FormatUint(gen, 10))
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Memcache Cache unexported methods
+//______________________________________________________________________________
+
+// generation method returns the current generation of the cache, it is
+// read from Memcached once the cached value is older than
+// `memcacheGenRefresh`.
+func (c *memcacheCache) generation() (string, error) {
+	c.genMu.Lock()
+	gen, refreshAt := c.gen, c.genRefreshAt
+	c.genMu.Unlock()
+	if len(gen) > 0 && time.Now().Before(refreshAt) {
+		return gen, nil
+	}
+	gen, err := c.loadGeneration()
+	if err != nil {
+		return "", err
+	}
+	c.setGeneration(gen)
+	return gen, nil
+}
+
+func (c *memcacheCache) setGeneration(gen string) {
+	c.genMu.Lock()
+	c.gen, c.genRefreshAt = gen, time.Now().Add(memcacheGenRefresh)
+	c.genMu.Unlock()
+}
+
+// loadGeneration method reads the current generation of the cache from
+// Memcached, it is initialized if not exists.
+func (c *memcacheCache) loadGeneration() (string, error) {
+	genKey := c.keyPrefix + "gen"
+	for {
+		item, err := c.p.client.Get(genKey)
+		if err == nil {
+			return string(item.Value), nil
+		}
+		if err != memcache.ErrCacheMiss {
+			return "", err
+		}
+		err = c.p.client.Add(&memcache.Item{Key: genKey, Value: []byte("1")})
+		if err != nil && err != memcache.ErrNotStored {
+			return "", err
+		}
+	}
+}
+
+// key method returns the Memcached key for the cache key, key is hashed if
+// it is not valid Memcached key.
+func (c *memcacheCache) key(k string) (string, error) {
+	gen, err := c.generation()
+	if err != nil {
+		return "", err
+	}
+	key := c.keyPrefix + gen + ":" + k
+	if len(key) > 250 || strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		h := sha1.Sum([]byte(k))
+		key = c.keyPrefix + gen + ":" + hex.EncodeToString(h[:])
+	}
+	return key, nil
+}
+
+// index method records the key in the keys index if prefix deletion is
+// enabled and in given tags index. Index failure is logged, since the entry
+// is already stored.
+func (c *memcacheCache) index(k string, tags []string) {
+	if !c.prefixDelete && len(tags) == 0 {
+		return
+	}
+	rec := []byte(strconv.Quote(k) + "\n")
+	if c.prefixDelete {
+		if err := c.appendIndex(indexKeys, rec); err != nil {
+			c.logError("index", k, err)
+		}
+	}
+	for _, t := range tags {
+		if err := c.appendIndex(indexTagPrefix+t, rec); err != nil {
+			c.logError("index", k, err)
+		}
+	}
+}
+
+// appendIndex method appends the record to the index, index is compacted once
+// if append fails, for e.g. index exceeds the Memcached item size limit.
+func (c *memcacheCache) appendIndex(name string, rec []byte) error {
+	key, err := c.indexKey(name)
+	if err != nil {
+		return err
+	}
+	compacted := false
+	for i := 0; i < 3; i++ {
+		err = c.p.client.Append(&memcache.Item{Key: key, Value: rec})
+		if err == nil {
+			return nil
+		}
+		if err == memcache.ErrNotStored {
+			if err = c.p.client.Add(&memcache.Item{Key: key, Value: rec}); err != memcache.ErrNotStored {
+				return err
+			}
+			continue
+		}
+		if compacted {
+			return err
+		}
+		if err = c.rewriteIndex(name, func(k string) (bool, error) { return c.Exists(k), nil }); err != nil {
+			return err
+		}
+		compacted = true
+	}
+	return fmt.Errorf("aah/cache: memcache: unable to update index of '%s'", c.cfg.Name)
+}
+
+// rewriteIndex method rewrites the index with the keys for which fn returns
+// true, duplicate keys are dropped. Index is updated using `cas`, on conflict
+// it is retried with the latest index.
+func (c *memcacheCache) rewriteIndex(name string, fn func(k string) (bool, error)) error {
+	key, err := c.indexKey(name)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < 3; i++ {
+		item, err := c.p.client.Get(key)
+		if err == memcache.ErrCacheMiss {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		seen := make(map[string]bool)
+		for _, line := range strings.Split(string(item.Value), "\n") {
+			k, err := strconv.Unquote(line)
+			if err != nil || seen[k] {
+				continue
+			}
+			seen[k] = true
+			keep, err := fn(k)
+			if err != nil {
+				return err
+			}
+			if keep {
+				buf.WriteString(line + "\n")
+			}
+		}
+		item.Value = buf.Bytes()
+		switch err = c.p.client.CompareAndSwap(item); err {
+		case memcache.ErrCASConflict:
+			continue
+		case memcache.ErrCacheMiss:
+			return nil
+		}
+		return err
+	}
+	return fmt.Errorf("aah/cache: memcache: unable to update index of '%s'", c.cfg.Name)
+}
+
+// indexKey method returns the Memcached key for the index name, it is outside
+// of the cache key space. Key is hashed if it is not valid Memcached key.
+func (c *memcacheCache) indexKey(name string) (string, error) {
+	gen, err := c.generation()
+	if err != nil {
+		return "", err
+	}
+	key := c.keyPrefix + gen + "#" + name
+	if len(key) > 250 || strings.IndexFunc(key, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		h := sha1.Sum([]byte(name))
+		key = c.keyPrefix + gen + "#" + hex.EncodeToString(h[:])
+	}
+	return key, nil
+}
+
+func (c *memcacheCache) expiration(d time.Duration) time.Duration {
+	if c.cfg.EvictionMode == EvictionModeNoTTL {
+		return 0
+	}
+	return d
+}
+
+func (c *memcacheCache) encode(v interface{}, d time.Duration) ([]byte, error) {
+	b, err := c.p.Serializer.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.EvictionMode == EvictionModeSlide {
+		hdr := make([]byte, 8, 8+len(b))
+		binary.BigEndian.PutUint64(hdr, uint64(d))
+		b = append(hdr, b...)
+	}
+	return b, nil
+}
+
+func (c *memcacheCache) decode(item *memcache.Item) (interface{}, error) {
+	b := item.Value
+	if c.cfg.EvictionMode == EvictionModeSlide {
+		if len(b) < 8 {
+			return nil, errors.New("aah/cache: memcache: invalid entry")
+		}
+		if d := time.Duration(binary.BigEndian.Uint64(b)); d > 0 {
+			if err := c.p.client.Touch(item.Key, d); err != nil && err != memcache.ErrCacheMiss {
+				return nil, err
+			}
+		}
+		b = b[8:]
+	}
+	return c.p.Serializer.Unmarshal(b)
+}
+
+// index names of keys and tags
+const (
+	indexKeys      = "keys"
+	indexTagPrefix = "tag:"
+)
+
+// memcacheGenRefresh is the interval of reading cache generation from Memcached.
+const memcacheGenRefresh = time.Second
+
+func (c *memcacheCache) logError(op, k string, err error) {
+	if c.p.logger != nil {
+		c.p.logger.Errorf("aah/cache: memcache: %s '%s' from '%s': %s", op, k, c.cfg.Name, err)
+	}
+}
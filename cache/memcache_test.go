@@ -0,0 +1,190 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/memcache/memcachetest"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemcacheCacheTTL(t *testing.T) {
+	srv, mgr := createMemcacheTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "memcache"}))
+	c := mgr.Cache("cache1")
+	assert.Equal(t, "cache1", c.Name())
+
+	assert.Nil(t, c.Put("key1", "value1", 2*time.Second))
+	assert.Equal(t, ErrEntryExists, c.Put("key1", "value1", 2*time.Second))
+	assert.Equal(t, "value1", c.Get("key1"))
+	assert.True(t, c.Exists("key1"))
+	assert.Equal(t, []string{"myapp:cache1:1:key1", "myapp:cache1:gen"}, srv.Keys(), "keys are not indexed by default")
+
+	v, err := c.GetOrPut("key1", "value2", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+
+	v, err = c.GetOrPut("key2", 202, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 202, v)
+
+	srv.Advance(3 * time.Second)
+	assert.Nil(t, c.Get("key1"))
+	assert.False(t, c.Exists("key1"))
+	assert.Equal(t, 202, c.Get("key2"), "zero duration never expires")
+
+	assert.Nil(t, c.Put("key1", "value1 again", time.Minute))
+	assert.Nil(t, c.Delete("key1"))
+	assert.Nil(t, c.Delete("key1"))
+	assert.Nil(t, c.Get("key1"))
+
+	s := c.(StatsReporter).Stats()
+	assert.Equal(t, Stats{Name: "cache1", Hits: 3, Misses: 3, Puts: 3, Entries: -1, Bytes: -1}, s)
+
+	t.Log("long and invalid keys are hashed")
+	longKey := strings.Repeat("k", 300)
+	assert.Nil(t, c.Put(longKey, "long", time.Minute))
+	assert.Equal(t, "long", c.Get(longKey))
+	assert.Nil(t, c.Put("key with space", "space", time.Minute))
+	assert.Equal(t, "space", c.Get("key with space"))
+
+	assert.Nil(t, mgr.Close())
+	assert.Nil(t, c.Get("key2"), "closed client")
+	assert.False(t, c.Exists("key2"))
+}
+
+func TestMemcacheCacheNoTTLAndSlide(t *testing.T) {
+	srv, mgr := createMemcacheTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "nottl", ProviderName: "memcache", EvictionMode: EvictionModeNoTTL}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "slide", ProviderName: "memcache", EvictionMode: EvictionModeSlide}))
+
+	c := mgr.Cache("nottl")
+	assert.Nil(t, c.Put("key1", "value1", time.Second))
+	srv.Advance(time.Hour)
+	assert.Equal(t, "value1", c.Get("key1"))
+
+	c = mgr.Cache("slide")
+	assert.Nil(t, c.Put("key1", "value1", time.Minute))
+	for i := 0; i < 4; i++ {
+		srv.Advance(40 * time.Second)
+		assert.Equal(t, "value1", c.Get("key1"), "access extends the expiration")
+	}
+	srv.Advance(61 * time.Second)
+	assert.Nil(t, c.Get("key1"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestMemcacheCacheFlush(t *testing.T) {
+	srv, mgr := createMemcacheTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "memcache"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "memcache"}))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, mgr.Cache("cache1").Put(fmt.Sprintf("key%d", i), i, time.Minute))
+		assert.Nil(t, mgr.Cache("cache2").Put(fmt.Sprintf("key%d", i), i, time.Minute))
+	}
+
+	assert.Nil(t, mgr.Cache("cache1").Flush())
+	assert.False(t, mgr.Cache("cache1").Exists("key3"))
+	assert.True(t, mgr.Cache("cache2").Exists("key3"))
+	assert.Nil(t, mgr.Cache("cache1").Put("key3", "new generation", time.Minute))
+	assert.Equal(t, "new generation", mgr.Cache("cache1").Get("key3"))
+
+	t.Log("generation is cached until refresh")
+	c := mgr.Cache("cache2").(*loaderCache).reportingCache.(*memcacheCache)
+	srv.Set("myapp:cache2:gen", []byte("5"))
+	assert.True(t, c.Exists("key3"))
+	c.genMu.Lock()
+	c.genRefreshAt = time.Now()
+	c.genMu.Unlock()
+	assert.False(t, c.Exists("key3"), "generation flushed by other instance")
+	assert.Nil(t, mgr.Close())
+}
+
+func TestMemcacheCacheGetOrPutCAS(t *testing.T) {
+	srv, mgr := createMemcacheTestManager(t, `serializer = "json"`)
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "memcache"}))
+	c := mgr.Cache("cache1")
+
+	t.Log("undecodable entry is replaced")
+	srv.Set("myapp:cache1:1:key1", []byte("{not json"))
+	assert.Nil(t, c.Get("key1"))
+	v, err := c.GetOrPut("key1", map[string]interface{}{"name": "aah"}, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "aah"}, v)
+	assert.Equal(t, map[string]interface{}{"name": "aah"}, c.Get("key1"))
+
+	t.Log("concurrent callers get the same value")
+	wg := sync.WaitGroup{}
+	results := make(chan interface{}, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			v, err := c.GetOrPut("key2", n, time.Minute)
+			assert.Nil(t, err)
+			results <- v
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	first := c.Get("key2")
+	for v := range results {
+		assert.Equal(t, fmt.Sprint(first), fmt.Sprint(v))
+	}
+	assert.Nil(t, mgr.Close())
+}
+
+func TestMemcacheCacheErrors(t *testing.T) {
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("memcache", new(MemcacheProvider)))
+	_, err := mgr.Provider("memcache").Create(&Config{Name: "cache1"})
+	assert.Equal(t, "aah/cache: memcache provider '' is not initialized", err.Error())
+
+	appCfg, _ := config.ParseString(`cache { providers { memcache { servers = ["127.0.0.1:1"]; } } }`)
+	assert.Nil(t, mgr.InitProviders(appCfg, nil))
+	assert.NotNil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "memcache"}))
+	assert.Nil(t, mgr.Close())
+
+	appCfg, _ = config.ParseString(`cache { providers { memcache { timeout = "3"; } } }`)
+	assert.Equal(t, `aah/cache: 'cache.providers.memcache.timeout' time: missing unit in duration "3"`,
+		new(MemcacheProvider).Init("memcache", appCfg, nil).Error())
+}
+
+func createMemcacheTestManager(t *testing.T, extraCfg string) (*memcachetest.Server, *Manager) {
+	srv := memcachetest.NewServer()
+	appCfg, err := config.ParseString(fmt.Sprintf(`cache {
+  providers {
+    memcache {
+      servers = ["%s"]
+      key_prefix = "myapp:"
+      %s
+    }
+  }
+}`, srv.Addr, extraCfg))
+	assert.Nil(t, err)
+
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("memcache", new(MemcacheProvider)))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	return srv, mgr
+}
@@ -0,0 +1,371 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/resp"
+	"aahframe.work/log"
+)
+
+var _ Provider = (*RedisProvider)(nil)
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Redis Provider
+//______________________________________________________________________________
+
+// RedisProvider struct represents the aah Redis cache provider, it talks RESP
+// protocol with connection pooling. It is registered with aah application cache
+// manager in the name of `redis`. Provider configuration is read from
+// `cache.providers.<name> { ... }`.
+//
+//	cache {
+//	  providers {
+//	    redis {
+//	      address = "localhost:6379"
+//	      password = ""
+//	      db = 0
+//	      dial_timeout = "5s"
+//	      read_timeout = "3s"
+//	      write_timeout = "3s"
+//	      key_prefix = "myapp:"
+//	      serializer = "gob"
+//	      pool {
+//	        max_idle = 10
+//	        idle_timeout = "5m"
+//	      }
+//	    }
+//	  }
+//	}
+//
+// Cache entries are stored with the key `<key_prefix><cache name>:<key>`. Tags
+// are kept as Redis sets in the same key space, until `DeleteByTag` or `Flush`.
+type RedisProvider struct {
+	// Serializer is used to marshal and unmarshal the cache values. If not set,
+	// it is chosen by config `serializer`, supported values are `gob` and `json`.
+	Serializer Serializer
+
+	name      string
+	logger    log.Loggerer
+	keyPrefix string
+	pool      *resp.Pool
+}
+
+// Init method initializes the Redis connection pool from the provider configuration.
+// Connections are established on demand.
+func (p *RedisProvider) Init(name string, appCfg *config.Config, logger log.Loggerer) error {
+	p.name = name
+	p.logger = logger
+	cfgPrefix := keyPrefixProviders + "." + name + "."
+
+	opts := &resp.Options{
+		Address:  appCfg.StringDefault(cfgPrefix+"address", "localhost:6379"),
+		Password: appCfg.StringDefault(cfgPrefix+"password", ""),
+		DB:       appCfg.IntDefault(cfgPrefix+"db", 0),
+		MaxIdle:  appCfg.IntDefault(cfgPrefix+"pool.max_idle", 10),
+	}
+	var err error
+	if opts.DialTimeout, err = parseDuration(appCfg, cfgPrefix+"dial_timeout", "5s"); err != nil {
+		return err
+	}
+	if opts.ReadTimeout, err = parseDuration(appCfg, cfgPrefix+"read_timeout", "3s"); err != nil {
+		return err
+	}
+	if opts.WriteTimeout, err = parseDuration(appCfg, cfgPrefix+"write_timeout", "3s"); err != nil {
+		return err
+	}
+	if opts.IdleTimeout, err = parseDuration(appCfg, cfgPrefix+"pool.idle_timeout", "5m"); err != nil {
+		return err
+	}
+
+	if p.Serializer == nil {
+		if p.Serializer, err = serializerByName(appCfg.StringDefault(cfgPrefix+"serializer", "gob")); err != nil {
+			return err
+		}
+	}
+	p.keyPrefix = appCfg.StringDefault(cfgPrefix+"key_prefix", "")
+	if p.pool != nil {
+		_ = p.pool.Close()
+	}
+	p.pool = resp.NewPool(opts)
+	return nil
+}
+
+// Create method creates new Redis cache with given options. It verifies the
+// connectivity to Redis server.
+func (p *RedisProvider) Create(cfg *Config) (Cache, error) {
+	if p.pool == nil {
+		return nil, fmt.Errorf("aah/cache: redis provider '%s' is not initialized", p.name)
+	}
+	if _, err := p.pool.Do("PING"); err != nil {
+		return nil, fmt.Errorf("aah/cache: redis: %s", err)
+	}
+	return &redisCache{p: p, cfg: cfg, keyPrefix: p.keyPrefix + cfg.Name + ":"}, nil
+}
+
+// Close method closes the Redis connection pool. aah invokes it on application
+// shutdown via `Manager.Close`.
+func (p *RedisProvider) Close() error {
+	if p.pool == nil {
+		return nil
+	}
+	return p.pool.Close()
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Redis Cache
+//______________________________________________________________________________
+
+var (
+	_ Cache         = (*redisCache)(nil)
+	_ StatsReporter = (*redisCache)(nil)
+	_ Invalidator   = (*redisCache)(nil)
+)
+
+// redisCache struct maps the cache eviction modes to Redis key expiry. For
+// `EvictionModeSlide` entry duration is stored along with the value, so that
+// expiry can be extended on access.
+type redisCache struct {
+	counters
+	p         *RedisProvider
+	cfg       *Config
+	keyPrefix string
+}
+
+// Name method returns the cache store name.
+func (c *redisCache) Name() string {
+	return c.cfg.Name
+}
+
+// Stats method returns the cache statistics. Entries, bytes and evictions
+// are managed by the Redis server, so they are not reported.
+func (c *redisCache) Stats() Stats {
+	return c.snapshot(c.cfg.Name)
+}
+
+// Get method returns the cached entry for given key if it exists otherwise nil.
+// For eviction mode `EvictionModeSlide` expiration gets extended on each access.
+func (c *redisCache) Get(k string) interface{} {
+	v, found, err := c.get(k)
+	c.lookup(found)
+	if err != nil {
+		c.p.logger.Errorf("aah/cache: redis: get '%s' from '%s': %s", k, c.cfg.Name, err)
+		return nil
+	}
+	if !found {
+		return nil
+	}
+	return v
+}
+
+// GetOrPut method returns the cached entry for the given key if it exists otherwise
+// it puts the new entry into cache store and returns the value.
+func (c *redisCache) GetOrPut(k string, v interface{}, d time.Duration) (interface{}, error) {
+	b, err := c.encode(v, d)
+	if err != nil {
+		return nil, err
+	}
+	// entry could expire in between SET NX and GET, so retry
+	for i := 0; i < 3; i++ {
+		ok, err := c.setNX(k, b, d)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.miss()
+			c.put()
+			return v, nil
+		}
+		ev, found, err := c.get(k)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			c.hit()
+			return ev, nil
+		}
+	}
+	return nil, fmt.Errorf("aah/cache: redis: unable to get or put '%s' into '%s'", k, c.cfg.Name)
+}
+
+// Put method adds the cache entry with specified expiration. Returns error
+// if cache entry exists.
+func (c *redisCache) Put(k string, v interface{}, d time.Duration) error {
+	return c.PutWithTags(k, v, d)
+}
+
+// PutWithTags method adds the cache entry with specified expiration and
+// associates it with given tags. Returns error if cache entry exists.
+func (c *redisCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	b, err := c.encode(v, d)
+	if err != nil {
+		return err
+	}
+	ok, err := c.setNX(k, b, d)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrEntryExists
+	}
+	c.put()
+	for _, t := range tags {
+		if _, err = c.p.pool.Do("SADD", c.tagKey(t), c.key(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete method deletes the cache entry from cache store.
+func (c *redisCache) Delete(k string) error {
+	_, err := c.p.pool.Do("DEL", c.key(k))
+	return err
+}
+
+// Exists method checks given key exists in cache store and its not expried.
+func (c *redisCache) Exists(k string) bool {
+	reply, err := c.p.pool.Do("EXISTS", c.key(k))
+	if err != nil {
+		c.p.logger.Errorf("aah/cache: redis: exists '%s' from '%s': %s", k, c.cfg.Name, err)
+		return false
+	}
+	n, _ := reply.(int64)
+	return n == 1
+}
+
+// DeleteByTag method deletes all the cache entries associated with given tag.
+func (c *redisCache) DeleteByTag(tag string) error {
+	reply, err := c.p.pool.Do("SMEMBERS", c.tagKey(tag))
+	if err != nil {
+		return err
+	}
+	keys, _ := reply.([]interface{})
+	if len(keys) == 0 {
+		return nil
+	}
+	if _, err = c.p.pool.Do(append([]interface{}{"DEL"}, keys...)...); err != nil {
+		return err
+	}
+	// members added in the meantime are retained
+	_, err = c.p.pool.Do(append([]interface{}{"SREM", c.tagKey(tag)}, keys...)...)
+	return err
+}
+
+// DeleteByPrefix method deletes all the cache entries whose key starts
+// with given prefix.
+func (c *redisCache) DeleteByPrefix(prefix string) error {
+	return c.deleteMatch(escapeGlob(c.keyPrefix+prefix) + "*")
+}
+
+// Flush methods flushes(deletes) all the cache entries from cache. Only the
+// entries of this cache get deleted, not the entire Redis database.
+func (c *redisCache) Flush() error {
+	return c.deleteMatch(escapeGlob(c.keyPrefix) + "*")
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Redis Cache unexported methods
+//______________________________________________________________________________
+
+func (c *redisCache) key(k string) string {
+	return c.keyPrefix + k
+}
+
+// tagKey method returns the Redis key of the tag set.
+func (c *redisCache) tagKey(tag string) string {
+	return c.keyPrefix + "\x00tag:" + tag
+}
+
+// deleteMatch method deletes the keys matching the given glob pattern using
+// `SCAN`, so that Redis server is not blocked.
+func (c *redisCache) deleteMatch(pattern string) error {
+	cursor := "0"
+	for {
+		reply, err := c.p.pool.Do("SCAN", cursor, "MATCH", pattern, "COUNT", 500)
+		if err != nil {
+			return err
+		}
+		arr, ok := reply.([]interface{})
+		if !ok || len(arr) != 2 {
+			return errors.New("aah/cache: redis: unexpected scan reply")
+		}
+		cb, _ := arr[0].([]byte)
+		cursor = string(cb)
+		if keys, _ := arr[1].([]interface{}); len(keys) > 0 {
+			if _, err = c.p.pool.Do(append([]interface{}{"DEL"}, keys...)...); err != nil {
+				return err
+			}
+		}
+		if cursor == "0" || len(cursor) == 0 {
+			return nil
+		}
+	}
+}
+
+func (c *redisCache) get(k string) (interface{}, bool, error) {
+	reply, err := c.p.pool.Do("GET", c.key(k))
+	if err != nil {
+		return nil, false, err
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, false, nil
+	}
+
+	if c.cfg.EvictionMode == EvictionModeSlide {
+		if len(b) < 8 {
+			return nil, false, errors.New("aah/cache: redis: invalid entry")
+		}
+		if d := time.Duration(binary.BigEndian.Uint64(b)); d > 0 {
+			if _, err = c.p.pool.Do("PEXPIRE", c.key(k), d); err != nil {
+				return nil, false, err
+			}
+		}
+		b = b[8:]
+	}
+	v, err := c.p.Serializer.Unmarshal(b)
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+func (c *redisCache) encode(v interface{}, d time.Duration) ([]byte, error) {
+	b, err := c.p.Serializer.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.EvictionMode == EvictionModeSlide {
+		hdr := make([]byte, 8, 8+len(b))
+		binary.BigEndian.PutUint64(hdr, uint64(d))
+		b = append(hdr, b...)
+	}
+	return b, nil
+}
+
+func (c *redisCache) setNX(k string, b []byte, d time.Duration) (bool, error) {
+	args := []interface{}{"SET", c.key(k), b, "NX"}
+	if c.cfg.EvictionMode != EvictionModeNoTTL && d >= time.Millisecond {
+		args = append(args, "PX", d)
+	}
+	reply, err := c.p.pool.Do(args...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+func escapeGlob(s string) string {
+	return globEscaper.Replace(s)
+}
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
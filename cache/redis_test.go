@@ -0,0 +1,182 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/resp/resptest"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisCacheTTL(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+
+	err := mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"})
+	assert.Nil(t, err)
+
+	c := mgr.Cache("cache1")
+	assert.Equal(t, "cache1", c.Name())
+
+	assert.Nil(t, c.Put("key1", "value1", 50*time.Millisecond))
+	assert.Equal(t, ErrEntryExists, c.Put("key1", "value1", 50*time.Millisecond))
+	assert.Equal(t, "value1", c.Get("key1"))
+	assert.True(t, c.Exists("key1"))
+	assert.Equal(t, []string{"myapp:cache1:key1"}, srv.Keys())
+
+	v, err := c.GetOrPut("key1", "value2", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "value1", v)
+
+	v, err = c.GetOrPut("key2", 202, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 202, v)
+	assert.Equal(t, time.Duration(-1), srv.TTL("myapp:cache1:key2"))
+
+	time.Sleep(80 * time.Millisecond)
+	assert.Nil(t, c.Get("key1"))
+	assert.False(t, c.Exists("key1"))
+	assert.Equal(t, 202, c.Get("key2"), "zero duration never expires")
+
+	assert.Nil(t, c.Put("key1", "value1 again", time.Minute))
+	assert.Nil(t, c.Delete("key1"))
+	assert.Nil(t, c.Get("key1"))
+
+	s := c.(StatsReporter).Stats()
+	assert.Equal(t, Stats{Name: "cache1", Hits: 3, Misses: 3, Puts: 3, Entries: -1, Bytes: -1}, s)
+
+	assert.Nil(t, mgr.Close())
+	assert.Nil(t, c.Get("key2"), "closed pool")
+	assert.False(t, c.Exists("key2"))
+}
+
+func TestRedisCacheNoTTLAndSlide(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "nottl", ProviderName: "redis", EvictionMode: EvictionModeNoTTL}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "slide", ProviderName: "redis", EvictionMode: EvictionModeSlide}))
+
+	c := mgr.Cache("nottl")
+	assert.Nil(t, c.Put("key1", "value1", time.Millisecond))
+	assert.Equal(t, time.Duration(-1), srv.TTL("myapp:nottl:key1"))
+
+	c = mgr.Cache("slide")
+	assert.Nil(t, c.Put("key1", "value1", 60*time.Millisecond))
+	for i := 0; i < 4; i++ {
+		time.Sleep(30 * time.Millisecond)
+		assert.Equal(t, "value1", c.Get("key1"), "access extends the expiration")
+	}
+	time.Sleep(90 * time.Millisecond)
+	assert.Nil(t, c.Get("key1"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestRedisCacheFlush(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache[1]", ProviderName: "redis"}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "redis"}))
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, mgr.Cache("cache[1]").Put(fmt.Sprintf("key%d", i), i, time.Minute))
+		assert.Nil(t, mgr.Cache("cache2").Put(fmt.Sprintf("key%d", i), i, time.Minute))
+	}
+	assert.Equal(t, 10, len(srv.Keys()))
+
+	assert.Nil(t, mgr.Cache("cache[1]").Flush())
+	assert.Equal(t, 5, len(srv.Keys()))
+	assert.True(t, mgr.Cache("cache2").Exists("key3"))
+	assert.Nil(t, mgr.Close())
+}
+
+func TestRedisCacheSerializer(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, `serializer = "json"`)
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"}))
+	c := mgr.Cache("cache1")
+	assert.Nil(t, c.Put("key1", map[string]interface{}{"name": "aah", "stars": 600}, time.Minute))
+	assert.Equal(t, map[string]interface{}{"name": "aah", "stars": float64(600)}, c.Get("key1"))
+
+	assert.NotNil(t, c.Put("key2", make(chan int), time.Minute), "json unsupported type")
+	assert.Nil(t, mgr.Close())
+
+	p := new(RedisProvider)
+	appCfg, _ := config.ParseString(`cache { providers { redis { serializer = "xml"; } } }`)
+	assert.Equal(t, "aah/cache: unsupported serializer 'xml'", p.Init("redis", appCfg, nil).Error())
+	appCfg, _ = config.ParseString(`cache { providers { redis { dial_timeout = "5"; } } }`)
+	assert.Equal(t, `aah/cache: 'cache.providers.redis.dial_timeout' time: missing unit in duration "5"`,
+		p.Init("redis", appCfg, nil).Error())
+}
+
+func TestRedisCacheConnectError(t *testing.T) {
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("redis", new(RedisProvider)))
+	_, err := mgr.Provider("redis").Create(&Config{Name: "cache1"})
+	assert.Equal(t, "aah/cache: redis provider '' is not initialized", err.Error())
+
+	appCfg, _ := config.ParseString(`cache { providers { redis { address = "127.0.0.1:1"; } } }`)
+	assert.Nil(t, mgr.InitProviders(appCfg, nil))
+	err = mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"})
+	assert.NotNil(t, err)
+	assert.Nil(t, mgr.Close())
+}
+
+func TestRedisCacheConcurrent(t *testing.T) {
+	srv, mgr := createRedisTestManager(t, "")
+	defer srv.Close()
+
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "redis"}))
+	c := mgr.Cache("cache1")
+	wg := sync.WaitGroup{}
+	results := make(chan interface{}, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			v, err := c.GetOrPut("key1", n, time.Minute)
+			assert.Nil(t, err)
+			results <- v
+		}(i)
+	}
+	wg.Wait()
+	close(results)
+	first := c.Get("key1")
+	for v := range results {
+		assert.Equal(t, first, v, "all callers get the same value")
+	}
+	assert.Nil(t, mgr.Close())
+}
+
+func createRedisTestManager(t *testing.T, extraCfg string) (*resptest.Server, *Manager) {
+	srv := resptest.NewServer()
+	srv.SetPassword("s3cret")
+	appCfg, err := config.ParseString(fmt.Sprintf(`cache {
+  providers {
+    redis {
+      address = "%s"
+      password = "s3cret"
+      key_prefix = "myapp:"
+      %s
+    }
+  }
+}`, srv.Addr, extraCfg))
+	assert.Nil(t, err)
+
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("redis", new(RedisProvider)))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	return srv, mgr
+}
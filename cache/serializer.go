@@ -0,0 +1,80 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+)
+
+// Serializer interface is used by the out-of-process cache providers such as
+// Redis and Memcache to convert the cache value to bytes and back.
+type Serializer interface {
+	// Marshal method returns the byte representation of given value.
+	Marshal(v interface{}) ([]byte, error)
+
+	// Unmarshal method returns the value from given bytes.
+	Unmarshal(b []byte) (interface{}, error)
+}
+
+var (
+	_ Serializer = (*GobSerializer)(nil)
+	_ Serializer = (*JSONSerializer)(nil)
+)
+
+// GobSerializer struct serializes the values using `encoding/gob`. Custom
+// types have to be registered via `gob.Register` before use.
+type GobSerializer struct{}
+
+// Marshal method encodes the given value using gob.
+func (GobSerializer) Marshal(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gob.NewEncoder(buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal method decodes the given gob bytes.
+func (GobSerializer) Unmarshal(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// JSONSerializer struct serializes the values using `encoding/json`. Values
+// are returned as per `json.Unmarshal` into `interface{}`, for e.g. numbers
+// are `float64` and objects are `map[string]interface{}`.
+type JSONSerializer struct{}
+
+// Marshal method encodes the given value to JSON.
+func (JSONSerializer) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal method decodes the given JSON bytes.
+func (JSONSerializer) Unmarshal(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// serializerByName method returns the serializer for the config value
+// `serializer`, supported values are `gob` and `json`.
+func serializerByName(name string) (Serializer, error) {
+	switch name {
+	case "", "gob":
+		return GobSerializer{}, nil
+	case "json":
+		return JSONSerializer{}, nil
+	}
+	return nil, fmt.Errorf("aah/cache: unsupported serializer '%s'", name)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// StatsReporter interface is implemented by the caches created via cache
+// manager. For e.g.:
+//
+//	stats := aah.App().CacheManager().Cache("users").(cache.StatsReporter).Stats()
+//
+// Provider caches which does not implement it, are wrapped by cache manager
+// to count the hits, misses and puts.
+type StatsReporter interface {
+	Stats() Stats
+}
+
+// Stats struct holds the cache statistics. Counters are since the cache creation.
+type Stats struct {
+	Name      string `json:"name"`
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Puts      uint64 `json:"puts"`
+	Evictions uint64 `json:"evictions"`
+
+	// Entries and Bytes are -1 when cache store cannot report them, for e.g.
+	// Redis and Memcache. Bytes is approximate value.
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// HitRatio method returns the ratio of hits over the lookups, zero if
+// there are no lookups.
+func (s Stats) HitRatio() float64 {
+	lookups := s.Hits + s.Misses
+	if lookups == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(lookups)
+}
+
+// ManagerStats struct holds the statistics of all the caches in the
+// cache manager and their aggregate.
+type ManagerStats struct {
+	// Total is sum of all the cache statistics, caches which cannot report
+	// entries and bytes are not included in those sums.
+	Total  Stats   `json:"total"`
+	Caches []Stats `json:"caches"`
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported types and methods
+//______________________________________________________________________________
+
+// counters struct is embedded by the caches to track the statistics, it is
+// safe for concurrent use.
+type counters struct {
+	hits      uint64
+	misses    uint64
+	puts      uint64
+	evictions uint64
+}
+
+func (c *counters) hit()          { atomic.AddUint64(&c.hits, 1) }
+func (c *counters) miss()         { atomic.AddUint64(&c.misses, 1) }
+func (c *counters) put()          { atomic.AddUint64(&c.puts, 1) }
+func (c *counters) evicted(n int) { atomic.AddUint64(&c.evictions, uint64(n)) }
+
+func (c *counters) lookup(found bool) {
+	if found {
+		c.hit()
+	} else {
+		c.miss()
+	}
+}
+
+func (c *counters) snapshot(name string) Stats {
+	return Stats{
+		Name:      name,
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Puts:      atomic.LoadUint64(&c.puts),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Entries:   -1,
+		Bytes:     -1,
+	}
+}
+
+var (
+	_ Cache         = (*statsCache)(nil)
+	_ StatsReporter = (*statsCache)(nil)
+	_ Loader        = (*statsCache)(nil)
+	_ Invalidator   = (*statsCache)(nil)
+)
+
+// statsCache struct wraps the provider cache which does not report statistics,
+// it implements `Loader` too.
+type statsCache struct {
+	Cache
+	counters
+	loadThrough
+}
+
+func (s *statsCache) Get(k string) interface{} {
+	v := s.Cache.Get(k)
+	s.lookup(v != nil)
+	return v
+}
+
+// GetOrPut method looks up the entry first to count hit or miss, then
+// delegates to the cache to keep its atomicity.
+func (s *statsCache) GetOrPut(k string, v interface{}, d time.Duration) (interface{}, error) {
+	if ev := s.Cache.Get(k); ev != nil {
+		s.hit()
+		return ev, nil
+	}
+	s.miss()
+	ev, err := s.Cache.GetOrPut(k, v, d)
+	if err == nil {
+		s.put()
+	}
+	return ev, err
+}
+
+func (s *statsCache) Put(k string, v interface{}, d time.Duration) error {
+	err := s.Cache.Put(k, v, d)
+	if err == nil {
+		s.put()
+	}
+	return err
+}
+
+func (s *statsCache) GetOrLoad(k string, d time.Duration, fn LoaderFunc) (interface{}, error) {
+	return s.getOrLoad(s, k, d, fn)
+}
+
+func (s *statsCache) Stats() Stats {
+	return s.snapshot(s.Name())
+}
+
+func (s *statsCache) Close() error {
+	if c, ok := s.Cache.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheStatsInMemory(t *testing.T) {
+	mgr := createInMemoryTestManager(t)
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "inmemory", EvictionMode: EvictionModeLRU, MaxEntries: 2}))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache2", ProviderName: "inmemory"}))
+
+	c := mgr.Cache("cache1")
+	assert.Nil(t, c.Put("key1", "value1", time.Minute))
+	assert.Equal(t, ErrEntryExists, c.Put("key1", "value1", time.Minute))
+	_ = c.Get("key1")
+	_ = c.Get("nokey")
+	_, _ = c.GetOrPut("key1", "value1", time.Minute)
+	_, _ = c.GetOrPut("key2", "value2", time.Minute)
+	assert.Nil(t, c.Put("key3", "value3", time.Minute))
+
+	s := c.(StatsReporter).Stats()
+	assert.Equal(t, "cache1", s.Name)
+	assert.Equal(t, uint64(2), s.Hits)
+	assert.Equal(t, uint64(2), s.Misses)
+	assert.Equal(t, uint64(3), s.Puts)
+	assert.Equal(t, uint64(1), s.Evictions)
+	assert.Equal(t, int64(2), s.Entries)
+	assert.Equal(t, int64(20), s.Bytes)
+	assert.Equal(t, 0.5, s.HitRatio())
+
+	assert.Nil(t, mgr.Cache("cache2").Put("key1", []byte("1234"), time.Minute))
+	ms := mgr.Stats()
+	assert.Equal(t, 2, len(ms.Caches))
+	assert.Equal(t, "cache2", ms.Caches[1].Name)
+	assert.Equal(t, uint64(4), ms.Total.Puts)
+	assert.Equal(t, int64(3), ms.Total.Entries)
+	assert.Equal(t, int64(28), ms.Total.Bytes)
+
+	b, err := json.Marshal(ms)
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"caches":[{"name":"cache1","hits":2,"misses":2,"puts":3,"evictions":1,"entries":2,"bytes":20}`)
+	assert.Equal(t, float64(0), Stats{}.HitRatio())
+	assert.Nil(t, mgr.Close())
+}
+
+func TestCacheStatsWrapper(t *testing.T) {
+	mgr := NewManager()
+	assert.Nil(t, mgr.AddProvider("provider1", &dummyProvider{name: "provider1"}))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(config.NewEmpty(), l))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "provider1"}))
+
+	c := mgr.Cache("cache1")
+	_, ok := c.(*statsCache)
+	assert.True(t, ok, "provider cache without stats gets wrapped")
+	assert.Equal(t, "cache1", c.Name())
+	_ = c.Get("key1")
+	_, _ = c.GetOrPut("key1", "value1", time.Minute)
+	assert.Nil(t, c.Put("key2", "value2", time.Minute))
+
+	s := c.(StatsReporter).Stats()
+	assert.Equal(t, Stats{Name: "cache1", Misses: 2, Puts: 2, Entries: -1, Bytes: -1}, s)
+
+	ms := mgr.Stats()
+	assert.Equal(t, int64(0), ms.Total.Entries)
+	assert.Nil(t, c.(*statsCache).Close())
+}
@@ -0,0 +1,354 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/log"
+)
+
+var _ Provider = (*TieredProvider)(nil)
+
+// Invalidation struct represents the deletion happened in the two-tier cache
+// on one application instance. Exactly one of Key, Prefix, Tag and Flush is set.
+type Invalidation struct {
+	Cache  string `json:"cache"`
+	Key    string `json:"key,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Tag    string `json:"tag,omitempty"`
+	Flush  bool   `json:"flush,omitempty"`
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Tiered Provider
+//______________________________________________________________________________
+
+// TieredProvider struct represents the aah two-tier cache provider. It keeps
+// the small in-process near cache (L1) in front of the shared far cache (L2)
+// created by the other provider registered in the cache manager. Reads are
+// served from near cache if present otherwise from far cache and populated
+// into near cache. Writes go to far cache and then near cache.
+//
+// It is registered with aah application cache manager in the name of `tiered`.
+// Provider configuration is read from `cache.providers.<name> { ... }`.
+//
+//	cache {
+//	  providers {
+//	    tiered {
+//	      # Far cache provider name, it is required.
+//	      far = "redis"
+//
+//	      # Near cache entry duration, entry duration is used if it is shorter.
+//	      # Default value is `30s`.
+//	      near_ttl = "30s"
+//
+//	      # Near cache is LRU bounded. Default value is `1000`.
+//	      near_max_entries = 1000
+//	    }
+//	  }
+//	}
+//
+// Deletes on one instance do not reach the near cache of other instances on
+// their own, set `OnInvalidate` hook to publish them (e.g. Redis pub/sub) and
+// apply the received ones via `Invalidate`. Until then other instances may serve
+// the stale entry up to near cache duration.
+//
+// For eviction mode `EvictionModeSlide` access served by near cache does not
+// extend the far cache entry expiration.
+type TieredProvider struct {
+	// OnInvalidate hook is invoked after the entries are deleted or flushed
+	// on this instance.
+	OnInvalidate func(inv Invalidation)
+
+	name           string
+	logger         log.Loggerer
+	mgr            *Manager
+	far            string
+	nearTTL        time.Duration
+	nearMaxEntries int
+	near           *InMemoryProvider
+	mu             sync.RWMutex
+	caches         map[string]*tieredCache
+}
+
+// NewTieredProvider method returns the two-tier cache provider, far cache
+// provider is looked up from given cache manager.
+func NewTieredProvider(m *Manager) *TieredProvider {
+	return &TieredProvider{mgr: m, caches: make(map[string]*tieredCache)}
+}
+
+// Init method initializes the two-tier cache provider from the provider configuration.
+func (p *TieredProvider) Init(name string, appCfg *config.Config, logger log.Loggerer) error {
+	p.name = name
+	p.logger = logger
+	cfgPrefix := keyPrefixProviders + "." + name + "."
+
+	p.far = appCfg.StringDefault(cfgPrefix+"far", "")
+	p.nearMaxEntries = appCfg.IntDefault(cfgPrefix+"near_max_entries", 1000)
+	var err error
+	if p.nearTTL, err = parseDuration(appCfg, cfgPrefix+"near_ttl", "30s"); err != nil {
+		return err
+	}
+	if p.near != nil {
+		_ = p.near.Close()
+	}
+	p.near = new(InMemoryProvider)
+	return p.near.Init(name, appCfg, logger)
+}
+
+// Create method creates new two-tier cache with given options. Far cache is
+// created with the same options using far provider.
+func (p *TieredProvider) Create(cfg *Config) (Cache, error) {
+	if p.mgr == nil || p.near == nil {
+		return nil, fmt.Errorf("aah/cache: tiered provider '%s' is not initialized", p.name)
+	}
+	if len(p.far) == 0 || p.far == p.name {
+		return nil, fmt.Errorf("aah/cache: '%s.%s.far' is required and it cannot be itself", keyPrefixProviders, p.name)
+	}
+	fp := p.mgr.Provider(p.far)
+	if fp == nil {
+		return nil, fmt.Errorf("aah/cache: provider '%s' not exists", p.far)
+	}
+	farCfg := *cfg
+	farCfg.ProviderName = p.far
+	far, err := fp.Create(&farCfg)
+	if err != nil {
+		return nil, err
+	}
+	near, err := p.near.Create(&Config{
+		Name:          cfg.Name,
+		ProviderName:  p.name,
+		EvictionMode:  EvictionModeLRU,
+		SweepInterval: p.nearTTL,
+		MaxEntries:    p.nearMaxEntries,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &tieredCache{p: p, cfg: cfg, near: near.(*inMemoryCache), far: far}
+	p.mu.Lock()
+	p.caches[cfg.Name] = c
+	p.mu.Unlock()
+	return c, nil
+}
+
+// Invalidate method evicts the near cache entries for the invalidation
+// received from other application instance.
+func (p *TieredProvider) Invalidate(inv Invalidation) {
+	p.mu.RLock()
+	c, found := p.caches[inv.Cache]
+	p.mu.RUnlock()
+	if found {
+		c.evictNear(inv)
+	}
+}
+
+// Close method stops the near cache sweepers. aah invokes it on application
+// shutdown via `Manager.Close`. Far caches are closed by their provider.
+func (p *TieredProvider) Close() error {
+	if p.near == nil {
+		return nil
+	}
+	return p.near.Close()
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Tiered Cache
+//______________________________________________________________________________
+
+var (
+	_ Cache         = (*tieredCache)(nil)
+	_ StatsReporter = (*tieredCache)(nil)
+	_ Invalidator   = (*tieredCache)(nil)
+)
+
+// tieredCache struct is the two-tier cache. Tagged entries are not tracked
+// in near cache, so tag invalidation flushes the near cache.
+type tieredCache struct {
+	counters
+	p    *TieredProvider
+	cfg  *Config
+	near *inMemoryCache
+	far  Cache
+}
+
+// Name method returns the cache store name.
+func (c *tieredCache) Name() string {
+	return c.cfg.Name
+}
+
+// Get method returns the cached entry for given key from near cache if it
+// exists otherwise from far cache, it populates the near cache.
+func (c *tieredCache) Get(k string) interface{} {
+	if v := c.near.Get(k); v != nil {
+		c.hit()
+		return v
+	}
+	v := c.far.Get(k)
+	c.lookup(v != nil)
+	if v != nil {
+		c.setNear(k, v, 0)
+	}
+	return v
+}
+
+// GetOrPut method returns the cached entry for the given key if it exists otherwise
+// it puts the new entry into cache store and returns the value.
+func (c *tieredCache) GetOrPut(k string, v interface{}, d time.Duration) (interface{}, error) {
+	if ev := c.near.Get(k); ev != nil {
+		c.hit()
+		return ev, nil
+	}
+	ev, err := c.far.GetOrPut(k, v, d)
+	if err != nil {
+		return nil, err
+	}
+	c.miss()
+	c.setNear(k, ev, d)
+	return ev, nil
+}
+
+// Put method adds the cache entry with specified expiration. Returns error
+// if cache entry exists.
+func (c *tieredCache) Put(k string, v interface{}, d time.Duration) error {
+	if err := c.far.Put(k, v, d); err != nil {
+		return err
+	}
+	c.put()
+	c.setNear(k, v, d)
+	return nil
+}
+
+// PutWithTags method adds the cache entry with specified expiration and
+// associates it with given tags. Returns `ErrNotSupported` if far cache does
+// not support it.
+func (c *tieredCache) PutWithTags(k string, v interface{}, d time.Duration, tags ...string) error {
+	ic, err := invalidator(c.far)
+	if err != nil {
+		return err
+	}
+	if err = ic.PutWithTags(k, v, d, tags...); err != nil {
+		return err
+	}
+	c.put()
+	c.setNear(k, v, d)
+	return nil
+}
+
+// Delete method deletes the cache entry from both near and far cache.
+func (c *tieredCache) Delete(k string) error {
+	if len(k) == 0 {
+		return nil
+	}
+	return c.invalidate(Invalidation{Cache: c.cfg.Name, Key: k}, func() error {
+		return c.far.Delete(k)
+	})
+}
+
+// DeleteByTag method deletes all the cache entries associated with given tag.
+func (c *tieredCache) DeleteByTag(tag string) error {
+	ic, err := invalidator(c.far)
+	if err != nil {
+		return err
+	}
+	return c.invalidate(Invalidation{Cache: c.cfg.Name, Tag: tag}, func() error {
+		return ic.DeleteByTag(tag)
+	})
+}
+
+// DeleteByPrefix method deletes all the cache entries whose key starts
+// with given prefix.
+func (c *tieredCache) DeleteByPrefix(prefix string) error {
+	ic, err := invalidator(c.far)
+	if err != nil {
+		return err
+	}
+	return c.invalidate(Invalidation{Cache: c.cfg.Name, Prefix: prefix}, func() error {
+		return ic.DeleteByPrefix(prefix)
+	})
+}
+
+// Exists method checks given key exists in cache store and its not expried.
+func (c *tieredCache) Exists(k string) bool {
+	return c.near.Exists(k) || c.far.Exists(k)
+}
+
+// Flush methods flushes(deletes) all the cache entries from both near and
+// far cache.
+func (c *tieredCache) Flush() error {
+	return c.invalidate(Invalidation{Cache: c.cfg.Name, Flush: true}, c.far.Flush)
+}
+
+// Stats method returns the cache statistics, hits include the near cache
+// hits. Entries and bytes are not reported.
+func (c *tieredCache) Stats() Stats {
+	return c.snapshot(c.cfg.Name)
+}
+
+// Close method releases the near cache and far cache. Cache manager invokes
+// it when the cache gets removed or recreated on configuration reload.
+func (c *tieredCache) Close() error {
+	c.p.mu.Lock()
+	if c.p.caches[c.cfg.Name] == c {
+		delete(c.p.caches, c.cfg.Name)
+	}
+	c.p.mu.Unlock()
+	_ = c.near.Close()
+	if cl, ok := c.far.(io.Closer); ok {
+		return cl.Close()
+	}
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Tiered Cache unexported methods
+//______________________________________________________________________________
+
+// setNear method sets the entry into near cache, replacing the existing one.
+// Near cache duration is capped to `near_ttl`.
+func (c *tieredCache) setNear(k string, v interface{}, d time.Duration) {
+	if d <= 0 || d > c.p.nearTTL {
+		d = c.p.nearTTL
+	}
+	c.near.mu.Lock()
+	evicted, _ := c.near.set(c.near.newEntry(k, v, d))
+	c.near.mu.Unlock()
+	c.near.notify(evicted)
+}
+
+// invalidate method evicts the near cache entries before and after the far
+// cache deletion, so that concurrent read does not retain the deleted entry.
+// Then it invokes the `OnInvalidate` hook.
+func (c *tieredCache) invalidate(inv Invalidation, fn func() error) error {
+	c.evictNear(inv)
+	if err := fn(); err != nil {
+		return err
+	}
+	c.evictNear(inv)
+	if c.p.OnInvalidate != nil {
+		c.p.OnInvalidate(inv)
+	}
+	return nil
+}
+
+func (c *tieredCache) evictNear(inv Invalidation) {
+	switch {
+	case inv.Flush:
+		_ = c.near.Flush()
+	case len(inv.Key) > 0:
+		_ = c.near.Delete(inv.Key)
+	case len(inv.Prefix) > 0:
+		_ = c.near.DeleteByPrefix(inv.Prefix)
+	case len(inv.Tag) > 0:
+		// near cache entries do not hold the tags
+		_ = c.near.Flush()
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) Jeevanandam M (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/resp/resptest"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredCacheReadWriteThrough(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	mgr1, p1 := createTieredTestManager(t, srv, `near_ttl = "100ms"`)
+	mgr2, _ := createTieredTestManager(t, srv, `near_ttl = "100ms"`)
+	c1, c2 := mgr1.Cache("cache1"), mgr2.Cache("cache1")
+	assert.Equal(t, "cache1", c1.Name())
+
+	assert.Nil(t, c1.Put("key1", "value1", time.Minute))
+	assert.Equal(t, ErrEntryExists, c2.Put("key1", "value1", time.Minute))
+	assert.Equal(t, []string{"myapp:cache1:key1"}, srv.Keys())
+
+	t.Log("near cache serves the reads")
+	n := srv.CommandCount()
+	assert.Equal(t, "value1", c1.Get("key1"))
+	assert.Equal(t, n, srv.CommandCount(), "write-through populates near cache")
+	assert.Equal(t, "value1", c2.Get("key1"))
+	n = srv.CommandCount()
+	assert.Equal(t, "value1", c2.Get("key1"))
+	assert.True(t, c2.Exists("key1"))
+	assert.Equal(t, n, srv.CommandCount(), "read-through populates near cache")
+
+	v, err := c2.GetOrPut("key2", "value2", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "value2", v)
+	v, err = c1.GetOrPut("key2", "value2 again", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "value2", v)
+	assert.Nil(t, c1.Get("nokey"))
+
+	t.Log("without invalidation hook, other instance serves stale entry until near ttl")
+	assert.Nil(t, c1.Delete("key1"))
+	assert.Nil(t, c1.Get("key1"))
+	assert.Equal(t, "value1", c2.Get("key1"))
+	time.Sleep(150 * time.Millisecond)
+	assert.Nil(t, c2.Get("key1"))
+
+	s := c2.(StatsReporter).Stats()
+	assert.Equal(t, Stats{Name: "cache1", Hits: 3, Misses: 2, Entries: -1, Bytes: -1}, s)
+	assert.Nil(t, p1.Close())
+	assert.Nil(t, mgr1.Close())
+	assert.Nil(t, mgr2.Close())
+}
+
+func TestTieredCacheInvalidationHook(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	mgr1, p1 := createTieredTestManager(t, srv, "")
+	mgr2, p2 := createTieredTestManager(t, srv, "")
+	var published []Invalidation
+	p1.OnInvalidate = func(inv Invalidation) {
+		published = append(published, inv)
+		p2.Invalidate(inv)
+	}
+	c1, c2 := mgr1.Cache("cache1"), mgr2.Cache("cache1")
+	ic1, ic2 := c1.(Invalidator), c2.(Invalidator)
+
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, ic1.PutWithTags(fmt.Sprintf("product:%d", i), i, time.Minute, "tenant:a"))
+		assert.Equal(t, i, c2.Get(fmt.Sprintf("product:%d", i)))
+	}
+	assert.Nil(t, ic2.PutWithTags("order:1", "o1", time.Minute))
+	assert.Nil(t, ic2.PutWithTags("order:2", "o2", time.Minute))
+	assert.Equal(t, "o1", c1.Get("order:1"))
+
+	assert.Nil(t, c1.Delete("product:1"))
+	assert.Nil(t, c2.Get("product:1"))
+	assert.Equal(t, 2, c2.Get("product:2"))
+
+	assert.Nil(t, ic1.DeleteByTag("tenant:a"))
+	assert.Nil(t, c2.Get("product:2"))
+	assert.Nil(t, c2.Get("product:3"))
+
+	assert.Equal(t, "o2", c2.Get("order:2"))
+	assert.Nil(t, ic1.DeleteByPrefix("order:"))
+	assert.Nil(t, c2.Get("order:1"))
+	assert.Nil(t, c2.Get("order:2"))
+
+	assert.Nil(t, c2.Put("user:1", "u1", time.Minute))
+	assert.Equal(t, "u1", c1.Get("user:1"))
+	assert.Nil(t, c1.Flush())
+	assert.Nil(t, c2.Get("user:1"))
+	assert.Equal(t, 0, len(srv.Keys()))
+
+	t.Log("empty key and invalidation are no-op")
+	assert.Nil(t, c2.Put("user:2", "u2", time.Minute))
+	assert.Equal(t, "u2", c1.Get("user:2"))
+	n := srv.CommandCount()
+	assert.Nil(t, c1.Delete(""))
+	p1.Invalidate(Invalidation{Cache: "cache1"})
+	assert.Equal(t, "u2", c1.Get("user:2"))
+	assert.Equal(t, n, srv.CommandCount(), "served from near cache")
+	assert.Nil(t, c1.Delete("user:2"))
+
+	assert.Equal(t, []Invalidation{
+		{Cache: "cache1", Key: "product:1"},
+		{Cache: "cache1", Tag: "tenant:a"},
+		{Cache: "cache1", Prefix: "order:"},
+		{Cache: "cache1", Flush: true},
+		{Cache: "cache1", Key: "user:2"},
+	}, published)
+	p2.Invalidate(Invalidation{Cache: "nocache", Flush: true})
+
+	t.Log("loader is provided by cache manager")
+	v, err := c1.(Loader).GetOrLoad("key1", time.Minute, func() (interface{}, error) { return "loaded", nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "loaded", v)
+	assert.Equal(t, "loaded", c2.Get("key1"))
+
+	t.Log("recreated cache is deregistered")
+	assert.Nil(t, mgr2.CreateCache(&Config{Name: "cache1", ProviderName: "tiered"}))
+	assert.Nil(t, c2.(*loaderCache).Close())
+	_, found := p2.caches["cache1"]
+	assert.True(t, found)
+	assert.Equal(t, 1, len(p2.near.caches), "near cache of closed tiered cache is released")
+
+	assert.Nil(t, mgr1.Close())
+	assert.Nil(t, mgr2.Close())
+}
+
+func TestTieredCacheErrors(t *testing.T) {
+	mgr := NewManager()
+	p := NewTieredProvider(mgr)
+	_, err := p.Create(&Config{Name: "cache1"})
+	assert.Equal(t, errors.New("aah/cache: tiered provider '' is not initialized"), err)
+
+	assert.Nil(t, mgr.AddProvider("tiered", p))
+	assert.Nil(t, mgr.AddProvider("provider1", &dummyProvider{name: "provider1"}))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(config.NewEmpty(), l))
+	err = mgr.CreateCache(&Config{Name: "cache1", ProviderName: "tiered"})
+	assert.Equal(t, errors.New("aah/cache: 'cache.providers.tiered.far' is required and it cannot be itself"), err)
+
+	appCfg, _ := config.ParseString(`cache { providers { tiered { far = "noprovider"; near_ttl = "1m"; } } }`)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	err = mgr.CreateCache(&Config{Name: "cache1", ProviderName: "tiered"})
+	assert.Equal(t, errors.New("aah/cache: provider 'noprovider' not exists"), err)
+
+	appCfg, _ = config.ParseString(`cache { providers { tiered { far = "provider1"; } } }`)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "tiered"}))
+	ic := mgr.Cache("cache1").(Invalidator)
+	assert.Equal(t, ErrNotSupported, ic.PutWithTags("key1", "value1", time.Minute, "tag1"))
+	assert.Equal(t, ErrNotSupported, ic.DeleteByTag("tag1"))
+	assert.Equal(t, ErrNotSupported, ic.DeleteByPrefix("key"))
+
+	appCfg, _ = config.ParseString(`cache { providers { tiered { far = "provider1"; near_ttl = "1y"; } } }`)
+	err = mgr.InitProviders(appCfg, l)
+	assert.NotNil(t, err)
+	assert.Nil(t, mgr.Close())
+}
+
+func createTieredTestManager(t *testing.T, srv *resptest.Server, extraCfg string) (*Manager, *TieredProvider) {
+	appCfg, err := config.ParseString(fmt.Sprintf(`cache {
+  providers {
+    redis {
+      address = "%s"
+      key_prefix = "myapp:"
+    }
+    tiered {
+      far = "redis"
+      %s
+    }
+  }
+}`, srv.Addr, extraCfg))
+	assert.Nil(t, err)
+
+	mgr := NewManager()
+	p := NewTieredProvider(mgr)
+	assert.Nil(t, mgr.AddProvider("redis", new(RedisProvider)))
+	assert.Nil(t, mgr.AddProvider("tiered", p))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(appCfg, l))
+	assert.Nil(t, mgr.CreateCache(&Config{Name: "cache1", ProviderName: "tiered"}))
+	return mgr, p
+}
@@ -324,22 +324,48 @@ func (ctx *Context) detectContentType() string {
 	return acceptContType.String()
 }
 
-// writeCookies method writes the user provided cookies and session cookie; also
-// saves the session data into session store if its stateful.
+// writeCookies method writes the user provided cookies and session cookie or
+// session header of domain; also saves the session data into session store if
+// its stateful.
 func (ctx *Context) writeCookies() {
 	for _, c := range ctx.Reply().cookies {
 		http.SetCookie(ctx.Res, c)
 	}
 
-	if ctx.a.SessionManager().IsStateful() && ctx.a.SessionManager().IsPath(ctx.Req.Path) {
-		if ctx.subject != nil && ctx.subject.Session != nil {
-			if err := ctx.a.SessionManager().SaveSession(ctx.Res, ctx.subject.Session); err != nil {
-				ctx.Log().Error(err)
+	sessMgr := ctx.a.SessionManager()
+	if sessMgr.IsStateful() && ctx.subject != nil && ctx.subject.Session != nil {
+		var err error
+		if header := ctx.sessionHeader(); len(header) > 0 {
+			err = sessMgr.SaveSessionToHeader(ctx.Res, ctx.subject.Session, header)
+		} else if sessMgr.IsPath(ctx.Req.Path) {
+			err = sessMgr.SaveSession(ctx.Res, ctx.subject.Session)
+		}
+		if err != nil {
+			ctx.Log().Error(err)
+			if err == session.ErrSessionTooLarge {
+				// session data is lost, report it via error handler
+				ctx.Reply().redirect = false
+				ctx.Reply().InternalServerError().Error(newErrorWithData(ErrSessionTooLarge, http.StatusInternalServerError, err))
+				ctx.a.errorMgr.Handle(ctx)
 			}
 		}
 	}
 }
 
+// sessionHeader method returns the session header name of request domain if
+// domain session transport is `header` otherwise empty string.
+func (ctx *Context) sessionHeader() string {
+	domain := ctx.domain
+	if domain == nil && ctx.a.Router() != nil {
+		// session is read before the route lookup
+		domain = ctx.a.Router().Lookup(ctx.Req.Host)
+	}
+	if domain == nil {
+		return ""
+	}
+	return domain.SessionHeader
+}
+
 func (ctx *Context) writeHeaders() {
 	if ctx.a.settings.ServerHeaderEnabled {
 		ctx.Res.Header().Set(ahttp.HeaderServer, ctx.a.settings.ServerHeader)
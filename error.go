@@ -31,7 +31,10 @@ var (
 	ErrAccessDenied               = errors.New("aah: access denied")
 	ErrAuthenticationFailed       = errors.New("aah: authentication failed")
 	ErrAuthorizationFailed        = errors.New("aah: authorization failed")
+	ErrAuthThrottled              = errors.New("aah: authentication throttled")
 	ErrSessionAuthenticationInfo  = errors.New("aah: session authentication info")
+	ErrSessionTooLarge            = errors.New("aah: session too large")
+	ErrSessionRegenerateID        = errors.New("aah: unable to regenerate session id")
 	ErrUnableToGetPrincipal       = errors.New("aah: unable to get principal")
 	ErrGeneric                    = errors.New("aah: generic error")
 	ErrValidation                 = errors.New("aah: validation error")
@@ -10,6 +10,7 @@ import (
 
 	"aahframe.work/essentials"
 	"aahframe.work/internal/util"
+	"aahframe.work/security/session"
 )
 
 const (
@@ -92,6 +93,33 @@ const (
 	// EventOnPostAuth is published once the Authentication and Authorization
 	// info gets populated into Subject.
 	EventOnPostAuth = "OnPostAuth"
+
+	// EventOnAuthLockout is published when the login attempts of principal or
+	// client IP gets locked out by login throttle. Event data is
+	// `*throttle.Lockout`.
+	EventOnAuthLockout = "OnAuthLockout"
+
+	//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+	// Session events
+	//______________________________________________________________________________
+
+	// EventOnSessionCreated is published when the new session is saved for the
+	// first time. Event data is `*session.Event`.
+	EventOnSessionCreated = session.EventOnSessionCreated
+
+	// EventOnSessionDestroyed is published when the session is deleted, i.e.
+	// logout, `Session.Clear()` or revoked by principal. Event data is
+	// `*session.Event`.
+	EventOnSessionDestroyed = session.EventOnSessionDestroyed
+
+	// EventOnSessionExpired is published when the expired session is found on
+	// request or removed by session store cleanup. Event data is
+	// `*session.Event`.
+	EventOnSessionExpired = session.EventOnSessionExpired
+
+	// EventOnSessionRegenerated is published when the session ID is
+	// regenerated, i.e. on successful login. Event data is `*session.Event`.
+	EventOnSessionRegenerated = session.EventOnSessionRegenerated
 )
 
 type (
@@ -159,10 +187,48 @@ func (a *Application) OnConfigHotReload(ecb EventCallbackFunc, priority ...int)
 	})
 }
 
+// OnSessionCreated method is to subscribe to aah application `OnSessionCreated`
+// event. It is published when the new session is saved for the first time.
+func (a *Application) OnSessionCreated(ecb EventCallbackFunc, priority ...int) {
+	a.subscribeEvent(EventOnSessionCreated, ecb, false, priority)
+}
+
+// OnSessionDestroyed method is to subscribe to aah application
+// `OnSessionDestroyed` event. It is published when the session is deleted or
+// revoked.
+func (a *Application) OnSessionDestroyed(ecb EventCallbackFunc, priority ...int) {
+	a.subscribeEvent(EventOnSessionDestroyed, ecb, false, priority)
+}
+
+// OnSessionExpired method is to subscribe to aah application `OnSessionExpired`
+// event. It is published when the expired session is found on request or
+// removed by session store cleanup.
+func (a *Application) OnSessionExpired(ecb EventCallbackFunc, priority ...int) {
+	a.subscribeEvent(EventOnSessionExpired, ecb, false, priority)
+}
+
+// OnSessionRegenerated method is to subscribe to aah application
+// `OnSessionRegenerated` event. It is published when the session ID is
+// regenerated.
+func (a *Application) OnSessionRegenerated(ecb EventCallbackFunc, priority ...int) {
+	a.subscribeEvent(EventOnSessionRegenerated, ecb, false, priority)
+}
+
+// OnAuthLockout method is to subscribe to aah application `OnAuthLockout`
+// event. It is published when the login attempts gets locked out after
+// configured failures, refer to `security.login_throttle { ... }`.
+func (a *Application) OnAuthLockout(ecb EventCallbackFunc, priority ...int) {
+	a.subscribeEvent(EventOnAuthLockout, ecb, false, priority)
+}
+
 func (a *Application) subcribeAppEvent(eventName string, ecb EventCallbackFunc, priority []int) {
+	a.subscribeEvent(eventName, ecb, true, priority)
+}
+
+func (a *Application) subscribeEvent(eventName string, ecb EventCallbackFunc, callOnce bool, priority []int) {
 	a.SubscribeEvent(eventName, EventCallback{
 		Callback: ecb,
-		CallOnce: true,
+		CallOnce: callOnce,
 		priority: parsePriority(priority),
 	})
 }
@@ -19,6 +19,11 @@ func TestAppEvents(t *testing.T) {
 		EventOnPreShutdown,
 		EventOnPostShutdown,
 		EventOnConfigHotReload,
+		EventOnSessionCreated,
+		EventOnSessionDestroyed,
+		EventOnSessionExpired,
+		EventOnSessionRegenerated,
+		EventOnAuthLockout,
 	}
 
 	importPath := filepath.Join(testdataBaseDir(), "webapp1")
@@ -98,6 +103,16 @@ func addTestEvent(a *Application, eventName string, fn func(e *Event), priority
 		a.OnPostShutdown(fn, priority...)
 	case EventOnConfigHotReload:
 		a.OnConfigHotReload(fn, priority...)
+	case EventOnSessionCreated:
+		a.OnSessionCreated(fn, priority...)
+	case EventOnSessionDestroyed:
+		a.OnSessionDestroyed(fn, priority...)
+	case EventOnSessionExpired:
+		a.OnSessionExpired(fn, priority...)
+	case EventOnSessionRegenerated:
+		a.OnSessionRegenerated(fn, priority...)
+	case EventOnAuthLockout:
+		a.OnAuthLockout(fn, priority...)
 	}
 }
 
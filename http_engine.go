@@ -18,7 +18,6 @@ import (
 	"aahframe.work/internal/settings"
 	"aahframe.work/log"
 	"aahframe.work/security"
-	"aahframe.work/security/authc"
 )
 
 const (
@@ -91,9 +90,13 @@ func (e *HTTPEngine) Handle(w http.ResponseWriter, r *http.Request) {
 
 	// Load session from request if its `stateful` and subject authentication info.
 	if ctx.a.SessionManager().IsStateful() {
-		ctx.Subject().Session = ctx.a.SessionManager().GetSession(ctx.Req.Unwrap())
-		if ctx.Session().IsKeyExists(KeyViewArgAuthcInfo) {
-			populateAuthenticationInfo(ctx.Session().Get(KeyViewArgAuthcInfo).(*authc.AuthenticationInfo), ctx)
+		if header := ctx.sessionHeader(); len(header) > 0 {
+			ctx.Subject().Session = ctx.a.SessionManager().GetSessionFromHeader(ctx.Req.Unwrap(), header)
+		} else {
+			ctx.Subject().Session = ctx.a.SessionManager().GetSession(ctx.Req.Unwrap())
+		}
+		if authcInfo := sessionAuthenticationInfo(ctx.Session()); authcInfo != nil {
+			populateAuthenticationInfo(authcInfo, ctx)
 		}
 	}
 
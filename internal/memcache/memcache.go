@@ -0,0 +1,378 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package memcache is minimal client implementation of Memcached text protocol.
+// Keys are distributed across the servers using consistent hashing. It is used
+// by aah Memcache cache provider.
+package memcache
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memcache errors
+var (
+	ErrCacheMiss    = errors.New("memcache: cache miss")
+	ErrNotStored    = errors.New("memcache: item not stored")
+	ErrCASConflict  = errors.New("memcache: compare-and-swap conflict")
+	ErrNoServers    = errors.New("memcache: no servers configured")
+	ErrClientClosed = errors.New("memcache: client is closed")
+	ErrMalformedKey = errors.New("memcache: key is too long or contains invalid characters")
+)
+
+// replicas is number of points per server on the hash ring.
+const replicas = 160
+
+// Item struct represents the Memcached entry.
+type Item struct {
+	Key   string
+	Value []byte
+	Flags uint32
+
+	// Expiration of item, zero means never expires. Memcached expiry has
+	// second precision, it is rounded up.
+	Expiration time.Duration
+
+	// CAS is compare-and-swap token, populated by `Get`.
+	CAS uint64
+}
+
+// Options struct holds the Memcached client configuration.
+type Options struct {
+	Servers     []string
+	DialTimeout time.Duration
+	Timeout     time.Duration
+
+	// MaxIdle is number of idle connections retained per server.
+	MaxIdle int
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Client type and its methods
+//______________________________________________________________________________
+
+// New method returns the Memcached client for given options. Connections are
+// established on demand.
+func New(opts *Options) (*Client, error) {
+	if len(opts.Servers) == 0 {
+		return nil, ErrNoServers
+	}
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 2
+	}
+	c := &Client{opts: opts, nodes: make(map[uint32]string), idle: make(map[string][]*conn)}
+	for _, s := range opts.Servers {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(s + "-" + strconv.Itoa(i)))
+			if _, found := c.nodes[h]; !found {
+				c.nodes[h] = s
+				c.ring = append(c.ring, h)
+			}
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i] < c.ring[j] })
+	return c, nil
+}
+
+// Client struct is Memcached client, it is safe for concurrent use.
+type Client struct {
+	opts   *Options
+	ring   []uint32
+	nodes  map[uint32]string
+	mu     sync.Mutex
+	idle   map[string][]*conn
+	closed bool
+}
+
+// Server method returns the server address for the given key from the hash ring.
+func (c *Client) Server(key string) string {
+	h := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i] >= h })
+	if i == len(c.ring) {
+		i = 0
+	}
+	return c.nodes[c.ring[i]]
+}
+
+// Get method returns the item for given key, `ErrCacheMiss` if not found.
+func (c *Client) Get(key string) (*Item, error) {
+	var item *Item
+	err := c.do(key, func(cn *conn) error {
+		if _, err := fmt.Fprintf(cn.rw, "gets %s\r\n", key); err != nil {
+			return err
+		}
+		if err := cn.rw.Flush(); err != nil {
+			return err
+		}
+		var err error
+		item, err = readValue(cn.rw.Reader)
+		return err
+	})
+	if err == nil && item == nil {
+		err = ErrCacheMiss
+	}
+	return item, err
+}
+
+// Set method writes the given item unconditionally.
+func (c *Client) Set(item *Item) error {
+	return c.store("set", item)
+}
+
+// Add method writes the given item only if the key does not exist,
+// otherwise returns `ErrNotStored`.
+func (c *Client) Add(item *Item) error {
+	return c.store("add", item)
+}
+
+// Append method appends the given item value to the existing item value,
+// `ErrNotStored` if key does not exist. Item flags and expiration are ignored.
+func (c *Client) Append(item *Item) error {
+	return c.store("append", item)
+}
+
+// CompareAndSwap method writes the given item only if it is not modified since
+// it was read. It returns `ErrCASConflict` if modified and `ErrCacheMiss` if
+// key no longer exists.
+func (c *Client) CompareAndSwap(item *Item) error {
+	return c.store("cas", item)
+}
+
+// Delete method deletes the given key, `ErrCacheMiss` if not found.
+func (c *Client) Delete(key string) error {
+	return c.simple(key, fmt.Sprintf("delete %s\r\n", key), "DELETED")
+}
+
+// Touch method updates the expiry of given key, `ErrCacheMiss` if not found.
+func (c *Client) Touch(key string, d time.Duration) error {
+	return c.simple(key, fmt.Sprintf("touch %s %d\r\n", key, expiry(d)), "TOUCHED")
+}
+
+// Increment method increments the value of given key by delta and returns
+// the new value, `ErrCacheMiss` if not found.
+func (c *Client) Increment(key string, delta uint64) (uint64, error) {
+	var n uint64
+	err := c.do(key, func(cn *conn) error {
+		line, err := cn.cmd(fmt.Sprintf("incr %s %d\r\n", key, delta))
+		if err != nil {
+			return err
+		}
+		if line == "NOT_FOUND" {
+			return ErrCacheMiss
+		}
+		n, err = strconv.ParseUint(line, 10, 64)
+		return err
+	})
+	return n, err
+}
+
+// Close method closes all the idle connections and marks client as closed.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	idle := c.idle
+	c.idle = make(map[string][]*conn)
+	c.closed = true
+	c.mu.Unlock()
+	for _, conns := range idle {
+		for _, cn := range conns {
+			_ = cn.Close()
+		}
+	}
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Client unexported methods
+//______________________________________________________________________________
+
+func (c *Client) store(verb string, item *Item) error {
+	return c.do(item.Key, func(cn *conn) error {
+		cmd := fmt.Sprintf("%s %s %d %d %d", verb, item.Key, item.Flags, expiry(item.Expiration), len(item.Value))
+		if verb == "cas" {
+			cmd += " " + strconv.FormatUint(item.CAS, 10)
+		}
+		buf := make([]byte, 0, len(cmd)+len(item.Value)+4)
+		buf = append(buf, cmd...)
+		buf = append(buf, "\r\n"...)
+		buf = append(buf, item.Value...)
+		buf = append(buf, "\r\n"...)
+		line, err := cn.cmd(string(buf))
+		if err != nil {
+			return err
+		}
+		switch line {
+		case "STORED":
+			return nil
+		case "NOT_STORED":
+			return ErrNotStored
+		case "EXISTS":
+			return ErrCASConflict
+		case "NOT_FOUND":
+			return ErrCacheMiss
+		}
+		return fmt.Errorf("memcache: unexpected response '%s'", line)
+	})
+}
+
+func (c *Client) simple(key, cmd, ok string) error {
+	return c.do(key, func(cn *conn) error {
+		line, err := cn.cmd(cmd)
+		if err != nil {
+			return err
+		}
+		switch line {
+		case ok:
+			return nil
+		case "NOT_FOUND":
+			return ErrCacheMiss
+		}
+		return fmt.Errorf("memcache: unexpected response '%s'", line)
+	})
+}
+
+// do method picks the server for key and executes fn on pooled connection.
+func (c *Client) do(key string, fn func(cn *conn) error) error {
+	if !validKey(key) {
+		return ErrMalformedKey
+	}
+	addr := c.Server(key)
+	cn, err := c.get(addr)
+	if err != nil {
+		return err
+	}
+	if c.opts.Timeout > 0 {
+		_ = cn.SetDeadline(time.Now().Add(c.opts.Timeout))
+	}
+	if err = fn(cn); err != nil && !isResumable(err) {
+		_ = cn.Close()
+		return err
+	}
+	c.put(addr, cn)
+	return err
+}
+
+func (c *Client) get(addr string) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClientClosed
+	}
+	if conns := c.idle[addr]; len(conns) > 0 {
+		cn := conns[len(conns)-1]
+		c.idle[addr] = conns[:len(conns)-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+
+	nc, err := net.DialTimeout("tcp", addr, c.opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: nc, rw: bufio.NewReadWriter(bufio.NewReader(nc), bufio.NewWriter(nc))}, nil
+}
+
+func (c *Client) put(addr string, cn *conn) {
+	c.mu.Lock()
+	if c.closed || len(c.idle[addr]) >= c.opts.MaxIdle {
+		c.mu.Unlock()
+		_ = cn.Close()
+		return
+	}
+	c.idle[addr] = append(c.idle[addr], cn)
+	c.mu.Unlock()
+}
+
+type conn struct {
+	net.Conn
+	rw *bufio.ReadWriter
+}
+
+// cmd method writes the command and returns the response line.
+func (cn *conn) cmd(cmd string) (string, error) {
+	if _, err := cn.rw.WriteString(cmd); err != nil {
+		return "", err
+	}
+	if err := cn.rw.Flush(); err != nil {
+		return "", err
+	}
+	line, err := cn.rw.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if strings.HasPrefix(line, "SERVER_ERROR") || strings.HasPrefix(line, "CLIENT_ERROR") || line == "ERROR" {
+		return "", fmt.Errorf("memcache: %s", line)
+	}
+	return line, nil
+}
+
+// readValue method reads the `gets` response, nil item for cache miss.
+func readValue(r *bufio.Reader) (*Item, error) {
+	var item *Item
+	for {
+		line, err := r.ReadSlice('\n')
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Equal(line, []byte("END\r\n")) {
+			return item, nil
+		}
+		item = &Item{}
+		var size int
+		if _, err = fmt.Sscanf(string(line), "VALUE %s %d %d %d", &item.Key, &item.Flags, &size, &item.CAS); err != nil {
+			return nil, fmt.Errorf("memcache: unexpected response '%s'", strings.TrimSpace(string(line)))
+		}
+		item.Value = make([]byte, size+2)
+		if _, err = io.ReadFull(r, item.Value); err != nil {
+			return nil, err
+		}
+		item.Value = item.Value[:size]
+	}
+}
+
+// expiry method converts the duration to Memcached expiration time, beyond
+// 30 days it has to be unix timestamp.
+func expiry(d time.Duration) int64 {
+	if d <= 0 {
+		return 0
+	}
+	secs := int64((d + time.Second - 1) / time.Second)
+	if secs > 30*24*60*60 {
+		return time.Now().Unix() + secs
+	}
+	return secs
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > 250 {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// isResumable method reports whether connection can be reused after error.
+func isResumable(err error) bool {
+	switch err {
+	case ErrCacheMiss, ErrNotStored, ErrCASConflict:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package memcache_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"aahframe.work/internal/memcache"
+	"aahframe.work/internal/memcache/memcachetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemcacheClient(t *testing.T) {
+	srv := memcachetest.NewServer()
+	defer srv.Close()
+
+	c, err := memcache.New(&memcache.Options{Servers: []string{srv.Addr}, Timeout: time.Second})
+	assert.Nil(t, err)
+
+	_, err = c.Get("key1")
+	assert.Equal(t, memcache.ErrCacheMiss, err)
+
+	assert.Nil(t, c.Add(&memcache.Item{Key: "key1", Value: []byte("value1"), Flags: 5, Expiration: time.Minute}))
+	assert.Equal(t, memcache.ErrNotStored, c.Add(&memcache.Item{Key: "key1", Value: []byte("value2")}))
+
+	item, err := c.Get("key1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value1"), item.Value)
+	assert.Equal(t, uint32(5), item.Flags)
+	assert.True(t, item.CAS > 0)
+
+	t.Log("compare-and-swap")
+	stale := *item
+	item.Value = []byte("value2")
+	assert.Nil(t, c.CompareAndSwap(item))
+	stale.Value = []byte("value3")
+	assert.Equal(t, memcache.ErrCASConflict, c.CompareAndSwap(&stale))
+	item, _ = c.Get("key1")
+	assert.Equal(t, []byte("value2"), item.Value)
+
+	t.Log("expiration and touch")
+	srv.Advance(50 * time.Second)
+	assert.Nil(t, c.Touch("key1", time.Minute))
+	srv.Advance(50 * time.Second)
+	_, err = c.Get("key1")
+	assert.Nil(t, err)
+	srv.Advance(11 * time.Second)
+	_, err = c.Get("key1")
+	assert.Equal(t, memcache.ErrCacheMiss, err)
+	assert.Equal(t, memcache.ErrCacheMiss, c.Touch("key1", time.Minute))
+	assert.Equal(t, memcache.ErrCacheMiss, c.CompareAndSwap(item))
+
+	t.Log("set, increment and delete")
+	assert.Nil(t, c.Set(&memcache.Item{Key: "counter", Value: []byte("10")}))
+	n, err := c.Increment("counter", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(15), n)
+	_, err = c.Increment("nocounter", 1)
+	assert.Equal(t, memcache.ErrCacheMiss, err)
+	assert.Nil(t, c.Delete("counter"))
+	assert.Equal(t, memcache.ErrCacheMiss, c.Delete("counter"))
+
+	t.Log("append")
+	assert.Equal(t, memcache.ErrNotStored, c.Append(&memcache.Item{Key: "list", Value: []byte("a")}))
+	assert.Nil(t, c.Add(&memcache.Item{Key: "list", Value: []byte("a")}))
+	assert.Nil(t, c.Append(&memcache.Item{Key: "list", Value: []byte("b")}))
+	item, _ = c.Get("list")
+	assert.Equal(t, []byte("ab"), item.Value)
+
+	t.Log("malformed keys")
+	assert.Equal(t, memcache.ErrMalformedKey, c.Set(&memcache.Item{Key: "key with space"}))
+	_, err = c.Get(strings.Repeat("k", 251))
+	assert.Equal(t, memcache.ErrMalformedKey, err)
+
+	assert.Nil(t, c.Close())
+	_, err = c.Get("key1")
+	assert.Equal(t, memcache.ErrClientClosed, err)
+}
+
+func TestMemcacheConsistentHashing(t *testing.T) {
+	_, err := memcache.New(&memcache.Options{})
+	assert.Equal(t, memcache.ErrNoServers, err)
+
+	servers := []string{"10.0.0.1:11211", "10.0.0.2:11211", "10.0.0.3:11211"}
+	c3, _ := memcache.New(&memcache.Options{Servers: servers})
+	c4, _ := memcache.New(&memcache.Options{Servers: append(servers, "10.0.0.4:11211")})
+
+	dist := make(map[string]int)
+	moved := 0
+	for i := 0; i < 3000; i++ {
+		k := fmt.Sprintf("key%d", i)
+		s := c3.Server(k)
+		assert.Equal(t, s, c3.Server(k), "same key, same server")
+		dist[s]++
+		if s4 := c4.Server(k); s4 != s {
+			assert.Equal(t, "10.0.0.4:11211", s4, "keys move only to new server")
+			moved++
+		}
+	}
+	for _, s := range servers {
+		assert.True(t, dist[s] > 600, "keys are spread across servers")
+	}
+	assert.True(t, moved > 400 && moved < 1200, "about quarter of keys move")
+}
+
+func TestMemcacheMultipleServers(t *testing.T) {
+	srv1, srv2 := memcachetest.NewServer(), memcachetest.NewServer()
+	defer srv1.Close()
+	defer srv2.Close()
+
+	c, _ := memcache.New(&memcache.Options{Servers: []string{srv1.Addr, srv2.Addr}})
+	for i := 0; i < 50; i++ {
+		assert.Nil(t, c.Set(&memcache.Item{Key: fmt.Sprintf("key%d", i), Value: []byte("v")}))
+	}
+	assert.True(t, len(srv1.Keys()) > 0)
+	assert.True(t, len(srv2.Keys()) > 0)
+	assert.Equal(t, 50, len(srv1.Keys())+len(srv2.Keys()))
+	assert.Nil(t, c.Close())
+}
@@ -0,0 +1,283 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package memcachetest provides in-process fake Memcached server, it implements
+// the subset of text protocol commands used by aah. It is meant for tests only.
+package memcachetest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewServer method starts the fake Memcached server on loopback address.
+// Caller should call `Close` when finished.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("memcachetest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Addr:  l.Addr().String(),
+		l:     l,
+		data:  make(map[string]*item),
+		conns: make(map[net.Conn]bool),
+	}
+	go s.serve()
+	return s
+}
+
+// Server struct is in-process fake Memcached server. Server has its own clock,
+// it can be moved forward via `Advance` to test the expiration.
+type Server struct {
+	// Addr is the server address in the form of `host:port`.
+	Addr string
+
+	mu     sync.Mutex
+	l      net.Listener
+	data   map[string]*item
+	conns  map[net.Conn]bool
+	offset time.Duration
+	cas    uint64
+}
+
+type item struct {
+	v     []byte
+	flags uint32
+	exp   time.Time
+	cas   uint64
+}
+
+// Close method stops the server and closes all the client connections.
+func (s *Server) Close() {
+	_ = s.l.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+}
+
+// Advance method moves the server clock forward by given duration.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	s.offset += d
+	s.mu.Unlock()
+}
+
+// Keys method returns the live key names in sorted order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.data {
+		if s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get method returns the raw value of key, nil if not exists.
+func (s *Server) Get(key string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if it := s.lookup(key); it != nil {
+		return append([]byte(nil), it.v...)
+	}
+	return nil
+}
+
+// Set method stores the raw value for key, it is handy to simulate the
+// entries written by other clients.
+func (s *Server) Set(key string, value []byte) {
+	s.mu.Lock()
+	s.cas++
+	s.data[key] = &item{v: value, cas: s.cas}
+	s.mu.Unlock()
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+	rw := bufio.NewReadWriter(bufio.NewReader(c), bufio.NewWriter(c))
+	for {
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			_, _ = rw.WriteString("ERROR\r\n")
+		} else if err = s.exec(rw, args); err != nil {
+			return
+		}
+		if err = rw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(rw *bufio.ReadWriter, args []string) error {
+	switch args[0] {
+	case "get", "gets":
+		s.mu.Lock()
+		for _, k := range args[1:] {
+			if it := s.lookup(k); it != nil {
+				if args[0] == "gets" {
+					_, _ = fmt.Fprintf(rw, "VALUE %s %d %d %d\r\n", k, it.flags, len(it.v), it.cas)
+				} else {
+					_, _ = fmt.Fprintf(rw, "VALUE %s %d %d\r\n", k, it.flags, len(it.v))
+				}
+				_, _ = rw.Write(it.v)
+				_, _ = rw.WriteString("\r\n")
+			}
+		}
+		s.mu.Unlock()
+		_, _ = rw.WriteString("END\r\n")
+	case "set", "add", "cas", "append":
+		return s.store(rw, args)
+	case "delete":
+		s.mu.Lock()
+		if s.lookup(args[1]) != nil {
+			delete(s.data, args[1])
+			_, _ = rw.WriteString("DELETED\r\n")
+		} else {
+			_, _ = rw.WriteString("NOT_FOUND\r\n")
+		}
+		s.mu.Unlock()
+	case "touch":
+		s.mu.Lock()
+		if it := s.lookup(args[1]); it != nil {
+			it.exp = s.expiry(args[2])
+			_, _ = rw.WriteString("TOUCHED\r\n")
+		} else {
+			_, _ = rw.WriteString("NOT_FOUND\r\n")
+		}
+		s.mu.Unlock()
+	case "incr":
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		it := s.lookup(args[1])
+		if it == nil {
+			_, _ = rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		n, err := strconv.ParseUint(string(it.v), 10, 64)
+		delta, err2 := strconv.ParseUint(args[2], 10, 64)
+		if err != nil || err2 != nil {
+			_, _ = rw.WriteString("CLIENT_ERROR cannot increment or decrement non-numeric value\r\n")
+			return nil
+		}
+		n += delta
+		s.cas++
+		it.v, it.cas = []byte(strconv.FormatUint(n, 10)), s.cas
+		_, _ = fmt.Fprintf(rw, "%d\r\n", n)
+	default:
+		_, _ = rw.WriteString("ERROR\r\n")
+	}
+	return nil
+}
+
+func (s *Server) store(rw *bufio.ReadWriter, args []string) error {
+	if len(args) < 5 || (args[0] == "cas" && len(args) < 6) {
+		_, _ = rw.WriteString("ERROR\r\n")
+		return nil
+	}
+	flags, _ := strconv.ParseUint(args[2], 10, 32)
+	size, err := strconv.Atoi(args[4])
+	if err != nil {
+		_, _ = rw.WriteString("CLIENT_ERROR bad data chunk\r\n")
+		return nil
+	}
+	data := make([]byte, size+2)
+	if _, err = io.ReadFull(rw, data); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	existing := s.lookup(args[1])
+	switch args[0] {
+	case "add":
+		if existing != nil {
+			_, _ = rw.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+	case "append":
+		if existing == nil {
+			_, _ = rw.WriteString("NOT_STORED\r\n")
+			return nil
+		}
+		s.cas++
+		existing.v, existing.cas = append(existing.v, data[:size]...), s.cas
+		_, _ = rw.WriteString("STORED\r\n")
+		return nil
+	case "cas":
+		if existing == nil {
+			_, _ = rw.WriteString("NOT_FOUND\r\n")
+			return nil
+		}
+		if strconv.FormatUint(existing.cas, 10) != args[5] {
+			_, _ = rw.WriteString("EXISTS\r\n")
+			return nil
+		}
+	}
+	s.cas++
+	s.data[args[1]] = &item{v: data[:size], flags: uint32(flags), exp: s.expiry(args[3]), cas: s.cas}
+	_, _ = rw.WriteString("STORED\r\n")
+	return nil
+}
+
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+func (s *Server) expiry(v string) time.Time {
+	n, _ := strconv.ParseInt(v, 10, 64)
+	switch {
+	case n <= 0:
+		return time.Time{}
+	case n > 30*24*60*60:
+		return time.Unix(n, 0)
+	}
+	return s.now().Add(time.Duration(n) * time.Second)
+}
+
+func (s *Server) lookup(k string) *item {
+	it, found := s.data[k]
+	if !found {
+		return nil
+	}
+	if !it.exp.IsZero() && !s.now().Before(it.exp) {
+		delete(s.data, k)
+		return nil
+	}
+	return it
+}
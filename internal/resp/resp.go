@@ -0,0 +1,281 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package resp is minimal client implementation of Redis Serialization
+// Protocol (RESP) with connection pooling. It is used by aah Redis cache
+// provider and session store.
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrPoolClosed returned when connection requested from closed pool.
+var ErrPoolClosed = errors.New("resp: pool is closed")
+
+// Error type represents the error reply from the server.
+type Error string
+
+// Error method returns the server error message.
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Options struct holds the connection details of RESP server.
+type Options struct {
+	Address      string
+	Password     string
+	DB           int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MaxIdle is number of idle connections retained in the pool.
+	MaxIdle int
+
+	// IdleTimeout closes the idle connections after given duration,
+	// zero means idle connections are not closed.
+	IdleTimeout time.Duration
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Pool type and its methods
+//______________________________________________________________________________
+
+// NewPool method returns new connection pool for given options. Connections
+// are established on demand.
+func NewPool(opts *Options) *Pool {
+	if opts.MaxIdle <= 0 {
+		opts.MaxIdle = 2
+	}
+	return &Pool{opts: opts}
+}
+
+// Pool struct is RESP connection pool, it is safe for concurrent use.
+type Pool struct {
+	opts   *Options
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// Do method sends the command to the server and returns the reply. Reply values
+// are `string` for simple string, `int64` for integer, `[]byte` for bulk
+// string, `[]interface{}` for array and nil for null bulk string or array.
+// Error reply is returned as `resp.Error`.
+func (p *Pool) Do(args ...interface{}) (interface{}, error) {
+	c, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	reply, err := c.do(args...)
+	if _, ok := err.(Error); err != nil && !ok {
+		_ = c.Close()
+		return nil, err
+	}
+	p.put(c)
+	return reply, err
+}
+
+// Close method closes all the idle connections and marks pool as closed.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+	for _, c := range idle {
+		_ = c.Close()
+	}
+	return nil
+}
+
+func (p *Pool) get() (*conn, error) {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrPoolClosed
+	}
+	for len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if p.opts.IdleTimeout > 0 && time.Since(c.lastUsed) > p.opts.IdleTimeout {
+			_ = c.Close()
+			continue
+		}
+		p.mu.Unlock()
+		return c, nil
+	}
+	p.mu.Unlock()
+	return p.dial()
+}
+
+func (p *Pool) put(c *conn) {
+	c.lastUsed = time.Now()
+	p.mu.Lock()
+	if p.closed || len(p.idle) >= p.opts.MaxIdle {
+		p.mu.Unlock()
+		_ = c.Close()
+		return
+	}
+	p.idle = append(p.idle, c)
+	p.mu.Unlock()
+}
+
+func (p *Pool) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", p.opts.Address, p.opts.DialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		Conn:         nc,
+		r:            bufio.NewReader(nc),
+		w:            bufio.NewWriter(nc),
+		readTimeout:  p.opts.ReadTimeout,
+		writeTimeout: p.opts.WriteTimeout,
+	}
+	if len(p.opts.Password) > 0 {
+		if _, err = c.do("AUTH", p.opts.Password); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	if p.opts.DB > 0 {
+		if _, err = c.do("SELECT", p.opts.DB); err != nil {
+			_ = c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Connection type and its methods
+//______________________________________________________________________________
+
+type conn struct {
+	net.Conn
+	r            *bufio.Reader
+	w            *bufio.Writer
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	lastUsed     time.Time
+}
+
+func (c *conn) do(args ...interface{}) (interface{}, error) {
+	if c.writeTimeout > 0 {
+		_ = c.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	if err := WriteCommand(c.w, args...); err != nil {
+		return nil, err
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	if c.readTimeout > 0 {
+		_ = c.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	return ReadReply(c.r)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Protocol read and write methods
+//______________________________________________________________________________
+
+// WriteCommand method writes the command as RESP array of bulk strings.
+// Supported argument types are `string`, `[]byte`, `int`, `int64` and
+// `time.Duration` (written in milliseconds).
+func WriteCommand(w io.Writer, args ...interface{}) error {
+	buf := make([]byte, 0, 64)
+	buf = append(buf, '*')
+	buf = strconv.AppendInt(buf, int64(len(args)), 10)
+	buf = append(buf, '\r', '\n')
+	for _, arg := range args {
+		var b []byte
+		switch v := arg.(type) {
+		case string:
+			b = []byte(v)
+		case []byte:
+			b = v
+		case int:
+			b = strconv.AppendInt(nil, int64(v), 10)
+		case int64:
+			b = strconv.AppendInt(nil, v, 10)
+		case time.Duration:
+			b = strconv.AppendInt(nil, int64(v/time.Millisecond), 10)
+		default:
+			return fmt.Errorf("resp: unsupported argument type %T", arg)
+		}
+		buf = append(buf, '$')
+		buf = strconv.AppendInt(buf, int64(len(b)), 10)
+		buf = append(buf, '\r', '\n')
+		buf = append(buf, b...)
+		buf = append(buf, '\r', '\n')
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// ReadReply method reads one RESP reply from the reader.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return string(line[1:]), nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(string(line[1:]), 10, 64)
+	case '$':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(string(line[1:]))
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		arr := make([]interface{}, n)
+		for i := range arr {
+			if arr[i], err = ReadReply(r); err != nil {
+				if _, ok := err.(Error); !ok {
+					return nil, err
+				}
+				arr[i] = err
+			}
+		}
+		return arr, nil
+	}
+	return nil, fmt.Errorf("resp: unexpected reply type '%c'", line[0])
+}
+
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadSlice('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return nil, errors.New("resp: invalid line terminator")
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,105 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package resp_test
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/internal/resp"
+	"aahframe.work/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRESPWriteCommand(t *testing.T) {
+	buf := new(bytes.Buffer)
+	err := resp.WriteCommand(buf, "SET", "key1", []byte("value1"), "PX", 1500*time.Millisecond, 10, int64(20))
+	assert.Nil(t, err)
+	assert.Equal(t, "*7\r\n$3\r\nSET\r\n$4\r\nkey1\r\n$6\r\nvalue1\r\n$2\r\nPX\r\n$4\r\n1500\r\n$2\r\n10\r\n$2\r\n20\r\n", buf.String())
+
+	err = resp.WriteCommand(buf, "SET", 1.5)
+	assert.Equal(t, "resp: unsupported argument type float64", err.Error())
+}
+
+func TestRESPReadReply(t *testing.T) {
+	testcases := []struct {
+		in     string
+		result interface{}
+		err    string
+	}{
+		{in: "+OK\r\n", result: "OK"},
+		{in: "-ERR bad\r\n", err: "ERR bad"},
+		{in: ":42\r\n", result: int64(42)},
+		{in: "$5\r\nhello\r\n", result: []byte("hello")},
+		{in: "$-1\r\n", result: nil},
+		{in: "*-1\r\n", result: nil},
+		{in: "*2\r\n$1\r\na\r\n:1\r\n", result: []interface{}{[]byte("a"), int64(1)}},
+		{in: "OK\r\n", err: "resp: unexpected reply type 'O'"},
+		{in: "+OK\n", err: "resp: invalid line terminator"},
+	}
+	for _, tc := range testcases {
+		v, err := resp.ReadReply(bufio.NewReader(strings.NewReader(tc.in)))
+		if len(tc.err) > 0 {
+			assert.NotNil(t, err, tc.in)
+			if err != nil {
+				assert.Equal(t, tc.err, err.Error())
+			}
+			continue
+		}
+		assert.Nil(t, err, tc.in)
+		if tc.result == nil {
+			assert.Nil(t, v, tc.in)
+		} else {
+			assert.Equal(t, tc.result, v, tc.in)
+		}
+	}
+}
+
+func TestRESPPool(t *testing.T) {
+	srv := resptest.NewServer()
+	srv.SetPassword("s3cret")
+	defer srv.Close()
+
+	p := resp.NewPool(&resp.Options{Address: srv.Addr, Password: "wrong", DB: 1})
+	_, err := p.Do("PING")
+	assert.Equal(t, resp.Error("ERR invalid password"), err)
+
+	p = resp.NewPool(&resp.Options{Address: srv.Addr, Password: "s3cret", DB: 1, MaxIdle: 2, IdleTimeout: time.Minute})
+	reply, err := p.Do("SET", "key1", "value1", "PX", time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", reply)
+
+	reply, err = p.Do("GET", "key1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value1"), reply)
+
+	_, err = p.Do("NOSUCHCMD")
+	assert.Equal(t, resp.Error("ERR unknown command 'NOSUCHCMD'"), err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				_, er := p.Do("EXISTS", "key1")
+				assert.Nil(t, er)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Nil(t, p.Close())
+	_, err = p.Do("PING")
+	assert.Equal(t, resp.ErrPoolClosed, err)
+
+	p = resp.NewPool(&resp.Options{Address: "127.0.0.1:1", DialTimeout: time.Second})
+	_, err = p.Do("PING")
+	assert.NotNil(t, err)
+}
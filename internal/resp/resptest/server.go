@@ -0,0 +1,382 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package resptest provides in-process RESP server, it implements the subset
+// of Redis commands used by aah. It is meant for tests only.
+package resptest
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"aahframe.work/internal/resp"
+)
+
+// NewServer method starts the RESP server on loopback address. Caller should
+// call `Close` when finished.
+func NewServer() *Server {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("resptest: failed to listen on a port: %v", err))
+	}
+	s := &Server{
+		Addr:  l.Addr().String(),
+		l:     l,
+		data:  make(map[string]*item),
+		conns: make(map[net.Conn]bool),
+	}
+	go s.serve()
+	return s
+}
+
+// Server struct is in-process RESP server.
+type Server struct {
+	// Addr is the server address in the form of `host:port`.
+	Addr string
+
+	mu       sync.Mutex
+	password string
+	l        net.Listener
+	data     map[string]*item
+	conns    map[net.Conn]bool
+	cmds     int
+}
+
+type item struct {
+	v   []byte
+	set map[string]bool
+	exp time.Time
+}
+
+// SetPassword method sets the server password, new connections have to be
+// authenticated via `AUTH`.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	s.password = password
+	s.mu.Unlock()
+}
+
+// Close method stops the server and closes all the client connections.
+func (s *Server) Close() {
+	_ = s.l.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		_ = c.Close()
+	}
+	s.mu.Unlock()
+}
+
+// CommandCount method returns the number of commands processed by the server.
+func (s *Server) CommandCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cmds
+}
+
+// Keys method returns the live key names in sorted order.
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for k := range s.data {
+		if s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TTL method returns the remaining time to live of the key, -1 if key has
+// no expiry and -2 if key does not exist.
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ttl(key)
+}
+
+func (s *Server) serve() {
+	for {
+		c, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = true
+		s.mu.Unlock()
+		go s.handle(c)
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, c)
+		s.mu.Unlock()
+		_ = c.Close()
+	}()
+	r := bufio.NewReader(c)
+	w := bufio.NewWriter(c)
+	s.mu.Lock()
+	password := s.password
+	s.mu.Unlock()
+	authed := len(password) == 0
+	for {
+		v, err := resp.ReadReply(r)
+		if err != nil {
+			return
+		}
+		arr, ok := v.([]interface{})
+		if !ok || len(arr) == 0 {
+			writeError(w, "ERR protocol error")
+			_ = w.Flush()
+			return
+		}
+		args := make([]string, len(arr))
+		for i, a := range arr {
+			b, _ := a.([]byte)
+			args[i] = string(b)
+		}
+		cmd := strings.ToUpper(args[0])
+		switch {
+		case cmd == "AUTH":
+			if len(args) == 2 && args[1] == password {
+				authed = true
+				writeSimple(w, "OK")
+			} else {
+				writeError(w, "ERR invalid password")
+			}
+		case !authed:
+			writeError(w, "NOAUTH Authentication required.")
+		default:
+			s.mu.Lock()
+			s.cmds++
+			s.exec(w, cmd, args[1:])
+			s.mu.Unlock()
+		}
+		if err = w.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(w *bufio.Writer, cmd string, args []string) {
+	switch cmd {
+	case "PING":
+		writeSimple(w, "PONG")
+	case "SELECT", "FLUSHDB":
+		if cmd == "FLUSHDB" {
+			s.data = make(map[string]*item)
+		}
+		writeSimple(w, "OK")
+	case "GET":
+		if it := s.lookup(args[0]); it != nil && it.set == nil {
+			writeBulk(w, it.v)
+		} else {
+			writeBulk(w, nil)
+		}
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		var n int64
+		for _, k := range args {
+			if s.lookup(k) != nil {
+				delete(s.data, k)
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "EXISTS":
+		var n int64
+		for _, k := range args {
+			if s.lookup(k) != nil {
+				n++
+			}
+		}
+		writeInt(w, n)
+	case "PEXPIRE", "EXPIRE":
+		it := s.lookup(args[0])
+		d, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			writeError(w, "ERR value is not an integer or out of range")
+			return
+		}
+		if it == nil {
+			writeInt(w, 0)
+			return
+		}
+		if cmd == "EXPIRE" {
+			d *= 1000
+		}
+		it.exp = time.Now().Add(time.Duration(d) * time.Millisecond)
+		writeInt(w, 1)
+	case "PTTL":
+		d := s.ttl(args[0])
+		if d > 0 {
+			d /= time.Millisecond
+		}
+		writeInt(w, int64(d))
+	case "KEYS":
+		writeArray(w, s.match(args[0]))
+	case "SCAN":
+		// entire keyspace is returned in one iteration
+		pattern := "*"
+		for i := 1; i+1 < len(args); i += 2 {
+			if strings.ToUpper(args[i]) == "MATCH" {
+				pattern = args[i+1]
+			}
+		}
+		_, _ = w.WriteString("*2\r\n")
+		writeBulk(w, []byte("0"))
+		writeArray(w, s.match(pattern))
+	case "SADD", "SREM":
+		it := s.lookup(args[0])
+		if it == nil {
+			it = &item{set: make(map[string]bool)}
+			s.data[args[0]] = it
+		}
+		var n int64
+		for _, m := range args[1:] {
+			if it.set[m] != (cmd == "SADD") {
+				n++
+			}
+			if cmd == "SADD" {
+				it.set[m] = true
+			} else {
+				delete(it.set, m)
+			}
+		}
+		if len(it.set) == 0 {
+			delete(s.data, args[0])
+		}
+		writeInt(w, n)
+	case "SMEMBERS":
+		var members []string
+		if it := s.lookup(args[0]); it != nil {
+			for m := range it.set {
+				members = append(members, m)
+			}
+		}
+		sort.Strings(members)
+		writeArray(w, members)
+	default:
+		writeError(w, "ERR unknown command '"+cmd+"'")
+	}
+}
+
+func (s *Server) set(w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		writeError(w, "ERR wrong number of arguments for 'set' command")
+		return
+	}
+	var nx, xx bool
+	var d time.Duration
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "PX", "EX":
+			if i+1 >= len(args) {
+				writeError(w, "ERR syntax error")
+				return
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				writeError(w, "ERR invalid expire time in set")
+				return
+			}
+			d = time.Duration(n) * time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				d = time.Duration(n) * time.Second
+			}
+			i++
+		default:
+			writeError(w, "ERR syntax error")
+			return
+		}
+	}
+	exists := s.lookup(args[0]) != nil
+	if (nx && exists) || (xx && !exists) {
+		writeBulk(w, nil)
+		return
+	}
+	it := &item{v: []byte(args[1])}
+	if d > 0 {
+		it.exp = time.Now().Add(d)
+	}
+	s.data[args[0]] = it
+	writeSimple(w, "OK")
+}
+
+func (s *Server) lookup(k string) *item {
+	it, found := s.data[k]
+	if !found {
+		return nil
+	}
+	if !it.exp.IsZero() && time.Now().After(it.exp) {
+		delete(s.data, k)
+		return nil
+	}
+	return it
+}
+
+func (s *Server) ttl(k string) time.Duration {
+	it := s.lookup(k)
+	if it == nil {
+		return -2
+	}
+	if it.exp.IsZero() {
+		return -1
+	}
+	return time.Until(it.exp)
+}
+
+func (s *Server) match(pattern string) []string {
+	var keys []string
+	for k := range s.data {
+		if ok, _ := path.Match(pattern, k); ok && s.lookup(k) != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeSimple(w *bufio.Writer, v string) {
+	_, _ = w.WriteString("+" + v + "\r\n")
+}
+
+func writeError(w *bufio.Writer, v string) {
+	_, _ = w.WriteString("-" + v + "\r\n")
+}
+
+func writeInt(w *bufio.Writer, n int64) {
+	_, _ = w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, b []byte) {
+	if b == nil {
+		_, _ = w.WriteString("$-1\r\n")
+		return
+	}
+	_, _ = w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	_, _ = w.Write(b)
+	_, _ = w.WriteString("\r\n")
+}
+
+func writeArray(w *bufio.Writer, vals []string) {
+	_, _ = w.WriteString("*" + strconv.Itoa(len(vals)) + "\r\n")
+	for _, v := range vals {
+		writeBulk(w, []byte(v))
+	}
+}
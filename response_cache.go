@@ -0,0 +1,304 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package aah
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/cache"
+	"aahframe.work/essentials"
+	"aahframe.work/router"
+)
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Response Cache middleware
+//______________________________________________________________________________
+
+// ResponseCacheMiddleware serves the rendered reply (status, headers and body)
+// from the cache store configured on the route via `cache { ... }` block in
+// the `routes.conf`. On cache miss it renders the reply and stores it. Cache
+// key comprises the domain, route name, path parameters and the configured
+// query parameters and request headers. Response header `Vary` is honored.
+//
+// Response is neither served from nor stored into cache, if:
+//   - HTTP method is other than `GET` and `HEAD`
+//   - Request has `Cache-Control: no-store` or `Authorization` header
+//   - Subject is authenticated or it has session values
+//
+// Request `Cache-Control: no-cache` skips the serving and refreshes the cache.
+// On cache miss the middleware writes the reply and stores the bytes written
+// on the wire. Reply is stored only for HTTP status `200` and it is not stored,
+// if reply has cookies or binary/file, response `Cache-Control` is `no-store`,
+// `no-cache` or `private`, response `Vary` is `*` or HTML reply on the route
+// with Anti-CSRF check enabled.
+//
+// Register it after `AuthcAuthzMiddleware` and before `ActionMiddleware`.
+func ResponseCacheMiddleware(ctx *Context, m *Middleware) {
+	rc := ctx.route.Cache
+	if rc == nil || !isResponseCacheable(ctx) {
+		m.Next(ctx)
+		return
+	}
+
+	c := ctx.a.CacheManager().Cache(rc.Store)
+	if c == nil {
+		ctx.Log().Warnf("responsecache: cache store '%s' not exists for route '%s'", rc.Store, ctx.route.Name)
+		m.Next(ctx)
+		return
+	}
+
+	key := responseCacheKey(ctx, rc)
+	if !hasCacheDirective(ctx.Req.Header.Get(ahttp.HeaderCacheControl), "no-cache") {
+		if cr := lookupCachedResponse(c, key, ctx.Req.Header); cr != nil {
+			ctx.Log().Tracef("responsecache: serving route '%s' from cache store '%s'", ctx.route.Name, rc.Store)
+			cr.reply(ctx)
+			return
+		}
+	}
+
+	m.Next(ctx)
+
+	if !isReplyCacheable(ctx) {
+		return
+	}
+	capture := captureResponse(ctx)
+	defer releaseBuffer(capture.body)
+	ctx.e.writeReply(ctx)
+	ctx.Reply().Done()
+
+	storeCachedResponse(ctx, c, key, rc, capture)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported types and methods
+//______________________________________________________________________________
+
+// cachedResponse struct is stored as JSON string in the cache store, so that
+// it works with every cache provider serializer.
+type cachedResponse struct {
+	Code        int         `json:"code"`
+	ContentType string      `json:"content_type"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body"`
+}
+
+func (cr *cachedResponse) reply(ctx *Context) {
+	hdr := ctx.Res.Header()
+	for k, v := range cr.Header {
+		if _, found := hdr[k]; !found {
+			hdr[k] = v
+		}
+	}
+	re := ctx.Reply().Status(cr.Code).ContentType(cr.ContentType).Binary(cr.Body)
+	if len(cr.Header.Get(ahttp.HeaderContentEncoding)) > 0 {
+		// stored body is already compressed
+		re.DisableGzip()
+	}
+}
+
+// responseCapture struct writes the response on the wire and keeps the copy
+// of written bytes for the response cache.
+type responseCapture struct {
+	http.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (rc *responseCapture) Write(b []byte) (int, error) {
+	n, err := rc.ResponseWriter.Write(b)
+	_, _ = rc.body.Write(b[:n])
+	return n, err
+}
+
+func (rc *responseCapture) Flush() {
+	if f, ok := rc.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// captureResponse method replaces the context response writer with the one
+// which captures the bytes written by `HTTPEngine.writeReply`.
+func captureResponse(ctx *Context) *responseCapture {
+	capture := &responseCapture{ResponseWriter: ctx.Res.Unwrap(), body: acquireBuffer()}
+	res := ctx.Res
+	ctx.Res = ahttp.AcquireResponseWriter(capture)
+	ahttp.ReleaseResponseWriter(res)
+	return capture
+}
+
+func isResponseCacheable(ctx *Context) bool {
+	if ctx.Req.Method != ahttp.MethodGet && ctx.Req.Method != ahttp.MethodHead {
+		return false
+	}
+	if hasCacheDirective(ctx.Req.Header.Get(ahttp.HeaderCacheControl), "no-store") ||
+		len(ctx.Req.Header.Get(ahttp.HeaderAuthorization)) > 0 {
+		return false
+	}
+	if ctx.subject == nil {
+		return true
+	}
+	// New session without values is created for every request on stateful mode
+	s := ctx.subject.Session
+	return !ctx.subject.IsAuthenticated() && (s == nil || (s.IsNew && len(s.Values) == 0))
+}
+
+// isReplyCacheable method reports whether the reply qualifies for the response
+// cache before it is written, response headers are checked after the write.
+func isReplyCacheable(ctx *Context) bool {
+	re := ctx.Reply()
+	if re.err != nil || re.done || re.redirect || re.Code != http.StatusOK ||
+		len(re.cookies) > 0 || len(ctx.Res.Header()[ahttp.HeaderSetCookie]) > 0 ||
+		re.Rdr == nil || !isResponseCacheable(ctx) {
+		return false
+	}
+	_, binary := re.Rdr.(*binaryRender)
+	return !binary
+}
+
+func lookupCachedResponse(c cache.Cache, key string, hdr http.Header) *cachedResponse {
+	vary, ok := c.Get(key).(string)
+	if !ok {
+		return nil
+	}
+	v, ok := c.Get(key + varyKey(hdr, vary)).(string)
+	if !ok {
+		return nil
+	}
+	cr := &cachedResponse{}
+	if err := json.Unmarshal([]byte(v), cr); err != nil {
+		return nil
+	}
+	return cr
+}
+
+// storeCachedResponse method stores the reply written on the wire in the cache.
+func storeCachedResponse(ctx *Context, c cache.Cache, key string, rc *router.ResponseCache, capture *responseCapture) {
+	re := ctx.Reply()
+	if ctx.Res.Status() != http.StatusOK || !isResponseCacheable(ctx) {
+		return
+	}
+	if vm := ctx.a.viewMgr; vm != nil && re.isHTML() {
+		if h, ok := re.Rdr.(*htmlRender); ok && (h.Template == nil || h.Template == vm.notFoundTmpl) {
+			return
+		}
+	}
+	if _, found := ctx.ViewArgs()[keyAntiCSRF]; found && re.isHTML() {
+		return
+	}
+	hdr := ctx.Res.Header()
+	cc := hdr.Get(ahttp.HeaderCacheControl)
+	if hasCacheDirective(cc, "no-store") || hasCacheDirective(cc, "no-cache") ||
+		hasCacheDirective(cc, "private") {
+		return
+	}
+	vary, ok := responseVary(hdr)
+	if !ok {
+		return
+	}
+
+	// gzip writer flushes the remaining compressed bytes on close
+	if gw, ok := ctx.Res.(*ahttp.GzipResponse); ok {
+		if err := gw.Close(); err != nil {
+			ctx.Log().Error("responsecache: ", err)
+			return
+		}
+	}
+
+	cr := &cachedResponse{Code: http.StatusOK, ContentType: hdr.Get(ahttp.HeaderContentType),
+		Header: make(http.Header), Body: capture.body.Bytes()}
+	for k, v := range hdr {
+		// new session cookie is written on each reply
+		if k == ahttp.HeaderContentType || k == ahttp.HeaderSetCookie ||
+			k == ctx.a.settings.RequestIDHeaderKey || strings.HasPrefix(k, "Access-Control-") {
+			continue
+		}
+		cr.Header[k] = v
+	}
+	b, err := json.Marshal(cr)
+	if err != nil {
+		ctx.Log().Error("responsecache: ", err)
+		return
+	}
+
+	// Entry at the key holds the response `Vary` header names; response
+	// itself is stored per vary header values.
+	varyNames := strings.Join(vary, ", ")
+	for _, e := range [][2]string{{key + varyKey(ctx.Req.Header, varyNames), string(b)}, {key, varyNames}} {
+		_ = c.Delete(e[0])
+		if err = c.Put(e[0], e[1], rc.TTL); err != nil && err != cache.ErrEntryExists {
+			ctx.Log().Errorf("responsecache: unable to store route '%s' response: %v", ctx.route.Name, err)
+			return
+		}
+	}
+}
+
+// responseCacheKey method returns the cache key for the request
+// `<domain>:<route>:<hash>`, hash comprises the path parameters and
+// configured query parameters and request headers.
+func responseCacheKey(ctx *Context, rc *router.ResponseCache) string {
+	h := sha1.New()
+	for _, p := range ctx.Req.URLParams {
+		_, _ = h.Write([]byte(p.Key + "=" + p.Value + "\n"))
+	}
+	query := ctx.Req.URL().Query()
+	for _, q := range rc.QueryParams {
+		_, _ = h.Write([]byte("?" + q + "=" + strings.Join(query[q], ",") + "\n"))
+	}
+	for _, k := range rc.Headers {
+		_, _ = h.Write([]byte(k + ":" + strings.Join(ctx.Req.Header[k], ",") + "\n"))
+	}
+	return ctx.domain.Key + ":" + ctx.route.Name + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// varyKey method returns the key suffix for the request header values
+// of given vary names.
+func varyKey(hdr http.Header, varyNames string) string {
+	if len(varyNames) == 0 {
+		return "|"
+	}
+	h := sha1.New()
+	for _, name := range strings.Split(varyNames, ", ") {
+		_, _ = h.Write([]byte(name + ":" + strings.Join(hdr[name], ",") + "\n"))
+	}
+	return "|" + hex.EncodeToString(h.Sum(nil))
+}
+
+// responseVary method returns the sorted response `Vary` header names,
+// false if vary is `*`.
+func responseVary(hdr http.Header) ([]string, bool) {
+	var names []string
+	for _, v := range hdr[ahttp.HeaderVary] {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name == "*" {
+				return nil, false
+			}
+			if len(name) > 0 && !ess.IsSliceContainsString(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, true
+}
+
+func hasCacheDirective(cc, directive string) bool {
+	for _, d := range strings.Split(cc, ",") {
+		d = strings.TrimSpace(d)
+		if idx := strings.IndexByte(d, '='); idx > 0 {
+			d = d[:idx]
+		}
+		if strings.EqualFold(d, directive) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package aah
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"aahframe.work/ahttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCacheMiddleware(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Response Cache]: %s", ts.URL)
+
+	get := func(path string, hdrs ...string) *testResult {
+		req, err := http.NewRequest(ahttp.MethodGet, ts.URL+path, nil)
+		assert.Nil(t, err)
+		for i := 0; i < len(hdrs); i += 2 {
+			req.Header.Set(hdrs[i], hdrs[i+1])
+		}
+		return fireRequest(t, req)
+	}
+	rendered := func(path string, hdrs ...string) string {
+		result := get(path, hdrs...)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		return result.Header.Get("X-Rendered")
+	}
+
+	t.Log("served from cache")
+	result := get("/cached/1")
+	assert.Equal(t, "text/plain; charset=utf-8", result.Header.Get(ahttp.HeaderContentType))
+	n := result.Header.Get("X-Rendered")
+	assert.Equal(t, "page 1 render "+n, result.Body)
+	result = get("/cached/1?other=value")
+	assert.Equal(t, n, result.Header.Get("X-Rendered"))
+	assert.Equal(t, "page 1 render "+n, result.Body)
+	assert.Equal(t, "text/plain; charset=utf-8", result.Header.Get(ahttp.HeaderContentType))
+	assert.Equal(t, "After Called successfully", result.Header.Get("X-After-Interceptor"))
+
+	t.Log("path params, configured query params and headers are part of key")
+	assert.NotEqual(t, n, rendered("/cached/2"))
+	assert.NotEqual(t, n, rendered("/cached/1?mode=other"))
+	n2 := rendered("/cached/1", "X-Tenant", "tenant1")
+	assert.NotEqual(t, n, n2)
+	assert.Equal(t, n2, rendered("/cached/1", "X-Tenant", "tenant1"))
+
+	t.Log("request cache control no-cache refreshes and no-store bypasses")
+	n3 := rendered("/cached/1", ahttp.HeaderCacheControl, "no-cache")
+	assert.NotEqual(t, n, n3)
+	assert.Equal(t, n3, rendered("/cached/1"))
+	assert.NotEqual(t, n3, rendered("/cached/1", ahttp.HeaderCacheControl, "no-store"))
+	assert.Equal(t, n3, rendered("/cached/1"))
+
+	t.Log("authorization header bypasses")
+	assert.NotEqual(t, n3, rendered("/cached/1", ahttp.HeaderAuthorization, "Bearer token"))
+
+	t.Log("response cache control no-store and session values are not stored")
+	n = rendered("/cached/1?mode=nostore")
+	assert.NotEqual(t, n, rendered("/cached/1?mode=nostore"))
+	n = rendered("/cached/1?mode=session")
+	assert.NotEqual(t, n, rendered("/cached/1?mode=session"))
+
+	t.Log("response vary")
+	n = rendered("/cached/1?mode=vary", ahttp.HeaderAcceptLanguage, "en")
+	n2 = rendered("/cached/1?mode=vary", ahttp.HeaderAcceptLanguage, "fr")
+	assert.NotEqual(t, n, n2)
+	assert.Equal(t, n, rendered("/cached/1?mode=vary", ahttp.HeaderAcceptLanguage, "en"))
+	assert.Equal(t, n2, rendered("/cached/1?mode=vary", ahttp.HeaderAcceptLanguage, "fr"))
+
+	t.Log("html view is rendered and stored")
+	result = get("/cached/1?mode=html")
+	n = result.Header.Get("X-Rendered")
+	assert.Equal(t, "text/html; charset=utf-8", result.Header.Get(ahttp.HeaderContentType))
+	assert.True(t, strings.Contains(result.Body, "page 1 render "+n+" Yes it works!!!"))
+	result2 := get("/cached/1?mode=html")
+	assert.Equal(t, n, result2.Header.Get("X-Rendered"))
+	assert.Equal(t, result.Body, result2.Body)
+	assert.Equal(t, "1; mode=block", result2.Header.Get(ahttp.HeaderXXSSProtection))
+
+	t.Log("gzip reply is stored per accept-encoding and not compressed again")
+	result = get("/cached/1?mode=html", ahttp.HeaderAcceptEncoding, "gzip")
+	n = result.Header.Get("X-Rendered")
+	assert.Equal(t, "gzip", result.Header.Get(ahttp.HeaderContentEncoding))
+	assert.True(t, strings.Contains(result.Body, "page 1 render "+n+" Yes it works!!!"))
+	result2 = get("/cached/1?mode=html", ahttp.HeaderAcceptEncoding, "gzip")
+	assert.Equal(t, n, result2.Header.Get("X-Rendered"))
+	assert.Equal(t, "gzip", result2.Header.Get(ahttp.HeaderContentEncoding))
+	assert.Equal(t, result.Body, result2.Body)
+	assert.NotEqual(t, n, rendered("/cached/1?mode=html", ahttp.HeaderAcceptEncoding, "identity"))
+
+	t.Log("child route inherits cache, html with anti-csrf is not stored")
+	n = rendered("/cached/1/csrf")
+	assert.Equal(t, n, rendered("/cached/1/csrf"))
+	n = rendered("/cached/1/csrf?mode=html")
+	assert.NotEqual(t, n, rendered("/cached/1/csrf?mode=html"))
+
+	t.Log("non-cached route")
+	result = get("/get-text.html")
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+}
+
+func TestResponseCacheHelpers(t *testing.T) {
+	assert.True(t, hasCacheDirective("public, max-age=60, No-Store", "no-store"))
+	assert.True(t, hasCacheDirective("private=\"Set-Cookie\"", "private"))
+	assert.False(t, hasCacheDirective("", "no-cache"))
+	assert.False(t, hasCacheDirective("no-cache-x", "no-cache"))
+
+	vary, ok := responseVary(http.Header{ahttp.HeaderVary: []string{"origin, Accept-Language", "Origin"}})
+	assert.True(t, ok)
+	assert.Equal(t, []string{"Accept-Language", "Origin"}, vary)
+	_, ok = responseVary(http.Header{ahttp.HeaderVary: []string{"Origin, *"}})
+	assert.False(t, ok)
+
+	hdr := http.Header{ahttp.HeaderAcceptLanguage: []string{"en"}}
+	assert.Equal(t, "|", varyKey(hdr, ""))
+	assert.NotEqual(t, varyKey(hdr, "Accept-Language"), varyKey(http.Header{}, "Accept-Language"))
+}
@@ -35,6 +35,7 @@ type Domain struct {
 	Host                  string
 	Port                  string
 	DefaultAuth           string
+	SessionHeader         string
 	CORS                  *CORS
 	CatchAllRoute         *Route
 	trees                 map[string]*tree
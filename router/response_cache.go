@@ -0,0 +1,82 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"aahframe.work/config"
+)
+
+const defaultResponseCacheTTL = "5m"
+
+// ResponseCache struct holds the route response cache configuration values.
+// Route opt-in for response caching via `cache { ... }` block in the route
+// and child routes inherit it from the parent route.
+//
+//	cache {
+//	  # Cache store name from `cache.stores { ... }`, it is required.
+//	  store = "pages"
+//
+//	  # Cached response duration. Default value is `5m`.
+//	  ttl = "10m"
+//
+//	  # Request query parameters and headers become part of the cache key.
+//	  # Default is none.
+//	  query_params = ["page", "sort"]
+//	  headers = ["Accept-Language"]
+//	}
+type ResponseCache struct {
+	Store       string
+	TTL         time.Duration
+	QueryParams []string
+	Headers     []string
+	ttlStr      string
+}
+
+// String method is stringer interface.
+func (rc *ResponseCache) String() string {
+	if rc == nil {
+		return "cache(nil)"
+	}
+	return fmt.Sprintf("cache(store:%s ttl:%s queryparams:%v headers:%v)",
+		rc.Store, rc.TTL, rc.QueryParams, rc.Headers)
+}
+
+func processResponseCacheSection(routeName string, cfg *config.Config, parent *ResponseCache) (*ResponseCache, error) {
+	rc := &ResponseCache{}
+	if parent == nil {
+		parent = &ResponseCache{ttlStr: defaultResponseCacheTTL}
+	}
+
+	rc.Store = cfg.StringDefault("store", parent.Store)
+	if len(rc.Store) == 0 {
+		return nil, fmt.Errorf("'%v.cache.store' key is missing", routeName)
+	}
+
+	var err error
+	rc.ttlStr = cfg.StringDefault("ttl", parent.ttlStr)
+	if rc.TTL, err = time.ParseDuration(rc.ttlStr); err != nil || rc.TTL <= 0 {
+		return nil, fmt.Errorf("'%v.cache.ttl' value is not a valid duration", routeName)
+	}
+
+	if params, found := cfg.StringList("query_params"); found {
+		rc.QueryParams = params
+	} else {
+		rc.QueryParams = parent.QueryParams
+	}
+
+	if hdrs, found := cfg.StringList("headers"); found {
+		for _, h := range hdrs {
+			rc.Headers = append(rc.Headers, http.CanonicalHeaderKey(h))
+		}
+	} else {
+		rc.Headers = parent.Headers
+	}
+
+	return rc, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteResponseCacheConfig(t *testing.T) {
+	cfg, err := config.ParseString(`
+    products {
+      path = "/products"
+      controller = "Product"
+      cache {
+        store = "pages"
+        query_params = ["page", "sort"]
+        headers = ["accept-language"]
+      }
+
+      routes {
+        product {
+          path = "/:id"
+          cache {
+            ttl = "30s"
+            query_params = ["view"]
+          }
+        }
+        product_reviews {
+          path = "/:id/reviews"
+        }
+        product_stock {
+          path = "/:id/stock"
+          cache {
+            enable = false
+          }
+        }
+        product_ws {
+          path = "/ws"
+          method = "WS"
+          websocket = "ProductWebSocket"
+          action = "Handle"
+        }
+      }
+    }
+  `)
+	assert.Nil(t, err)
+
+	routes, err := parseSectionRoutes(cfg, &parentRouteInfo{AuthorizationInfo: &authorizationInfo{Satisfy: "either"}})
+	assert.Nil(t, err)
+	byName := make(map[string]*Route)
+	for _, r := range routes {
+		byName[r.Name] = r
+	}
+
+	rc := byName["products"].Cache
+	assert.Equal(t, "pages", rc.Store)
+	assert.Equal(t, 5*time.Minute, rc.TTL)
+	assert.Equal(t, []string{"page", "sort"}, rc.QueryParams)
+	assert.Equal(t, []string{"Accept-Language"}, rc.Headers)
+	assert.Equal(t, "cache(store:pages ttl:5m0s queryparams:[page sort] headers:[Accept-Language])", rc.String())
+
+	rc = byName["product"].Cache
+	assert.Equal(t, "pages", rc.Store)
+	assert.Equal(t, 30*time.Second, rc.TTL)
+	assert.Equal(t, []string{"view"}, rc.QueryParams)
+	assert.Equal(t, []string{"Accept-Language"}, rc.Headers)
+
+	assert.Equal(t, byName["products"].Cache, byName["product_reviews"].Cache)
+	assert.Nil(t, byName["product_stock"].Cache)
+	assert.Nil(t, byName["product_ws"].Cache)
+	assert.Equal(t, "cache(nil)", byName["product_ws"].Cache.String())
+}
+
+func TestRouteResponseCacheConfigErrors(t *testing.T) {
+	testcases := []struct {
+		label, configStr string
+		err              error
+	}{
+		{
+			label:     "store missing",
+			configStr: `products { path = "/products"; controller = "Product"; cache { ttl = "1m"; } }`,
+			err:       errors.New("'products.cache.store' key is missing"),
+		},
+		{
+			label:     "invalid ttl",
+			configStr: `products { path = "/products"; controller = "Product"; cache { store = "pages"; ttl = "1y"; } }`,
+			err:       errors.New("'products.cache.ttl' value is not a valid duration"),
+		},
+		{
+			label:     "negative ttl",
+			configStr: `products { path = "/products"; controller = "Product"; cache { store = "pages"; ttl = "-1m"; } }`,
+			err:       errors.New("'products.cache.ttl' value is not a valid duration"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			cfg, err := config.ParseString(tc.configStr)
+			assert.Nil(t, err)
+			_, err = parseSectionRoutes(cfg, &parentRouteInfo{AuthorizationInfo: &authorizationInfo{Satisfy: "either"}})
+			assert.Equal(t, tc.err, err)
+		})
+	}
+}
@@ -33,6 +33,7 @@ type Route struct {
 	Dir             string
 	File            string
 	CORS            *CORS
+	Cache           *ResponseCache
 	Constraints     map[string]string
 
 	authorizationInfo *authorizationInfo
@@ -146,6 +147,7 @@ type parentRouteInfo struct {
 	Auth              string
 	MaxBodySizeStr    string
 	CORS              *CORS
+	Cache             *ResponseCache
 	AuthorizationInfo *authorizationInfo
 }
 
@@ -336,6 +336,17 @@ func (r *Router) processRoutesConfig() (err error) {
 			routes:                make(map[string]*Route),
 		}
 
+		// Domain level session transport, session is transmitted via HTTP
+		// header instead of cookie for clients without cookie jar
+		switch transport := domainCfg.StringDefault("session.transport", "cookie"); transport {
+		case "cookie":
+		case "header":
+			domain.SessionHeader = domainCfg.StringDefault("session.header", ahttp.HeaderAuthorization)
+		default:
+			err = fmt.Errorf("'%v.session.transport' value '%v' is not supported", key, transport)
+			return
+		}
+
 		// Domain Level CORS configuration
 		if domain.CORSEnabled {
 			baseCORSCfg, _ := domainCfg.GetSubConfig("cors")
@@ -612,10 +623,23 @@ func parseSectionRoutes(cfg *config.Config, routeInfo *parentRouteInfo) (routes
 			}
 		}
 
-		// 'anti_csrf_check', 'cors' and 'max_body_size' not applicable for WebSocket
+		// Response cache
+		var respCache *ResponseCache
+		if cacheCfg, found := cfg.GetSubConfig(routeName + ".cache"); found {
+			if cacheCfg.BoolDefault("enable", true) {
+				if respCache, err = processResponseCacheSection(routeName, cacheCfg, routeInfo.Cache); err != nil {
+					return
+				}
+			}
+		} else {
+			respCache = routeInfo.Cache
+		}
+
+		// 'anti_csrf_check', 'cors', 'cache' and 'max_body_size' not applicable for WebSocket
 		if routeMethod == methodWebSocket {
 			routeAntiCSRFCheck = false
 			cors = nil
+			respCache = nil
 			routeMaxBodySize = 0
 		}
 
@@ -632,6 +656,7 @@ func parseSectionRoutes(cfg *config.Config, routeInfo *parentRouteInfo) (routes
 					MaxBodySize:       routeMaxBodySize,
 					IsAntiCSRFCheck:   routeAntiCSRFCheck,
 					CORS:              cors,
+					Cache:             respCache,
 					Constraints:       routeConstraints,
 					authorizationInfo: routeAuthorizationInfo,
 				})
@@ -649,6 +674,7 @@ func parseSectionRoutes(cfg *config.Config, routeInfo *parentRouteInfo) (routes
 				AntiCSRFCheck:     routeAntiCSRFCheck,
 				CORS:              cors,
 				CORSEnabled:       routeInfo.CORSEnabled,
+				Cache:             respCache,
 				AuthorizationInfo: routeAuthorizationInfo,
 			})
 			if er != nil {
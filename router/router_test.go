@@ -263,6 +263,19 @@ func TestRouterDomainConfig(t *testing.T) {
 	assert.Nil(t, domain)
 }
 
+func TestRouterDomainSessionTransport(t *testing.T) {
+	router, err := createRouter("routes-session-header.conf")
+	assert.Nil(t, err)
+
+	assert.Equal(t, "", router.Lookup("localhost:8080").SessionHeader)
+	assert.Equal(t, ahttp.HeaderAuthorization, router.Lookup("api.localhost:8080").SessionHeader)
+	assert.Equal(t, "X-Session-Token", router.Lookup("mobile.localhost:8080").SessionHeader)
+
+	router, err = createRouter("routes-session-transport-error.conf")
+	assert.Nil(t, router)
+	assert.Equal(t, "'localhost.session.transport' value 'query' is not supported", err.Error())
+}
+
 func TestRouterDomainAddresses(t *testing.T) {
 	router, err := createRouter("routes.conf")
 	assert.Nil(t, err, "")
@@ -5,9 +5,14 @@
 package aah
 
 import (
+	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"aahframe.work/ahttp"
 	ess "aahframe.work/essentials"
@@ -17,6 +22,8 @@ import (
 	"aahframe.work/security/authc"
 	"aahframe.work/security/authz"
 	"aahframe.work/security/scheme"
+	"aahframe.work/security/session"
+	"aahframe.work/security/throttle"
 )
 
 const (
@@ -29,9 +36,16 @@ const (
 	// KeyOAuth2Token key name is used to store OAuth2 Access Token into `aah.Context`.
 	KeyOAuth2Token = "_aahOAuth2Token"
 
-	keyAntiCSRF       = "_aahAntiCSRF"
-	keyOAuth2StateKey = "_aahOAuth2State"
-	keyAuthScheme     = "_aahAuthScheme"
+	// KeyOAuth2IDTokenClaims key name is used to store verified OpenID Connect
+	// ID token claims into `aah.Context`.
+	KeyOAuth2IDTokenClaims = scheme.KeyOIDCClaims
+
+	keyAntiCSRF           = "_aahAntiCSRF"
+	keyOAuth2StateKey     = "_aahOAuth2State"
+	keyAuthScheme         = "_aahAuthScheme"
+	keyTwoFactorAuthcInfo = "_aahTwoFactorAuthcInfo"
+	keyTwoFactorIdentity  = "_aahTwoFactorIdentity"
+	keyTwoFactorAttempts  = "_aahTwoFactorAttempts"
 )
 
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
@@ -45,11 +59,50 @@ func (a *Application) initSecurity() error {
 		return err
 	}
 
+	// session lifecycle events are published into app event store
+	asecmgr.SessionManager.SetEventPublisher(func(e *session.Event) {
+		a.PublishEvent(e.Name, e)
+	})
+
 	a.securityMgr = asecmgr
 	a.settings.AuthSchemeExists = len(a.securityMgr.AuthSchemes()) > 0
 	return nil
 }
 
+// initLoginThrottleStore method sets the cache store into login throttle, if
+// configured. It is called after the cache initialization.
+func (a *Application) initLoginThrottleStore() error {
+	lt := a.SecurityManager().LoginThrottle
+	if lt == nil || !lt.Enabled || ess.IsStrEmpty(lt.CacheName) {
+		return nil
+	}
+	store, err := throttle.NewCacheStore(a.CacheManager().Cache(lt.CacheName))
+	if err != nil {
+		return fmt.Errorf("security: login throttle cache '%s' not exists", lt.CacheName)
+	}
+	return lt.SetStore(store)
+}
+
+// initTwoFactorStepStore method sets the cache store into form auth schemes
+// to record the used TOTP codes, if configured. It is called after the cache
+// initialization.
+func (a *Application) initTwoFactorStepStore() error {
+	for _, s := range a.SecurityManager().AuthSchemes() {
+		formAuth, ok := s.(*scheme.FormAuth)
+		if !ok || !formAuth.IsTwoFactorEnabled() || ess.IsStrEmpty(formAuth.TwoFactorCacheName) {
+			continue
+		}
+		store, err := scheme.NewCacheTOTPStepStore(a.CacheManager().Cache(formAuth.TwoFactorCacheName))
+		if err != nil {
+			return fmt.Errorf("security: two-factor cache '%s' not exists", formAuth.TwoFactorCacheName)
+		}
+		if err = formAuth.SetTOTPStepStore(store); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // Authentication and Authorization Middleware
 //______________________________________________________________________________
@@ -90,7 +143,9 @@ func AuthcAuthzMiddleware(ctx *Context, m *Middleware) {
 		case "oauth2":
 			result = doOAuth2(authScheme, ctx)
 		default:
-			result = doAuthScheme(authScheme, ctx)
+			if result = doAuthScheme(authScheme, ctx); result == flowCont {
+				result = renewAuthenticatedSession(ctx)
+			}
 		}
 
 		if result == flowCont {
@@ -107,6 +162,15 @@ func AuthcAuthzMiddleware(ctx *Context, m *Middleware) {
 func doFormAuth(authScheme scheme.Schemer, ctx *Context) flowResult {
 	formAuth := authScheme.(*scheme.FormAuth)
 
+	// Subject is partially authenticated, password is verified and second
+	// factor is pending. Submitting login form again starts over.
+	if formAuth.IsTwoFactorEnabled() && formAuth.LoginSubmitURL != ctx.route.Path &&
+		ctx.Session().GetString(keyAuthScheme) == formAuth.Key() {
+		if authcInfo := sessionAuthenticationInfoOf(ctx.Session(), keyTwoFactorAuthcInfo); authcInfo != nil {
+			return doFormAuthSecondFactor(formAuth, authcInfo, ctx)
+		}
+	}
+
 	// Check route is login submit URL otherwise send it login URL.
 	// Since session is not authenticated.
 	if formAuth.LoginSubmitURL != ctx.route.Path && ctx.Req.Method != ahttp.MethodPost {
@@ -119,12 +183,107 @@ func doFormAuth(authScheme scheme.Schemer, ctx *Context) flowResult {
 	}
 
 	ctx.e.publishOnPreAuthEvent(ctx)
+	clearTwoFactorState(ctx)
 
 	if doAuthentication(authScheme, ctx) == flowAbort {
 		return flowAbort
 	}
 
-	populateAuthorizationInfo(authScheme, ctx)
+	return formAuthSucceeded(formAuth, ctx)
+}
+
+// doFormAuthSecondFactor method verifies the TOTP code or recovery code of
+// partially authenticated subject, on success subject becomes authenticated.
+func doFormAuthSecondFactor(formAuth *scheme.FormAuth, authcInfo *authc.AuthenticationInfo, ctx *Context) flowResult {
+	// Check route is OTP submit URL otherwise send it OTP URL.
+	if formAuth.OTPSubmitURL != ctx.route.Path || ctx.Req.Method != ahttp.MethodPost {
+		otpURL := formAuth.OTPURL
+		if formAuth.OTPURL != ctx.Req.Path {
+			otpURL = util.AddQueryString(otpURL, "_rt", ctx.Req.URL().String())
+		}
+		ctx.Reply().Redirect(otpURL)
+		return flowAbort
+	}
+
+	identity := ctx.Session().GetString(keyTwoFactorIdentity)
+	loginThrottle := loginThrottleOf(formAuth, ctx)
+	if loginThrottle != nil {
+		if st := loginThrottle.Check(identity, ctx.Req.ClientIP()); st != nil {
+			replyLoginThrottled(formAuth, ctx, st)
+			return flowAbort
+		}
+	}
+
+	rt := ctx.Req.FormValue("_rt")
+	if err := formAuth.VerifySecondFactor(authcInfo, ctx.Req.FormValue(formAuth.FieldOTP)); err != nil {
+		if loginThrottle != nil {
+			failLoginAttempt(formAuth, loginThrottle, identity, ctx)
+		}
+
+		attempts := ctx.Session().GetInt(keyTwoFactorAttempts) + 1
+		if formAuth.TwoFactorMaxAttempts > 0 && attempts >= formAuth.TwoFactorMaxAttempts {
+			ctx.Log().Infof("%s: Second factor attempts exceeded, sending to login failure URL", formAuth.Key())
+			clearTwoFactorState(ctx)
+			ctx.Reply().Redirect(util.AddQueryString(formAuth.LoginFailureURL, "_rt", rt))
+			return flowAbort
+		}
+		ctx.Session().Set(keyTwoFactorAttempts, attempts)
+
+		ctx.Log().Infof("%s: Second factor authentication is failed, sending to OTP failure URL", formAuth.Key())
+		ctx.Reply().Redirect(util.AddQueryString(formAuth.OTPFailureURL, "_rt", rt))
+		return flowAbort
+	}
+
+	clearTwoFactorState(ctx)
+	if loginThrottle != nil {
+		loginThrottle.Succeed(identity)
+	}
+
+	ctx.Log().Infof("%s: Second factor authentication successful", formAuth.Key())
+	authenticationSucceeded(formAuth, authcInfo, ctx)
+	return formAuthSucceeded(formAuth, ctx)
+}
+
+// beginTwoFactorAuth method puts the subject into partially authenticated
+// state and sends it to OTP URL, if the second factor is required for the
+// subject. It returns true if the reply is handled.
+func beginTwoFactorAuth(formAuth *scheme.FormAuth, identity string, authcInfo *authc.AuthenticationInfo, ctx *Context) bool {
+	secret, err := formAuth.TwoFactorSecret(authcInfo)
+	if err != nil {
+		ctx.Log().Error(err)
+		ctx.Reply().Redirect(util.AddQueryString(formAuth.LoginFailureURL, "_rt", ctx.Req.FormValue("_rt")))
+		return true
+	}
+	if ess.IsStrEmpty(secret) {
+		return false
+	}
+
+	authcInfo.Credential = nil // Remove the credential
+	ctx.Session().Set(keyTwoFactorAuthcInfo, authcInfo)
+	ctx.Session().Set(keyTwoFactorIdentity, identity)
+	ctx.Session().Set(keyAuthScheme, formAuth.Key())
+
+	ctx.Log().Infof("%s: Password verified, sending to OTP URL for second factor", formAuth.Key())
+	ctx.Reply().Redirect(util.AddQueryString(formAuth.OTPURL, "_rt", ctx.Req.FormValue("_rt")))
+	return true
+}
+
+func clearTwoFactorState(ctx *Context) {
+	if ctx.Session().IsKeyExists(keyTwoFactorAuthcInfo) {
+		ctx.Session().Del(keyTwoFactorAuthcInfo)
+		ctx.Session().Del(keyTwoFactorIdentity)
+		ctx.Session().Del(keyTwoFactorAttempts)
+	}
+}
+
+// formAuthSucceeded method completes the form auth flow of authenticated
+// subject and redirects to requested or default target URL.
+func formAuthSucceeded(formAuth *scheme.FormAuth, ctx *Context) flowResult {
+	if renewAuthenticatedSession(ctx) == flowAbort {
+		return flowAbort
+	}
+
+	populateAuthorizationInfo(formAuth, ctx)
 	debugLogSubjectInfo(ctx)
 
 	ctx.e.publishOnPostAuthEvent(ctx)
@@ -159,17 +318,29 @@ func doOAuth2(authScheme scheme.Schemer, ctx *Context) flowResult {
 
 	// OAuth2 provider callback handling
 	if ctx.Req.Path == oauth.RedirectURL {
+		state := ctx.Session().GetString(keyOAuth2StateKey)
 		defer ctx.Session().Del(keyOAuth2StateKey)
 
 		// Validate OAuth2 callback
 		ctx.Log().Debug(ctx.Req.URL().String())
-		token, err := oauth.ValidateCallback(ctx.Session().GetString(keyOAuth2StateKey), ctx.Req)
+		token, err := oauth.ValidateCallback(state, ctx.Req)
 		if err != nil {
 			ctx.Log().Error(err)
 			ctx.Reply().Unauthorized().Error(newError(err, http.StatusUnauthorized))
 			return flowAbort
 		}
 
+		// Validate OpenID Connect ID token
+		if oauth.IsOIDC() {
+			claims, err := oauth.VerifyIDToken(state, token)
+			if err != nil {
+				ctx.Log().Error(err)
+				ctx.Reply().Unauthorized().Error(newError(err, http.StatusUnauthorized))
+				return flowAbort
+			}
+			ctx.Set(KeyOAuth2IDTokenClaims, claims)
+		}
+
 		// Set successful access token into aah.Context
 		ctx.Log().Info("oauth2: Token obtained from provider")
 		ctx.Set(KeyOAuth2Token, token)
@@ -177,6 +348,9 @@ func doOAuth2(authScheme scheme.Schemer, ctx *Context) flowResult {
 		if doAuthentication(authScheme, ctx) == flowAbort {
 			return flowAbort
 		}
+		if renewAuthenticatedSession(ctx) == flowAbort {
+			return flowAbort
+		}
 
 		populateAuthorizationInfo(authScheme, ctx)
 		debugLogSubjectInfo(ctx)
@@ -233,8 +407,21 @@ func doAuthentication(authScheme scheme.Schemer, ctx *Context) flowResult {
 	} else {
 		// Call Authentication Info provider
 		var err error
-		authcInfo, err = authScheme.DoAuthenticate(authScheme.ExtractAuthenticationToken(ctx.Req))
+		authcToken := authScheme.ExtractAuthenticationToken(ctx.Req)
+		loginThrottle := loginThrottleOf(authScheme, ctx)
+		if loginThrottle != nil {
+			if st := loginThrottle.Check(authcToken.Identity, ctx.Req.ClientIP()); st != nil {
+				replyLoginThrottled(authScheme, ctx, st)
+				return flowAbort
+			}
+		}
+
+		authcInfo, err = authScheme.DoAuthenticate(authcToken)
 		if err != nil || authcInfo == nil {
+			if loginThrottle != nil {
+				failLoginAttempt(authScheme, loginThrottle, authcToken.Identity, ctx)
+			}
+
 			switch sa := authScheme.(type) {
 			case *scheme.FormAuth:
 				ctx.Log().Infof("%s: Authentication is failed, sending to login failure URL", authScheme.Key())
@@ -243,10 +430,13 @@ func doAuthentication(authScheme scheme.Schemer, ctx *Context) flowResult {
 				ctx.Log().Infof("%s: Authentication is failed", authScheme.Key())
 				ctx.Reply().Header(ahttp.HeaderWWWAuthenticate, `Basic realm="`+sa.RealmName+`"`)
 				ctx.Reply().Unauthorized().Error(newError(ErrAuthenticationFailed, http.StatusUnauthorized))
-			case *scheme.GenericAuth:
+			case *scheme.GenericAuth, *scheme.JWTAuth, *scheme.APIKeyAuth:
 				switch err {
 				case authc.ErrAuthenticationFailed, authc.ErrAuthenticatorIsNil, authc.ErrPrincipalIsNil, authc.ErrSubjectNotExists:
 					ctx.Log().Infof("%s: Authentication is failed", authScheme.Key())
+					if ja, ok := sa.(*scheme.JWTAuth); ok {
+						ctx.Reply().Header(ahttp.HeaderWWWAuthenticate, `Bearer realm="`+ja.RealmName+`"`)
+					}
 					ctx.Reply().Unauthorized().Error(newError(ErrAuthenticationFailed, http.StatusUnauthorized))
 				case authc.ErrInternalServerError:
 					ctx.Log().Errorf("%s: Internal Server Error", authScheme.Key())
@@ -259,8 +449,25 @@ func doAuthentication(authScheme scheme.Schemer, ctx *Context) flowResult {
 
 			return flowAbort
 		}
+
+		// Second factor step, subject is not authenticated yet
+		if fa, ok := authScheme.(*scheme.FormAuth); ok && fa.IsTwoFactorEnabled() &&
+			beginTwoFactorAuth(fa, authcToken.Identity, authcInfo, ctx) {
+			return flowAbort
+		}
+
+		if loginThrottle != nil {
+			loginThrottle.Succeed(authcToken.Identity)
+		}
 	}
 
+	authenticationSucceeded(authScheme, authcInfo, ctx)
+	return flowCont
+}
+
+// authenticationSucceeded method marks the session as authenticated and
+// populates the subject's authentication info.
+func authenticationSucceeded(authScheme scheme.Schemer, authcInfo *authc.AuthenticationInfo, ctx *Context) {
 	populateAuthenticationInfo(authcInfo, ctx)
 	ctx.Session().IsAuthenticated = true
 	ctx.Session().Set(keyAuthScheme, authScheme.Key())
@@ -276,10 +483,105 @@ func doAuthentication(authScheme scheme.Schemer, ctx *Context) flowResult {
 		ctx.Log().Info("Change Anti-CSRF secret after successful authentication for security purpose")
 		ctx.AddViewArg(keyAntiCSRF, ctx.a.SecurityManager().AntiCSRF.GenerateSecret())
 	}
+}
 
+// loginThrottleOf method returns the login throttle if it is enabled and
+// applicable to given auth scheme otherwise nil. Login throttle is applied to
+// password based auth schemes i.e. form and basic.
+func loginThrottleOf(authScheme scheme.Schemer, ctx *Context) *throttle.Throttle {
+	switch authScheme.(type) {
+	case *scheme.FormAuth, *scheme.BasicAuth:
+		if lt := ctx.a.SecurityManager().LoginThrottle; lt != nil && lt.Enabled {
+			return lt
+		}
+	}
+	return nil
+}
+
+// failLoginAttempt method records the failed login attempt into login throttle
+// and publishes the event `OnAuthLockout` on lockout.
+func failLoginAttempt(authScheme scheme.Schemer, loginThrottle *throttle.Throttle, identity string, ctx *Context) {
+	if lockout := loginThrottle.Fail(identity, ctx.Req.ClientIP()); lockout != nil {
+		ctx.Log().Warnf("%s: Login attempts are locked out for '%s' until %s", authScheme.Key(),
+			lockout.Key, lockout.Until.Format(time.RFC3339))
+		ctx.a.PublishEvent(EventOnAuthLockout, lockout)
+	}
+}
+
+// replyLoginThrottled method replies HTTP 429 Too Many Requests with header
+// `Retry-After` for throttled login attempt.
+func replyLoginThrottled(authScheme scheme.Schemer, ctx *Context, st *throttle.Status) {
+	retryAfter := int64(math.Ceil(st.RetryAfter.Seconds()))
+	got := "too-many-attempts"
+	if st.Locked {
+		got = "locked"
+	}
+	reasons := []*authz.Reason{{
+		Func:     "loginthrottle",
+		Expected: "retry-after=" + strconv.FormatInt(retryAfter, 10) + "s",
+		Got:      got,
+	}}
+
+	ctx.Log().Warnf("%s: Authentication throttled for '%s':%s", authScheme.Key(), st.Key, reason2String(reasons))
+	ctx.Reply().Header(ahttp.HeaderRetryAfter, strconv.FormatInt(retryAfter, 10))
+	ctx.Reply().Status(http.StatusTooManyRequests).
+		Error(newErrorWithData(ErrAuthThrottled, http.StatusTooManyRequests, reasons))
+}
+
+// renewAuthenticatedSession method rotates the session ID after successful
+// authentication to prevent session fixation and indexes the session to
+// subject's primary principal if session store supports it. Session cookie is
+// written with new ID at the end of request.
+//
+// If session ID is not rotated, authentication is reverted and the session is
+// cleared, since login must not continue on possibly fixated session ID.
+func renewAuthenticatedSession(ctx *Context) flowResult {
+	sessMgr := ctx.a.SessionManager()
+	if !sessMgr.IsStateful() {
+		return flowCont
+	}
+	if err := sessMgr.RegenerateID(nil, ctx.Session()); err != nil {
+		ctx.Log().Errorf("Unable to regenerate session ID after authentication: %v", err)
+		ctx.Session().IsAuthenticated = false
+		ctx.Session().Del(keyAuthScheme)
+		ctx.Session().Del(KeyViewArgAuthcInfo)
+		ctx.Subject().Logout()
+		ctx.Reply().InternalServerError().Error(newErrorWithData(ErrSessionRegenerateID, http.StatusInternalServerError, err))
+		return flowAbort
+	}
+	if sessMgr.IsIndexable() {
+		if err := sessMgr.IndexSession(ctx.Req, ctx.Session(), ctx.Subject().AuthenticationInfo); err != nil {
+			ctx.Log().Error(err)
+		}
+	}
 	return flowCont
 }
 
+// sessionAuthenticationInfo method returns the authentication info stored in
+// the session otherwise nil. With session codec `json` it is decoded as a
+// generic map, so it is converted back.
+func sessionAuthenticationInfo(s *session.Session) *authc.AuthenticationInfo {
+	return sessionAuthenticationInfoOf(s, KeyViewArgAuthcInfo)
+}
+
+func sessionAuthenticationInfoOf(s *session.Session, key string) *authc.AuthenticationInfo {
+	switch v := s.Get(key).(type) {
+	case *authc.AuthenticationInfo:
+		return v
+	case map[string]interface{}:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		authcInfo := authc.NewAuthenticationInfo()
+		if err = json.Unmarshal(b, authcInfo); err != nil {
+			return nil
+		}
+		return authcInfo
+	}
+	return nil
+}
+
 func populateAuthenticationInfo(authcInfo *authc.AuthenticationInfo, ctx *Context) {
 	ctx.Subject().AuthenticationInfo = authcInfo
 	ctx.logger = ctx.Log().WithField("principal", ctx.Subject().PrimaryPrincipal().Value)
@@ -154,6 +154,11 @@ func (m *Manager) Write(w http.ResponseWriter, value string) {
 	}
 }
 
+// MaxCookieSize method returns the max cookie value size i.e 4Kb.
+func (m *Manager) MaxCookieSize() int {
+	return m.maxCookieSize
+}
+
 // Encode method encodes given value.
 //
 // It performs:
@@ -162,6 +167,13 @@ func (m *Manager) Write(w http.ResponseWriter, value string) {
 //   3) Encodes value into Base64 string
 //   4) Checks max cookie size i.e 4Kb
 func (m *Manager) Encode(b []byte) (string, error) {
+	return m.EncodeWithMaxSize(b, m.maxCookieSize)
+}
+
+// EncodeWithMaxSize method encodes given value same as `Encode` and checks
+// the encoded value against given max size instead of max cookie size. It is
+// used for the value stored at server-side.
+func (m *Manager) EncodeWithMaxSize(b []byte, maxSize int) (string, error) {
 	// Encrypt it
 	if len(m.key.enc) > 0 {
 		b = acrypto.AESEncrypt(m.key.cipherBlock, b)
@@ -188,7 +200,7 @@ func (m *Manager) Encode(b []byte) (string, error) {
 	b = ess.EncodeToBase64(b)
 
 	// Check cookie max size.
-	if len(b) > m.maxCookieSize {
+	if len(b) > maxSize {
 		return "", ErrCookieValueIsTooLarge
 	}
 
@@ -205,8 +217,14 @@ func (m *Manager) Encode(b []byte) (string, error) {
 //   5) Decodes the value using Base64
 //   6) Decrypts the value
 func (m *Manager) Decode(value string) ([]byte, error) {
+	return m.DecodeWithMaxSize(value, m.maxCookieSize)
+}
+
+// DecodeWithMaxSize method decodes the secure cookie value same as `Decode`
+// and checks the value against given max size instead of max cookie size.
+func (m *Manager) DecodeWithMaxSize(value string, maxSize int) ([]byte, error) {
 	// Check cookie max size.
-	if len(value) > m.maxCookieSize {
+	if len(value) > maxSize {
 		return nil, ErrCookieValueIsTooLarge
 	}
 
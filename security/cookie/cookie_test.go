@@ -103,6 +103,30 @@ func TestCookieWithKeys(t *testing.T) {
 	assert.Equal(t, ErrCookieValueIsTooLarge, err)
 }
 
+func TestCookieWithMaxSize(t *testing.T) {
+	cm, err := NewManager(&Options{Name: "aah", MaxAge: 1800}, "eFWLXEewECptbDVXExokRTLONWxrTjfV")
+	assert.Nil(t, err)
+	assert.Equal(t, 4096, cm.MaxCookieSize())
+
+	value := []byte(strings.Repeat("large value ", 500))
+	_, err = cm.Encode(value)
+	assert.Equal(t, ErrCookieValueIsTooLarge, err)
+
+	encodeValue, err := cm.EncodeWithMaxSize(value, 64*1024)
+	assert.Nil(t, err)
+	assert.True(t, len(encodeValue) > cm.MaxCookieSize())
+
+	_, err = cm.Decode(encodeValue)
+	assert.Equal(t, ErrCookieValueIsTooLarge, err)
+
+	r1, err := cm.DecodeWithMaxSize(encodeValue, 64*1024)
+	assert.Nil(t, err)
+	assert.Equal(t, value, r1)
+
+	_, err = cm.EncodeWithMaxSize(value, 1024)
+	assert.Equal(t, ErrCookieValueIsTooLarge, err)
+}
+
 func TestCookieWithKeysRotation(t *testing.T) {
 	opts := &Options{
 		Name:     "aah",
@@ -0,0 +1,303 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/config"
+	"aahframe.work/essentials"
+	"aahframe.work/log"
+	"aahframe.work/security/acrypto"
+	"aahframe.work/security/authc"
+	"aahframe.work/security/authz"
+)
+
+var _ Schemer = (*APIKeyAuth)(nil)
+
+// API key Errors
+var (
+	ErrAPIKeyStoreIsNil = errors.New("apikey: key store is nil")
+)
+
+const apiKeyDelim = "."
+
+type (
+	// APIKeyAuth struct provides aah's OOTB API key auth scheme, typically used
+	// by machine-to-machine callers. API key value is `<key id>.<secret>`, key
+	// details are obtained from `APIKeyStore` by key id and the secret is
+	// compared with stored hash using configured password encoder. For unknown
+	// key id the secret is compared with dummy hash, so response time does not
+	// reveal the existence of key id.
+	//
+	// Secret is compared on every request. `bcrypt` is slow by design for low
+	// entropy passwords; generated API key secrets are random with high
+	// entropy, so cheaper encoder is recommended, such as `pbkdf2` with lower
+	// iteration, refer to `security.password_encoder.pbkdf2 { ... }`.
+	//
+	//	security {
+	//	  auth_schemes {
+	//	    apikey_auth {
+	//	      scheme = "apikey"
+	//
+	//	      # Default value is `X-API-Key`.
+	//	      header = "X-API-Key"
+	//
+	//	      # Optional, API key is read from query parameter if header is
+	//	      # not present. Default value is empty string.
+	//	      query_param = "api_key"
+	//
+	//	      # Default value is `bcrypt`.
+	//	      password_encoder = "bcrypt"
+	//
+	//	      # Optional, file based key store; otherwise supply `APIKeyStore`
+	//	      # via `SetKeyStore`.
+	//	      key_file = "/path/to/apikeys.conf"
+	//	    }
+	//	  }
+	//	}
+	//
+	// Key file format is as follows:
+	//
+	//	<key id> {
+	//	  hash = "$2y$10$2A4GsJ6SmLAMvDe8XmTam.MSkKojdobBVJfIU7GiyoM.lWt.XV3H6"
+	//	  owner = "billing-service"
+	//	  roles = ["reports"]
+	//	  permissions = ["invoice:read"]
+	//	  expires_at = "2020-01-01T00:00:00Z"
+	//	}
+	APIKeyAuth struct {
+		BaseAuth
+		Header     string
+		QueryParam string
+
+		keyStore  APIKeyStore
+		dummyHash []byte
+	}
+
+	// APIKeyStore interface is used to implement the API key lookup for
+	// auth scheme `apikey`. Method `Get` returns nil, nil if the key id does
+	// not exist.
+	APIKeyStore interface {
+		Init(appCfg *config.Config) error
+		Get(id string) (*APIKey, error)
+	}
+
+	// APIKey struct holds the stored API key details. `Hash` is the secret
+	// hashed with `acrypto.PasswordEncoder`, zero value `ExpiresAt` never
+	// expires.
+	APIKey struct {
+		ID          string
+		Hash        []byte
+		Owner       string
+		Roles       []string
+		Permissions []string
+		ExpiresAt   time.Time
+	}
+)
+
+// Init method initializes the API key authentication scheme from `security.auth_schemes`.
+func (a *APIKeyAuth) Init(cfg *config.Config, keyName string) error {
+	a.AppConfig = cfg
+	a.KeyName = keyName
+	a.KeyPrefix = "security.auth_schemes." + keyName
+	a.Name, _ = a.AppConfig.String(a.ConfigKey("scheme"))
+	a.Header = http.CanonicalHeaderKey(a.AppConfig.StringDefault(a.ConfigKey("header"), "X-API-Key"))
+	a.QueryParam = a.AppConfig.StringDefault(a.ConfigKey("query_param"), "")
+
+	if keyFile := a.AppConfig.StringDefault(a.ConfigKey("key_file"), ""); !ess.IsStrEmpty(keyFile) {
+		if err := a.SetKeyStore(&fileAPIKeyStore{file: keyFile}); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if a.passwordEncoder, err = passwordAlgorithm(a.AppConfig, a.KeyPrefix); err != nil {
+		return err
+	}
+
+	// hash of random secret, compared on unknown key id
+	secret := make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, secret); err != nil {
+		return err
+	}
+	a.dummyHash, err = a.passwordEncoder.Generate([]byte(hex.EncodeToString(secret)))
+
+	return err
+}
+
+// SetKeyStore method assigns the given `APIKeyStore` instance to auth scheme.
+func (a *APIKeyAuth) SetKeyStore(store APIKeyStore) error {
+	if store == nil {
+		return ErrAPIKeyStoreIsNil
+	}
+	a.keyStore = store
+	return a.keyStore.Init(a.AppConfig)
+}
+
+// DoAuthenticate method validates the API key against the key store.
+func (a *APIKeyAuth) DoAuthenticate(authcToken *authc.AuthenticationToken) (*authc.AuthenticationInfo, error) {
+	if a.keyStore == nil {
+		log.Warnf("%s: '%s' or key store is not properly configured", a.KeyName, a.ConfigKey("key_file"))
+		return nil, ErrAPIKeyStoreIsNil
+	}
+
+	if authcToken == nil || ess.IsStrEmpty(authcToken.Identity) || ess.IsStrEmpty(authcToken.Credential) {
+		log.Errorf("%s: API key not found on request", a.KeyName)
+		return nil, authc.ErrAuthenticationFailed
+	}
+
+	apiKey, err := a.keyStore.Get(authcToken.Identity)
+	if err != nil {
+		log.Error(err)
+		return nil, authc.ErrInternalServerError
+	}
+	if apiKey == nil {
+		_ = a.passwordEncoder.Compare(a.dummyHash, []byte(authcToken.Credential))
+		log.Errorf("%s: API key [%s] does not exists", a.KeyName, authcToken.Identity)
+		return nil, authc.ErrSubjectNotExists
+	}
+
+	if !a.passwordEncoder.Compare(apiKey.Hash, []byte(authcToken.Credential)) {
+		log.Errorf("%s: API key [%s] secret does not match", a.KeyName, authcToken.Identity)
+		return nil, authc.ErrAuthenticationFailed
+	}
+
+	if apiKey.IsExpired() {
+		log.Errorf("%s: API key [%s] is expired", a.KeyName, authcToken.Identity)
+		return nil, authc.ErrAuthenticationFailed
+	}
+
+	authcInfo := authc.NewAuthenticationInfo()
+	authcInfo.Principals = append(authcInfo.Principals,
+		&authc.Principal{Realm: "APIKey", Claim: "Owner", Value: apiKey.Owner, IsPrimary: true},
+		&authc.Principal{Realm: "APIKey", Claim: "KeyID", Value: apiKey.ID})
+	authcInfo.AuthenticationToken = authcToken
+
+	return authcInfo, nil
+}
+
+// DoAuthorizationInfo method calls registered `Authorizer` with authentication
+// information, otherwise it returns the roles and permissions of the API key.
+func (a *APIKeyAuth) DoAuthorizationInfo(authcInfo *authc.AuthenticationInfo) *authz.AuthorizationInfo {
+	if a.authorizer != nil {
+		return a.BaseAuth.DoAuthorizationInfo(authcInfo)
+	}
+
+	authzInfo := authz.NewAuthorizationInfo()
+	if a.keyStore == nil || authcInfo == nil || authcInfo.Principal("KeyID") == nil {
+		return authzInfo
+	}
+
+	apiKey, err := a.keyStore.Get(authcInfo.Principal("KeyID").Value)
+	if err != nil || apiKey == nil {
+		if err != nil {
+			log.Error(err)
+		}
+		return authzInfo
+	}
+
+	return authzInfo.AddRole(apiKey.Roles...).AddPermissionString(apiKey.Permissions...)
+}
+
+// ExtractAuthenticationToken method extracts the API key from the HTTP
+// request header or query parameter. `AuthenticationToken.Identity` holds the
+// key id and `AuthenticationToken.Credential` holds the secret.
+func (a *APIKeyAuth) ExtractAuthenticationToken(r *ahttp.Request) *authc.AuthenticationToken {
+	value := r.Header.Get(a.Header)
+	if ess.IsStrEmpty(value) && !ess.IsStrEmpty(a.QueryParam) {
+		value = r.QueryValue(a.QueryParam)
+	}
+
+	authcToken := &authc.AuthenticationToken{Scheme: a.Scheme()}
+	if idx := strings.Index(value, apiKeyDelim); idx > 0 {
+		authcToken.Identity = value[:idx]
+		authcToken.Credential = value[idx+1:]
+	}
+	return authcToken
+}
+
+// IsExpired method returns true if the API key is expired otherwise false.
+func (k *APIKey) IsExpired() bool {
+	return !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt)
+}
+
+// GenerateAPIKey method generates a new API key using given password encoder.
+// It returns the API key value to be handed over to the caller and `APIKey`
+// with hashed secret to be saved in the key store. Key value is not
+// recoverable from the `APIKey`.
+func GenerateAPIKey(pe acrypto.PasswordEncoder) (string, *APIKey, error) {
+	if pe == nil {
+		return "", nil, acrypto.ErrPasswordEncoderIsNil
+	}
+
+	b := make([]byte, 40)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", nil, err
+	}
+	id, secret := hex.EncodeToString(b[:8]), hex.EncodeToString(b[8:])
+
+	hash, err := pe.Generate([]byte(secret))
+	if err != nil {
+		return "", nil, err
+	}
+	return id + apiKeyDelim + secret, &APIKey{ID: id, Hash: hash}, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// File API key store
+//___________________________________
+
+var _ APIKeyStore = (*fileAPIKeyStore)(nil)
+
+type fileAPIKeyStore struct {
+	file string
+	keys map[string]*APIKey
+}
+
+func (f *fileAPIKeyStore) Init(appCfg *config.Config) error {
+	keyCfg, err := config.LoadFile(f.file)
+	if err != nil {
+		return err
+	}
+
+	f.keys = make(map[string]*APIKey)
+	for _, id := range keyCfg.Keys() {
+		hash := keyCfg.StringDefault(id+".hash", "")
+		if ess.IsStrEmpty(hash) {
+			return fmt.Errorf("apikey: '%v' key is required", id+".hash")
+		}
+
+		owner := keyCfg.StringDefault(id+".owner", "")
+		if ess.IsStrEmpty(owner) {
+			return fmt.Errorf("apikey: '%v' key is required", id+".owner")
+		}
+
+		apiKey := &APIKey{ID: id, Hash: []byte(hash), Owner: owner}
+		apiKey.Roles, _ = keyCfg.StringList(id + ".roles")
+		apiKey.Permissions, _ = keyCfg.StringList(id + ".permissions")
+		if expiresAt := keyCfg.StringDefault(id+".expires_at", ""); !ess.IsStrEmpty(expiresAt) {
+			if apiKey.ExpiresAt, err = time.Parse(time.RFC3339, expiresAt); err != nil {
+				return fmt.Errorf("apikey: '%v' value is invalid: %v", id+".expires_at", err)
+			}
+		}
+
+		f.keys[id] = apiKey
+	}
+
+	return nil
+}
+
+func (f *fileAPIKeyStore) Get(id string) (*APIKey, error) {
+	return f.keys[id], nil
+}
@@ -0,0 +1,217 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/config"
+	"aahframe.work/security/acrypto"
+	"aahframe.work/security/authc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemeAPIKeyAuthFileStore(t *testing.T) {
+	cfg, _ := config.ParseString(`
+	security {
+	  auth_schemes {
+	    apikey_auth {
+	      scheme = "apikey"
+	      query_param = "api_key"
+	      password_encoder = "bcrypt"
+
+	      # supplied dynamically for test
+	      key_file = "path/to/file"
+	    }
+	  }
+	}
+	`)
+	_ = acrypto.InitPasswordEncoders(cfg)
+
+	apiKeyAuth := New("apikey").(*APIKeyAuth)
+	err := apiKeyAuth.Init(cfg, "apikey_auth")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "does not exists"))
+
+	cfg.SetString("security.auth_schemes.apikey_auth.key_file", filepath.Join(getTestdataPath(), "apikey_auth_keys_error.conf"))
+	assert.Equal(t, errors.New("apikey: 'billing01.owner' key is required"), apiKeyAuth.Init(cfg, "apikey_auth"))
+
+	cfg.SetString("security.auth_schemes.apikey_auth.key_file", filepath.Join(getTestdataPath(), "apikey_auth_keys.conf"))
+	assert.Nil(t, apiKeyAuth.Init(cfg, "apikey_auth"))
+	assert.Equal(t, "apikey", apiKeyAuth.Scheme())
+	assert.Equal(t, "X-Api-Key", apiKeyAuth.Header)
+	assert.Equal(t, "api_key", apiKeyAuth.QueryParam)
+
+	t.Log("API key from header")
+	authcToken := apiKeyAuth.ExtractAuthenticationToken(createTestAPIKeyRequest("billing01.welcome123", ""))
+	assert.Equal(t, "billing01", authcToken.Identity)
+	assert.Equal(t, "welcome123", authcToken.Credential)
+	authcInfo, err := apiKeyAuth.DoAuthenticate(authcToken)
+	assert.Nil(t, err)
+	assert.Equal(t, "billing-service", authcInfo.PrimaryPrincipal().Value)
+	assert.Equal(t, "billing01", authcInfo.Principal("KeyID").Value)
+
+	authzInfo := apiKeyAuth.DoAuthorizationInfo(authcInfo)
+	assert.True(t, authzInfo.HasRole("reports"))
+	assert.True(t, authzInfo.IsPermitted("invoice:write"))
+	assert.False(t, authzInfo.IsPermitted("invoice:delete"))
+
+	t.Log("API key from query param")
+	authcToken = apiKeyAuth.ExtractAuthenticationToken(createTestAPIKeyRequest("", "billing01.welcome123"))
+	assert.Equal(t, "billing01", authcToken.Identity)
+	_, err = apiKeyAuth.DoAuthenticate(authcToken)
+	assert.Nil(t, err)
+
+	t.Log("authentication failures")
+	testcases := []struct {
+		label string
+		key   string
+		err   error
+	}{
+		{"no key", "", authc.ErrAuthenticationFailed},
+		{"without secret", "billing01", authc.ErrAuthenticationFailed},
+		{"invalid secret", "billing01.welcome", authc.ErrAuthenticationFailed},
+		{"expired", "expired01.welcome123", authc.ErrAuthenticationFailed},
+		{"not exists", "unknown01.welcome123", authc.ErrSubjectNotExists},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			authcInfo, err := apiKeyAuth.DoAuthenticate(apiKeyAuth.ExtractAuthenticationToken(createTestAPIKeyRequest(tc.key, "")))
+			assert.Equal(t, tc.err, err)
+			assert.Nil(t, authcInfo)
+		})
+	}
+}
+
+func TestSchemeAPIKeyAuthCustomStore(t *testing.T) {
+	cfg, _ := config.ParseString(`
+	security {
+	  auth_schemes {
+	    apikey_auth {
+	      scheme = "apikey"
+	      header = "X-Service-Key"
+	    }
+	  }
+	}
+	`)
+	_ = acrypto.InitPasswordEncoders(cfg)
+
+	apiKeyAuth := New("apikey").(*APIKeyAuth)
+	assert.Nil(t, apiKeyAuth.Init(cfg, "apikey_auth"))
+	assert.Equal(t, "", apiKeyAuth.QueryParam)
+
+	t.Log("key store is not configured")
+	_, err := apiKeyAuth.DoAuthenticate(&authc.AuthenticationToken{Identity: "id", Credential: "secret"})
+	assert.Equal(t, ErrAPIKeyStoreIsNil, err)
+	assert.Equal(t, ErrAPIKeyStoreIsNil, apiKeyAuth.SetKeyStore(nil))
+	assert.False(t, apiKeyAuth.DoAuthorizationInfo(nil).HasRole("reports"))
+
+	store := &testAPIKeyStore{keys: make(map[string]*APIKey)}
+	assert.Nil(t, apiKeyAuth.SetKeyStore(store))
+
+	t.Log("generated key")
+	_, _, err = GenerateAPIKey(nil)
+	assert.Equal(t, acrypto.ErrPasswordEncoderIsNil, err)
+	key, apiKey, err := GenerateAPIKey(acrypto.PasswordAlgorithm("bcrypt"))
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(key, apiKey.ID+"."))
+	assert.False(t, strings.Contains(string(apiKey.Hash), key[len(apiKey.ID)+1:]))
+	apiKey.Owner = "reporting-service"
+	apiKey.Roles = []string{"reports"}
+	apiKey.ExpiresAt = time.Now().Add(time.Hour)
+	store.keys[apiKey.ID] = apiKey
+
+	r, _ := http.NewRequest(ahttp.MethodGet, "http://localhost:8080/reports", nil)
+	r.Header.Set("X-Service-Key", key)
+	authcInfo, err := apiKeyAuth.DoAuthenticate(apiKeyAuth.ExtractAuthenticationToken(ahttp.ParseRequest(r, &ahttp.Request{})))
+	assert.Nil(t, err)
+	assert.Equal(t, "reporting-service", authcInfo.PrimaryPrincipal().Value)
+	assert.True(t, apiKeyAuth.DoAuthorizationInfo(authcInfo).HasRole("reports"))
+
+	t.Log("authorizer is preferred over key roles")
+	assert.Nil(t, apiKeyAuth.SetAuthorizer(&testJWTAuthentication{}))
+	authzInfo := apiKeyAuth.DoAuthorizationInfo(authcInfo)
+	assert.False(t, authzInfo.HasRole("reports"))
+	assert.True(t, authzInfo.HasRole("user"))
+
+	t.Log("key store error")
+	store.err = errors.New("store unavailable")
+	_, err = apiKeyAuth.DoAuthenticate(&authc.AuthenticationToken{Identity: apiKey.ID, Credential: "secret"})
+	assert.Equal(t, authc.ErrInternalServerError, err)
+}
+
+func TestSchemeAPIKeyAuthUnknownKeyID(t *testing.T) {
+	cfg, _ := config.ParseString(`
+	security {
+	  auth_schemes {
+	    apikey_auth {
+	      scheme = "apikey"
+	      password_encoder = "apikeytest"
+	    }
+	  }
+	}
+	`)
+	encoder := &testAPIKeyEncoder{}
+	assert.Nil(t, acrypto.AddPasswordAlgorithm("apikeytest", encoder))
+
+	apiKeyAuth := New("apikey").(*APIKeyAuth)
+	assert.Nil(t, apiKeyAuth.Init(cfg, "apikey_auth"))
+	assert.Nil(t, apiKeyAuth.SetKeyStore(&testAPIKeyStore{keys: make(map[string]*APIKey)}))
+	assert.Equal(t, 1, encoder.generated)
+
+	t.Log("secret is compared with dummy hash for unknown key id")
+	_, err := apiKeyAuth.DoAuthenticate(&authc.AuthenticationToken{Identity: "unknown", Credential: "secret"})
+	assert.Equal(t, authc.ErrSubjectNotExists, err)
+	assert.Equal(t, 1, encoder.compared)
+}
+
+type testAPIKeyEncoder struct {
+	generated, compared int
+}
+
+func (e *testAPIKeyEncoder) Generate(password []byte) ([]byte, error) {
+	e.generated++
+	return append([]byte("hash:"), password...), nil
+}
+
+func (e *testAPIKeyEncoder) Compare(hash, password []byte) bool {
+	e.compared++
+	return string(hash) == "hash:"+string(password)
+}
+
+type testAPIKeyStore struct {
+	keys map[string]*APIKey
+	err  error
+}
+
+var _ APIKeyStore = (*testAPIKeyStore)(nil)
+
+func (ts *testAPIKeyStore) Init(cfg *config.Config) error {
+	return nil
+}
+
+func (ts *testAPIKeyStore) Get(id string) (*APIKey, error) {
+	if ts.err != nil {
+		return nil, ts.err
+	}
+	return ts.keys[id], nil
+}
+
+func createTestAPIKeyRequest(header, query string) *ahttp.Request {
+	req, _ := http.NewRequest(ahttp.MethodGet, "http://localhost:8080/invoices", nil)
+	if len(header) > 0 {
+		req.Header.Set("X-API-Key", header)
+	}
+	if len(query) > 0 {
+		req.URL.RawQuery = "api_key=" + query
+	}
+	return ahttp.ParseRequest(req, &ahttp.Request{})
+}
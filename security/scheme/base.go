@@ -25,7 +25,7 @@ var _ Schemer = (*BaseAuth)(nil)
 // BaseAuth struct hold base implementation of aah framework's authentication schemes.
 type BaseAuth struct {
 	// Name contains name of the auth scheme.
-	// For e.g.: form, basic, oauth2, generic
+	// For e.g.: form, basic, oauth2, generic, jwt, apikey
 	Name string
 
 	// KeyName value is auth scheme configuration KeyName.
@@ -5,25 +5,133 @@
 package scheme
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
 	"aahframe.work/ahttp"
+	"aahframe.work/cache"
 	"aahframe.work/config"
+	"aahframe.work/essentials"
 	"aahframe.work/log"
 	"aahframe.work/security/authc"
+	"aahframe.work/security/totp"
 )
 
 var _ Schemer = (*FormAuth)(nil)
 
-// FormAuth struct provides aah's OOTB Form Auth scheme.
-type FormAuth struct {
-	BaseAuth
-	IsAlwaysToDefaultTarget bool
-	LoginURL                string
-	LoginSubmitURL          string
-	LoginFailureURL         string
-	DefaultTargetURL        string
-	FieldIdentity           string
-	FieldCredential         string
-}
+// ErrTwoFactorProviderIsNil error is returned when two-factor is enabled and
+// provider is not supplied.
+var ErrTwoFactorProviderIsNil = errors.New("form: two-factor provider is nil")
+
+// ErrTOTPStepStoreIsNil error is returned when given TOTP step store is nil.
+var ErrTOTPStepStoreIsNil = errors.New("form: totp step store is nil")
+
+type (
+	// FormAuth struct provides aah's OOTB Form Auth scheme.
+	//
+	// Optionally TOTP (RFC 6238) two-factor authentication step can be enabled,
+	// after the successful password verification subject is redirected to OTP
+	// page and it is authenticated only after the valid TOTP code or recovery
+	// code.
+	//
+	//	security {
+	//	  auth_schemes {
+	//	    form_auth {
+	//	      scheme = "form"
+	//
+	//	      url {
+	//	        # Default values are `/login/otp.html`, `/login/otp` and
+	//	        # `/login/otp.html?error=true`.
+	//	        otp = "/login/otp.html"
+	//	        otp_submit = "/login/otp"
+	//	        otp_failure = "/login/otp.html?error=true"
+	//	      }
+	//
+	//	      field {
+	//	        # Default value is `otp`.
+	//	        otp = "otp"
+	//	      }
+	//
+	//	      two_factor {
+	//	        # Default value is false.
+	//	        enable = true
+	//
+	//	        # Implementation of `scheme.TwoFactorProvider`.
+	//	        provider = "security/TwoFactorProvider"
+	//
+	//	        # Shown in authenticator app, default value is app `name`.
+	//	        issuer = "My App"
+	//
+	//	        # Default values are 6, `30s` and 1.
+	//	        digits = 6
+	//	        period = "30s"
+	//	        skew = 1
+	//
+	//	        # Invalid code attempts before the subject has to login
+	//	        # again with password. Default value is 5.
+	//	        max_attempts = 5
+	//
+	//	        # Optional, name of the cache from `cache.conf` to record the
+	//	        # used TOTP codes, so the code is rejected on reuse across
+	//	        # application instances. Default is in-memory store.
+	//	        cache = "totp_steps"
+	//	      }
+	//	    }
+	//	  }
+	//	}
+	FormAuth struct {
+		BaseAuth
+		IsAlwaysToDefaultTarget bool
+		LoginURL                string
+		LoginSubmitURL          string
+		LoginFailureURL         string
+		DefaultTargetURL        string
+		FieldIdentity           string
+		FieldCredential         string
+		OTPURL                  string
+		OTPSubmitURL            string
+		OTPFailureURL           string
+		FieldOTP                string
+		TwoFactorMaxAttempts    int
+		TwoFactorCacheName      string
+
+		// TOTP is nil if two-factor authentication is not enabled.
+		TOTP *totp.TOTP
+
+		twoFactorProvider TwoFactorProvider
+		usedSteps         TOTPStepStore
+	}
+
+	// TwoFactorProvider interface is used to supply TOTP secret and to verify
+	// recovery codes of the subject for FormAuth two-factor authentication.
+	TwoFactorProvider interface {
+		// Init method gets called by aah during an application start.
+		Init(appCfg *config.Config) error
+
+		// TOTPSecret method returns base32 encoded TOTP secret of the subject.
+		// Empty value means subject is not enrolled into two-factor, so login
+		// completes with password. Use `totp.GenerateSecret` on enrollment.
+		TOTPSecret(authcInfo *authc.AuthenticationInfo) (string, error)
+
+		// UseRecoveryCode method verifies given recovery code of the subject and
+		// invalidates it on success. Code is normalized via
+		// `totp.NormalizeRecoveryCode`.
+		UseRecoveryCode(authcInfo *authc.AuthenticationInfo, code string) (bool, error)
+	}
+
+	// TOTPStepStore interface is used to record the used TOTP time steps of the
+	// subjects, to reject the reuse of code within its validity (RFC 6238
+	// section 5.2). Default store is in-memory, so it is per application
+	// instance; use `CacheTOTPStepStore` to share it across instances.
+	TOTPStepStore interface {
+		// MarkUsed method records the time step used by the subject for given
+		// validity, it returns false if the step or later one is already used.
+		MarkUsed(subject string, step int64, validity time.Duration) (bool, error)
+	}
+)
 
 // Init method initializes the Form Auth scheme from `security.auth_schemes`.
 func (f *FormAuth) Init(cfg *config.Config, keyName string) error {
@@ -41,8 +149,15 @@ func (f *FormAuth) Init(cfg *config.Config, keyName string) error {
 	f.FieldCredential = f.AppConfig.StringDefault(f.ConfigKey("field.credential"), "password")
 
 	var err error
-	f.passwordEncoder, err = passwordAlgorithm(f.AppConfig, f.KeyPrefix)
-	return err
+	if f.passwordEncoder, err = passwordAlgorithm(f.AppConfig, f.KeyPrefix); err != nil {
+		return err
+	}
+
+	f.TOTP = nil
+	if f.AppConfig.BoolDefault(f.ConfigKey("two_factor.enable"), false) {
+		return f.initTwoFactor()
+	}
+	return nil
 }
 
 // DoAuthenticate method calls the registered `Authenticator` with authentication token.
@@ -78,6 +193,94 @@ func (f *FormAuth) DoAuthenticate(authcToken *authc.AuthenticationToken) (*authc
 	return authcInfo, nil
 }
 
+// IsTwoFactorEnabled method returns true if TOTP two-factor authentication
+// step is enabled otherwise false.
+func (f *FormAuth) IsTwoFactorEnabled() bool {
+	return f.TOTP != nil
+}
+
+// SetTwoFactorProvider method sets the given two-factor provider into form
+// auth scheme.
+func (f *FormAuth) SetTwoFactorProvider(provider TwoFactorProvider) error {
+	if provider == nil {
+		return ErrTwoFactorProviderIsNil
+	}
+	f.twoFactorProvider = provider
+	return f.twoFactorProvider.Init(f.AppConfig)
+}
+
+// SetTOTPStepStore method sets the given store to record the used TOTP time
+// steps.
+func (f *FormAuth) SetTOTPStepStore(store TOTPStepStore) error {
+	if store == nil {
+		return ErrTOTPStepStoreIsNil
+	}
+	f.usedSteps = store
+	return nil
+}
+
+// TwoFactorSecret method returns the TOTP secret of the subject, empty value
+// means second factor is not required for the subject.
+func (f *FormAuth) TwoFactorSecret(authcInfo *authc.AuthenticationInfo) (string, error) {
+	if !f.IsTwoFactorEnabled() {
+		return "", nil
+	}
+	if f.twoFactorProvider == nil {
+		log.Warnf("%s: '%s' is not properly configured in security.conf", f.KeyName, f.ConfigKey("two_factor.provider"))
+		return "", ErrTwoFactorProviderIsNil
+	}
+	return f.twoFactorProvider.TOTPSecret(authcInfo)
+}
+
+// VerifySecondFactor method verifies the given TOTP code or recovery code of
+// the subject. TOTP code is accepted only once within its validity. It returns
+// nil on success otherwise `authc.ErrAuthenticationFailed`.
+func (f *FormAuth) VerifySecondFactor(authcInfo *authc.AuthenticationInfo, code string) error {
+	if authcInfo == nil || authcInfo.PrimaryPrincipal() == nil {
+		log.Errorf("%s: subject primary principal is not found for second factor", f.KeyName)
+		return authc.ErrAuthenticationFailed
+	}
+
+	secret, err := f.TwoFactorSecret(authcInfo)
+	if err != nil {
+		log.Error(err)
+		return authc.ErrAuthenticationFailed
+	}
+	if ess.IsStrEmpty(secret) || ess.IsStrEmpty(code) {
+		return authc.ErrAuthenticationFailed
+	}
+
+	subject := authcInfo.PrimaryPrincipal().Value
+	if step, err := f.TOTP.Validate(secret, code, time.Now()); err == nil {
+		// code is valid for its own step and skew steps on either side
+		ok, err := f.usedSteps.MarkUsed(subject, step, time.Duration(2*f.TOTP.Skew+1)*f.TOTP.Period)
+		if err != nil {
+			log.Error(err)
+			return authc.ErrAuthenticationFailed
+		}
+		if !ok {
+			log.Errorf("%s: subject [%s] TOTP code is already used", f.KeyName, subject)
+			return authc.ErrAuthenticationFailed
+		}
+		return nil
+	}
+
+	// Recovery code
+	if len(code) > f.TOTP.Digits {
+		ok, err := f.twoFactorProvider.UseRecoveryCode(authcInfo, totp.NormalizeRecoveryCode(code))
+		if err != nil {
+			log.Error(err)
+		}
+		if ok {
+			log.Infof("%s: subject [%s] authenticated with recovery code", f.KeyName, subject)
+			return nil
+		}
+	}
+
+	log.Errorf("%s: subject [%s] second factor code does not match", f.KeyName, subject)
+	return authc.ErrAuthenticationFailed
+}
+
 // ExtractAuthenticationToken method extracts the authentication token information
 // from the HTTP request.
 func (f *FormAuth) ExtractAuthenticationToken(r *ahttp.Request) *authc.AuthenticationToken {
@@ -87,3 +290,111 @@ func (f *FormAuth) ExtractAuthenticationToken(r *ahttp.Request) *authc.Authentic
 		Credential: r.FormValue(f.FieldCredential),
 	}
 }
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+func (f *FormAuth) initTwoFactor() error {
+	f.OTPURL = f.AppConfig.StringDefault(f.ConfigKey("url.otp"), "/login/otp.html")
+	f.OTPSubmitURL = f.AppConfig.StringDefault(f.ConfigKey("url.otp_submit"), "/login/otp")
+	f.OTPFailureURL = f.AppConfig.StringDefault(f.ConfigKey("url.otp_failure"), "/login/otp.html?error=true")
+	f.FieldOTP = f.AppConfig.StringDefault(f.ConfigKey("field.otp"), "otp")
+	f.TwoFactorMaxAttempts = f.AppConfig.IntDefault(f.ConfigKey("two_factor.max_attempts"), 5)
+	f.TwoFactorCacheName = f.AppConfig.StringDefault(f.ConfigKey("two_factor.cache"), "")
+
+	t := totp.New(f.AppConfig.StringDefault(f.ConfigKey("two_factor.issuer"), f.AppConfig.StringDefault("name", "")))
+	t.Digits = f.AppConfig.IntDefault(f.ConfigKey("two_factor.digits"), 6)
+	if t.Digits < 6 || t.Digits > 8 {
+		return fmt.Errorf("%s: '%s' value is invalid, valid values are 6 to 8", f.KeyName, f.ConfigKey("two_factor.digits"))
+	}
+	t.Skew = f.AppConfig.IntDefault(f.ConfigKey("two_factor.skew"), 1)
+
+	var err error
+	periodKey := f.ConfigKey("two_factor.period")
+	if t.Period, err = time.ParseDuration(f.AppConfig.StringDefault(periodKey, "30s")); err != nil || t.Period < time.Second {
+		return fmt.Errorf("%s: '%s' value is invalid", f.KeyName, periodKey)
+	}
+
+	f.TOTP = t
+	f.usedSteps = &memoryTOTPStepStore{steps: make(map[string]usedStep)}
+	return nil
+}
+
+// memoryTOTPStepStore holds the last used TOTP time step of the subjects in
+// application instance memory.
+type memoryTOTPStepStore struct {
+	mu    sync.Mutex
+	steps map[string]usedStep
+}
+
+type usedStep struct {
+	step      int64
+	expiresAt time.Time
+}
+
+func (m *memoryTOTPStepStore) MarkUsed(subject string, step int64, validity time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if last, found := m.steps[subject]; found && now.Before(last.expiresAt) && step <= last.step {
+		return false, nil
+	}
+	m.steps[subject] = usedStep{step: step, expiresAt: now.Add(validity)}
+
+	// remove the steps which are no longer valid
+	for k, v := range m.steps {
+		if now.After(v.expiresAt) {
+			delete(m.steps, k)
+		}
+	}
+	return true, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Cache TOTP step store
+//___________________________________
+
+var _ TOTPStepStore = (*CacheTOTPStepStore)(nil)
+
+// CacheTOTPStepStore struct records the used TOTP time steps into aah cache,
+// it is used to share them across application instances, i.e. Redis or
+// Memcache.
+type CacheTOTPStepStore struct {
+	c cache.Cache
+}
+
+// NewCacheTOTPStepStore method creates the TOTP step store for given cache.
+func NewCacheTOTPStepStore(c cache.Cache) (*CacheTOTPStepStore, error) {
+	if c == nil {
+		return nil, ErrTOTPStepStoreIsNil
+	}
+	return &CacheTOTPStepStore{c: c}, nil
+}
+
+// MarkUsed method records the time step used by the subject. Entry of the
+// step is added atomically, since `cache.Cache.Put` does not overwrite
+// existing entry, so the same code is accepted only once. Last used step is
+// recorded to reject the earlier codes within validity.
+func (s *CacheTOTPStepStore) MarkUsed(subject string, step int64, validity time.Duration) (bool, error) {
+	lastKey := "totp:" + subject
+	if last, err := strconv.ParseInt(fmt.Sprint(s.c.Get(lastKey)), 10, 64); err == nil && step <= last {
+		return false, nil
+	}
+
+	err := s.c.Put(lastKey+":"+strconv.FormatInt(step, 10), "1", validity)
+	if err == cache.ErrEntryExists {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err = s.c.Delete(lastKey); err != nil {
+		return false, err
+	}
+	if err = s.c.Put(lastKey, strconv.FormatInt(step, 10), validity); err != nil && err != cache.ErrEntryExists {
+		return false, err
+	}
+	return true, nil
+}
@@ -7,15 +7,20 @@ package scheme
 import (
 	"bytes"
 	"errors"
+	"io/ioutil"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"aahframe.work/ahttp"
+	"aahframe.work/cache"
 	"aahframe.work/config"
+	"aahframe.work/log"
 	"aahframe.work/security/acrypto"
 	"aahframe.work/security/authc"
 	"aahframe.work/security/authz"
+	"aahframe.work/security/totp"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -177,3 +182,153 @@ func TestSchemeEnablePasswordAlgorithm(t *testing.T) {
 	err := formAuth.Init(cfg, "form_auth")
 	assert.True(t, strings.HasPrefix(err.Error(), "'scrypt' password algorithm is not enabled"))
 }
+
+func TestSchemeFormAuthTwoFactor(t *testing.T) {
+	cfg, _ := config.ParseString(`
+	name = "aah app"
+	security {
+	  auth_schemes {
+	    form_auth {
+	      scheme = "form"
+	      two_factor {
+	        enable = true
+	      }
+	    }
+	  }
+	}
+	`)
+	_ = acrypto.InitPasswordEncoders(cfg)
+
+	formAuth := New("form").(*FormAuth)
+	assert.Nil(t, formAuth.Init(cfg, "form_auth"))
+	assert.True(t, formAuth.IsTwoFactorEnabled())
+	assert.Equal(t, "/login/otp.html", formAuth.OTPURL)
+	assert.Equal(t, "/login/otp", formAuth.OTPSubmitURL)
+	assert.Equal(t, "/login/otp.html?error=true", formAuth.OTPFailureURL)
+	assert.Equal(t, "otp", formAuth.FieldOTP)
+	assert.Equal(t, 5, formAuth.TwoFactorMaxAttempts)
+	assert.Equal(t, "aah app", formAuth.TOTP.Issuer)
+	assert.Equal(t, 6, formAuth.TOTP.Digits)
+	assert.Equal(t, 30*time.Second, formAuth.TOTP.Period)
+	assert.Equal(t, 1, formAuth.TOTP.Skew)
+
+	authcInfo := authc.NewAuthenticationInfo()
+	authcInfo.Principals = append(authcInfo.Principals, &authc.Principal{Realm: "database", Value: "jeeva", IsPrimary: true})
+
+	t.Log("two-factor provider is not supplied")
+	_, err := formAuth.TwoFactorSecret(authcInfo)
+	assert.Equal(t, ErrTwoFactorProviderIsNil, err)
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authcInfo, "123456"))
+	assert.Equal(t, ErrTwoFactorProviderIsNil, formAuth.SetTwoFactorProvider(nil))
+
+	secret, err := totp.GenerateSecret()
+	assert.Nil(t, err)
+	provider := &testTwoFactorProvider{secrets: map[string]string{"jeeva": secret}, recoveryCodes: map[string]bool{"abcde-fghjk": true}}
+	assert.Nil(t, formAuth.SetTwoFactorProvider(provider))
+
+	s, err := formAuth.TwoFactorSecret(authcInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, secret, s)
+
+	t.Log("TOTP code")
+	code, err := formAuth.TOTP.Code(secret, time.Now())
+	assert.Nil(t, err)
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authcInfo, ""))
+	assert.Nil(t, formAuth.VerifySecondFactor(authcInfo, code))
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authcInfo, code), "code is accepted only once")
+
+	t.Log("primary principal is not found")
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(nil, code))
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authc.NewAuthenticationInfo(), code))
+
+	t.Log("recovery code")
+	assert.Nil(t, formAuth.VerifySecondFactor(authcInfo, "ABCDE FGHJK"))
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authcInfo, "abcde-fghjk"), "recovery code is one-time use")
+
+	t.Log("subject is not enrolled")
+	delete(provider.secrets, "jeeva")
+	s, err = formAuth.TwoFactorSecret(authcInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, "", s)
+	assert.Equal(t, authc.ErrAuthenticationFailed, formAuth.VerifySecondFactor(authcInfo, code))
+
+	t.Log("two-factor config errors")
+	cfg.SetInt("security.auth_schemes.form_auth.two_factor.digits", 10)
+	assert.Equal(t, errors.New("form_auth: 'security.auth_schemes.form_auth.two_factor.digits' value is invalid, valid values are 6 to 8"),
+		formAuth.Init(cfg, "form_auth"))
+	cfg.SetInt("security.auth_schemes.form_auth.two_factor.digits", 8)
+	cfg.SetString("security.auth_schemes.form_auth.two_factor.period", "30")
+	assert.Equal(t, errors.New("form_auth: 'security.auth_schemes.form_auth.two_factor.period' value is invalid"),
+		formAuth.Init(cfg, "form_auth"))
+
+	cfg.SetBool("security.auth_schemes.form_auth.two_factor.enable", false)
+	assert.Nil(t, formAuth.Init(cfg, "form_auth"))
+	assert.False(t, formAuth.IsTwoFactorEnabled())
+	s, err = formAuth.TwoFactorSecret(authcInfo)
+	assert.Nil(t, err)
+	assert.Equal(t, "", s)
+}
+
+func TestSchemeFormAuthTOTPStepStore(t *testing.T) {
+	formAuth := New("form").(*FormAuth)
+	assert.Equal(t, ErrTOTPStepStoreIsNil, formAuth.SetTOTPStepStore(nil))
+	_, err := NewCacheTOTPStepStore(nil)
+	assert.Equal(t, ErrTOTPStepStoreIsNil, err)
+
+	mgr := cache.NewManager()
+	assert.Nil(t, mgr.AddProvider("inmemory", new(cache.InMemoryProvider)))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(config.NewEmpty(), l))
+	assert.Nil(t, mgr.CreateCache(&cache.Config{Name: "totp_steps", ProviderName: "inmemory"}))
+	defer func() { assert.Nil(t, mgr.Close()) }()
+
+	cacheStore, err := NewCacheTOTPStepStore(mgr.Cache("totp_steps"))
+	assert.Nil(t, err)
+	assert.Nil(t, formAuth.SetTOTPStepStore(cacheStore))
+
+	memStore := &memoryTOTPStepStore{steps: make(map[string]usedStep)}
+	for _, store := range []TOTPStepStore{memStore, cacheStore} {
+		ok, err := store.MarkUsed("jeeva", 100, time.Minute)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+
+		ok, _ = store.MarkUsed("jeeva", 100, time.Minute)
+		assert.False(t, ok, "step is used")
+		ok, _ = store.MarkUsed("jeeva", 99, time.Minute)
+		assert.False(t, ok, "later step is used")
+		ok, _ = store.MarkUsed("other", 100, time.Minute)
+		assert.True(t, ok)
+		ok, _ = store.MarkUsed("jeeva", 101, time.Minute)
+		assert.True(t, ok)
+	}
+
+	ok, _ := memStore.MarkUsed("expired", 100, -time.Second)
+	assert.True(t, ok)
+	ok, _ = memStore.MarkUsed("expired", 100, time.Minute)
+	assert.True(t, ok, "used step is expired")
+	assert.Equal(t, 3, len(memStore.steps))
+}
+
+type testTwoFactorProvider struct {
+	secrets       map[string]string
+	recoveryCodes map[string]bool
+}
+
+var _ TwoFactorProvider = (*testTwoFactorProvider)(nil)
+
+func (tp *testTwoFactorProvider) Init(cfg *config.Config) error {
+	return nil
+}
+
+func (tp *testTwoFactorProvider) TOTPSecret(authcInfo *authc.AuthenticationInfo) (string, error) {
+	return tp.secrets[authcInfo.PrimaryPrincipal().Value], nil
+}
+
+func (tp *testTwoFactorProvider) UseRecoveryCode(authcInfo *authc.AuthenticationInfo, code string) (bool, error) {
+	if tp.recoveryCodes[code] {
+		delete(tp.recoveryCodes, code)
+		return true, nil
+	}
+	return false, nil
+}
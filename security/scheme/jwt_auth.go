@@ -0,0 +1,580 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/config"
+	"aahframe.work/essentials"
+	"aahframe.work/log"
+	"aahframe.work/security/authc"
+	"aahframe.work/security/authz"
+)
+
+var _ Schemer = (*JWTAuth)(nil)
+
+// JWT Errors
+var (
+	ErrJWTMalformed        = errors.New("jwt: token is malformed")
+	ErrJWTUnsupportedAlg   = errors.New("jwt: signing algorithm is not supported")
+	ErrJWTKeyNotFound      = errors.New("jwt: signing key not found")
+	ErrJWTSignatureInvalid = errors.New("jwt: signature is invalid")
+	ErrJWTExpired          = errors.New("jwt: token is expired")
+	ErrJWTMissingExpiry    = errors.New("jwt: token expiry claim is missing")
+	ErrJWTNotValidYet      = errors.New("jwt: token is not valid yet")
+	ErrJWTInvalidIssuer    = errors.New("jwt: token issuer is invalid")
+	ErrJWTInvalidAudience  = errors.New("jwt: token audience is invalid")
+)
+
+const bearerPrefix = "Bearer "
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// JWT Auth Scheme
+//______________________________________________________________________________
+
+// JWTAuth struct provides aah's OOTB JSON Web Token (JWT) bearer auth scheme.
+// It verifies the token signature (HS256, RS256, ES256) and validates the
+// registered claims `exp`, `nbf`, `iss` and `aud`. Token without `exp` claim
+// is rejected unless `validate.require_exp` is set to `false`.
+//
+// Subject principals are mapped from token claims. If `Authenticator` is
+// configured then it is called with verified token, `AuthenticationToken.Identity`
+// holds the principal claim value and `AuthenticationToken.Values` holds the
+// token claims. Likewise subject roles and permissions are mapped from token
+// claims unless `Authorizer` is configured.
+//
+//	security {
+//	  auth_schemes {
+//	    jwt_auth {
+//	      scheme = "jwt"
+//
+//	      # Default value is `Authorization`, token is read as `Bearer <token>`.
+//	      header = "Authorization"
+//
+//	      # Default value is derived from configured signing keys.
+//	      algorithms = ["HS256", "RS256", "ES256"]
+//
+//	      key {
+//	        hmac = "secret value"
+//	        public_key_file = "/path/to/public-key.pem"
+//	        jwks_file = "/path/to/jwks.json"
+//	      }
+//
+//	      validate {
+//	        issuer = "https://auth.example.com"
+//	        audience = ["api.example.com"]
+//	        # Default value is `1m`.
+//	        clock_skew = "1m"
+//	        # Default value is `true`.
+//	        require_exp = true
+//	      }
+//
+//	      claims {
+//	        # Default value is `sub`.
+//	        principal = "sub"
+//	        principals = ["email"]
+//	        roles = "roles"
+//	        permissions = "permissions"
+//	      }
+//	    }
+//	  }
+//	}
+type JWTAuth struct {
+	BaseAuth
+	RealmName       string
+	Header          string
+	Algorithms      []string
+	Issuer          string
+	Audience        []string
+	ClockSkew       time.Duration
+	RequireExp      bool
+	PrincipalClaim  string
+	PrincipalClaims []string
+	RolesClaim      string
+	PermsClaim      string
+
+	keys []*jwtKey
+}
+
+// Init method initializes the JWT authentication scheme from `security.auth_schemes`.
+func (j *JWTAuth) Init(cfg *config.Config, keyName string) error {
+	j.AppConfig = cfg
+	j.KeyName = keyName
+	j.KeyPrefix = "security.auth_schemes." + keyName
+	j.Name, _ = j.AppConfig.String(j.ConfigKey("scheme"))
+
+	j.RealmName = j.AppConfig.StringDefault(j.ConfigKey("realm_name"), "Authentication Required")
+	j.Header = http.CanonicalHeaderKey(j.AppConfig.StringDefault(j.ConfigKey("header"), ahttp.HeaderAuthorization))
+
+	j.keys = make([]*jwtKey, 0)
+	if secret := j.AppConfig.StringDefault(j.ConfigKey("key.hmac"), ""); !ess.IsStrEmpty(secret) {
+		j.keys = append(j.keys, &jwtKey{family: "HS", key: []byte(secret)})
+	}
+
+	if pubKeyFile := j.AppConfig.StringDefault(j.ConfigKey("key.public_key_file"), ""); !ess.IsStrEmpty(pubKeyFile) {
+		k, err := loadPublicKeyFile(pubKeyFile)
+		if err != nil {
+			return fmt.Errorf("%s: %v", j.KeyName, err)
+		}
+		j.keys = append(j.keys, k)
+	}
+
+	if jwksFile := j.AppConfig.StringDefault(j.ConfigKey("key.jwks_file"), ""); !ess.IsStrEmpty(jwksFile) {
+		keys, err := loadJWKSFile(jwksFile)
+		if err != nil {
+			return fmt.Errorf("%s: %v", j.KeyName, err)
+		}
+		j.keys = append(j.keys, keys...)
+	}
+
+	if len(j.keys) == 0 {
+		return j.ConfigError("key")
+	}
+
+	if algs, found := j.AppConfig.StringList(j.ConfigKey("algorithms")); found && len(algs) > 0 {
+		for _, alg := range algs {
+			if _, supported := jwtAlgorithms[alg]; !supported {
+				return fmt.Errorf("%s: '%s' value '%s' is not supported", j.KeyName, j.ConfigKey("algorithms"), alg)
+			}
+		}
+		j.Algorithms = algs
+	} else {
+		j.Algorithms = make([]string, 0)
+		for _, alg := range []string{"HS256", "RS256", "ES256"} {
+			for _, k := range j.keys {
+				if k.family == alg[:2] {
+					j.Algorithms = append(j.Algorithms, alg)
+					break
+				}
+			}
+		}
+	}
+
+	j.Issuer = j.AppConfig.StringDefault(j.ConfigKey("validate.issuer"), "")
+	j.Audience, _ = j.AppConfig.StringList(j.ConfigKey("validate.audience"))
+	if aud := j.AppConfig.StringDefault(j.ConfigKey("validate.audience"), ""); !ess.IsStrEmpty(aud) {
+		j.Audience = []string{aud}
+	}
+
+	var err error
+	skewKey := j.ConfigKey("validate.clock_skew")
+	if j.ClockSkew, err = time.ParseDuration(j.AppConfig.StringDefault(skewKey, "1m")); err != nil {
+		return fmt.Errorf("%s: '%s' value is invalid: %v", j.KeyName, skewKey, err)
+	}
+	j.RequireExp = j.AppConfig.BoolDefault(j.ConfigKey("validate.require_exp"), true)
+
+	j.PrincipalClaim = j.AppConfig.StringDefault(j.ConfigKey("claims.principal"), "sub")
+	j.PrincipalClaims, _ = j.AppConfig.StringList(j.ConfigKey("claims.principals"))
+	j.RolesClaim = j.AppConfig.StringDefault(j.ConfigKey("claims.roles"), "")
+	j.PermsClaim = j.AppConfig.StringDefault(j.ConfigKey("claims.permissions"), "")
+
+	return nil
+}
+
+// DoAuthenticate method verifies the JWT token and maps the token claims into
+// subject principals. Raw token `AuthenticationToken.Credential` is cleared
+// once verified, claims are available in `AuthenticationToken.Values`.
+func (j *JWTAuth) DoAuthenticate(authcToken *authc.AuthenticationToken) (*authc.AuthenticationInfo, error) {
+	if authcToken == nil || ess.IsStrEmpty(authcToken.Credential) {
+		log.Errorf("%s: bearer token not found on request header '%s'", j.KeyName, j.Header)
+		return nil, authc.ErrAuthenticationFailed
+	}
+
+	claims, err := j.Verify(authcToken.Credential)
+	if err != nil {
+		log.Errorf("%s: %v", j.KeyName, err)
+		return nil, authc.ErrAuthenticationFailed
+	}
+
+	identity, _ := claims[j.PrincipalClaim].(string)
+	if ess.IsStrEmpty(identity) {
+		log.Errorf("%s: principal claim '%s' not found in the token", j.KeyName, j.PrincipalClaim)
+		return nil, authc.ErrAuthenticationFailed
+	}
+	authcToken.Identity = identity
+	authcToken.Values = claims
+	authcToken.Credential = ""
+
+	if j.authenticator != nil {
+		authcInfo, err := j.BaseAuth.DoAuthenticate(authcToken)
+		if err != nil {
+			return nil, err
+		}
+		if authcInfo == nil {
+			return nil, authc.ErrSubjectNotExists
+		}
+		if authcInfo.IsLocked || authcInfo.IsExpired {
+			log.Errorf("Subject [%s] is locked or expired", identity)
+			return nil, authc.ErrAuthenticationFailed
+		}
+		return authcInfo, nil
+	}
+
+	authcInfo := authc.NewAuthenticationInfo()
+	authcInfo.Principals = append(authcInfo.Principals,
+		&authc.Principal{Realm: "JWT", Claim: j.PrincipalClaim, Value: identity, IsPrimary: true})
+	for _, claim := range j.PrincipalClaims {
+		if v, ok := claims[claim].(string); ok {
+			authcInfo.Principals = append(authcInfo.Principals, &authc.Principal{Realm: "JWT", Claim: claim, Value: v})
+		}
+	}
+	authcInfo.AuthenticationToken = authcToken
+
+	return authcInfo, nil
+}
+
+// DoAuthorizationInfo method calls registered `Authorizer` with authentication
+// information, otherwise it maps configured roles and permissions claims.
+func (j *JWTAuth) DoAuthorizationInfo(authcInfo *authc.AuthenticationInfo) *authz.AuthorizationInfo {
+	if j.authorizer != nil {
+		return j.BaseAuth.DoAuthorizationInfo(authcInfo)
+	}
+
+	authzInfo := authz.NewAuthorizationInfo()
+	if authcInfo == nil || authcInfo.AuthenticationToken == nil {
+		return authzInfo
+	}
+
+	claims := authcInfo.AuthenticationToken.Values
+	if !ess.IsStrEmpty(j.RolesClaim) {
+		authzInfo.AddRole(claimStrings(claims[j.RolesClaim])...)
+	}
+	if !ess.IsStrEmpty(j.PermsClaim) {
+		authzInfo.AddPermissionString(claimStrings(claims[j.PermsClaim])...)
+	}
+
+	return authzInfo
+}
+
+// ExtractAuthenticationToken method extracts the bearer token from the HTTP
+// request header. Token is supplied as `AuthenticationToken.Credential`.
+func (j *JWTAuth) ExtractAuthenticationToken(r *ahttp.Request) *authc.AuthenticationToken {
+	// Invoke the user provided method if exists for extracting authentication token
+	if ac, found := j.authenticator.(acauthenticator); found {
+		return ac.ExtractAuthenticationToken(r)
+	}
+
+	value := r.Header.Get(j.Header)
+	if len(value) > len(bearerPrefix) && strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
+		value = value[len(bearerPrefix):]
+	} else if j.Header == ahttp.HeaderAuthorization {
+		value = ""
+	}
+
+	return &authc.AuthenticationToken{
+		Scheme:     j.Scheme(),
+		Credential: strings.TrimSpace(value),
+	}
+}
+
+// Verify method verifies the given JWT token signature and validates its
+// registered claims. It returns the token claims on success.
+func (j *JWTAuth) Verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrJWTMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTSegment(parts[0], &header); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if !isStringExists(j.Algorithms, header.Alg) {
+		return nil, ErrJWTUnsupportedAlg
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if err = j.verifySignature(header.Alg, header.Kid, []byte(parts[0]+"."+parts[1]), sig); err != nil {
+		return nil, err
+	}
+
+	claims := make(map[string]interface{})
+	if err = decodeJWTSegment(parts[1], &claims); err != nil {
+		return nil, ErrJWTMalformed
+	}
+
+	if err = j.validateClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+var jwtAlgorithms = map[string]string{"HS256": "HS", "RS256": "RS", "ES256": "ES"}
+
+type jwtKey struct {
+	kid    string
+	family string
+	key    interface{}
+}
+
+func (j *JWTAuth) verifySignature(alg, kid string, signingInput, sig []byte) error {
+	family := jwtAlgorithms[alg]
+	hashed := sha256.Sum256(signingInput)
+	keyFound := false
+	for _, k := range j.keys {
+		if k.family != family || (len(kid) > 0 && len(k.kid) > 0 && k.kid != kid) {
+			continue
+		}
+		keyFound = true
+
+		switch key := k.key.(type) {
+		case []byte:
+			mac := hmac.New(sha256.New, key)
+			_, _ = mac.Write(signingInput)
+			if hmac.Equal(sig, mac.Sum(nil)) {
+				return nil
+			}
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], sig) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			if len(sig) == 64 && key.Curve == elliptic.P256() {
+				r := new(big.Int).SetBytes(sig[:32])
+				s := new(big.Int).SetBytes(sig[32:])
+				if ecdsa.Verify(key, hashed[:], r, s) {
+					return nil
+				}
+			}
+		}
+	}
+
+	if !keyFound {
+		return ErrJWTKeyNotFound
+	}
+	return ErrJWTSignatureInvalid
+}
+
+func (j *JWTAuth) validateClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if exp, found := claims["exp"]; found {
+		v, ok := exp.(float64)
+		if !ok || now.After(time.Unix(int64(v), 0).Add(j.ClockSkew)) {
+			return ErrJWTExpired
+		}
+	} else if j.RequireExp {
+		return ErrJWTMissingExpiry
+	}
+
+	if nbf, found := claims["nbf"]; found {
+		v, ok := nbf.(float64)
+		if !ok || now.Add(j.ClockSkew).Before(time.Unix(int64(v), 0)) {
+			return ErrJWTNotValidYet
+		}
+	}
+
+	if !ess.IsStrEmpty(j.Issuer) {
+		if iss, _ := claims["iss"].(string); iss != j.Issuer {
+			return ErrJWTInvalidIssuer
+		}
+	}
+
+	if len(j.Audience) > 0 {
+		for _, aud := range claimStrings(claims["aud"]) {
+			if isStringExists(j.Audience, aud) {
+				return nil
+			}
+		}
+		return ErrJWTInvalidAudience
+	}
+
+	return nil
+}
+
+// isStringExists method does case-sensitive match, JWT values are case-sensitive.
+func isStringExists(values []string, search string) bool {
+	for _, v := range values {
+		if v == search {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeJWTSegment(seg string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(bytes.NewReader(b)).Decode(v)
+}
+
+// claimStrings method returns claim value as string slice, claim value could
+// be a string (space separated) or JSON array.
+func claimStrings(v interface{}) []string {
+	switch value := v.(type) {
+	case string:
+		return strings.Fields(value)
+	case []string:
+		return value
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return []string{}
+}
+
+func loadPublicKeyFile(file string) (*jwtKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: public key file '%s' is not PEM encoded", file)
+	}
+
+	var pub interface{}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, er := x509.ParseCertificate(block.Bytes)
+		if er != nil {
+			return nil, er
+		}
+		pub = cert.PublicKey
+	case "RSA PUBLIC KEY":
+		pub, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return &jwtKey{family: "RS", key: key}, nil
+	case *ecdsa.PublicKey:
+		return &jwtKey{family: "ES", key: key}, nil
+	}
+	return nil, fmt.Errorf("jwt: public key type '%T' is not supported", pub)
+}
+
+func loadJWKSFile(file string) ([]*jwtKey, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(b)
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(b []byte) ([]*jwtKey, error) {
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &jwks); err != nil {
+		return nil, fmt.Errorf("jwt: unable to parse JWKS: %v", err)
+	}
+
+	keys := make([]*jwtKey, 0, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+
+		var err error
+		key := &jwtKey{kid: k.Kid}
+		switch k.Kty {
+		case "RSA":
+			key.family = "RS"
+			key.key, err = jwkRSAPublicKey(k)
+		case "EC":
+			key.family = "ES"
+			key.key, err = jwkECPublicKey(k)
+		case "oct":
+			key.family = "HS"
+			key.key, err = base64.RawURLEncoding.DecodeString(k.K)
+		default:
+			log.Warnf("jwt: JWKS key type '%s' is not supported, skip it", k.Kty)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt: invalid JWKS key '%s': %v", k.Kid, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func jwkRSAPublicKey(k jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if len(n) == 0 || len(e) == 0 {
+		return nil, errors.New("modulus and exponent are required")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+func jwkECPublicKey(k jwk) (*ecdsa.PublicKey, error) {
+	if k.Crv != "P-256" {
+		return nil, fmt.Errorf("curve '%s' is not supported", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
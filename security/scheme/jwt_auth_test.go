@@ -0,0 +1,368 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/config"
+	"aahframe.work/security/authc"
+	"aahframe.work/security/authz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSchemeJWTAuthHS256(t *testing.T) {
+	jwtAuth := createTestJWTAuth(t, `
+	  key {
+	    hmac = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	  }
+	  validate {
+	    issuer = "https://auth.example.com"
+	    audience = "api.example.com"
+	    clock_skew = "30s"
+	  }
+	  claims {
+	    principals = ["email"]
+	    roles = "roles"
+	    permissions = "scope"
+	  }
+	`)
+	assert.Equal(t, "jwt", jwtAuth.Scheme())
+	assert.Equal(t, []string{"HS256"}, jwtAuth.Algorithms)
+	assert.Equal(t, []string{"api.example.com"}, jwtAuth.Audience)
+	assert.Equal(t, 30*time.Second, jwtAuth.ClockSkew)
+
+	hs256 := func(input []byte) []byte {
+		mac := hmac.New(sha256.New, []byte("eFWLXEewECptbDVXExokRTLONWxrTjfV"))
+		_, _ = mac.Write(input)
+		return mac.Sum(nil)
+	}
+	claims := func(m map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub":   "jeeva",
+			"email": "jeeva@example.com",
+			"iss":   "https://auth.example.com",
+			"aud":   []string{"web.example.com", "api.example.com"},
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{"admin", "manager"},
+			"scope": "users:read users:write",
+		}
+		for k, v := range m {
+			if v == nil {
+				delete(c, k)
+				continue
+			}
+			c[k] = v
+		}
+		return c
+	}
+
+	t.Log("valid token")
+	token := createTestJWT(t, map[string]interface{}{"alg": "HS256", "typ": "JWT"}, claims(nil), hs256)
+	authcToken := jwtAuth.ExtractAuthenticationToken(createTestJWTRequest(ahttp.HeaderAuthorization, "bearer "+token))
+	assert.Equal(t, token, authcToken.Credential)
+	authcInfo, err := jwtAuth.DoAuthenticate(authcToken)
+	assert.Nil(t, err)
+	assert.True(t, jwtAuth.RequireExp)
+	assert.Equal(t, "", authcInfo.AuthenticationToken.Credential, "raw token is cleared")
+	assert.Equal(t, "jeeva", authcInfo.PrimaryPrincipal().Value)
+	assert.Equal(t, "sub", authcInfo.PrimaryPrincipal().Claim)
+	assert.Equal(t, "jeeva@example.com", authcInfo.Principal("email").Value)
+	assert.Equal(t, "jeeva", authcToken.Identity)
+
+	authzInfo := jwtAuth.DoAuthorizationInfo(authcInfo)
+	assert.True(t, authzInfo.HasAllRoles("admin", "manager"))
+	assert.True(t, authzInfo.IsPermittedAll("users:read", "users:write"))
+	assert.False(t, authzInfo.IsPermitted("users:delete"))
+
+	t.Log("expired token within clock skew")
+	token = createTestJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"exp": time.Now().Add(-10 * time.Second).Unix()}), hs256)
+	_, err = jwtAuth.Verify(token)
+	assert.Nil(t, err)
+
+	t.Log("token validation failures")
+	testcases := []struct {
+		label  string
+		header map[string]interface{}
+		claims map[string]interface{}
+		err    error
+	}{
+		{"expired", nil, map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}, ErrJWTExpired},
+		{"missing expiry", nil, map[string]interface{}{"exp": nil}, ErrJWTMissingExpiry},
+		{"not valid yet", nil, map[string]interface{}{"nbf": time.Now().Add(time.Minute).Unix()}, ErrJWTNotValidYet},
+		{"invalid issuer", nil, map[string]interface{}{"iss": "https://other.example.com"}, ErrJWTInvalidIssuer},
+		{"missing issuer", nil, map[string]interface{}{"iss": nil}, ErrJWTInvalidIssuer},
+		{"invalid audience", nil, map[string]interface{}{"aud": "web.example.com"}, ErrJWTInvalidAudience},
+		{"none algorithm", map[string]interface{}{"alg": "none"}, nil, ErrJWTUnsupportedAlg},
+		{"disallowed algorithm", map[string]interface{}{"alg": "RS256"}, nil, ErrJWTUnsupportedAlg},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			header := map[string]interface{}{"alg": "HS256"}
+			for k, v := range tc.header {
+				header[k] = v
+			}
+			_, err := jwtAuth.Verify(createTestJWT(t, header, claims(tc.claims), hs256))
+			assert.Equal(t, tc.err, err)
+		})
+	}
+
+	t.Log("invalid signature and malformed token")
+	token = createTestJWT(t, map[string]interface{}{"alg": "HS256"}, claims(nil), func(input []byte) []byte {
+		return []byte("invalid signature")
+	})
+	_, err = jwtAuth.Verify(token)
+	assert.Equal(t, ErrJWTSignatureInvalid, err)
+	_, err = jwtAuth.Verify("header.payload")
+	assert.Equal(t, ErrJWTMalformed, err)
+	_, err = jwtAuth.Verify(token[:strings.LastIndex(token, ".")] + ".!!!")
+	assert.Equal(t, ErrJWTMalformed, err)
+
+	t.Log("authentication failures")
+	authcInfo, err = jwtAuth.DoAuthenticate(jwtAuth.ExtractAuthenticationToken(createTestJWTRequest(ahttp.HeaderAuthorization, "Basic "+token)))
+	assert.Equal(t, authc.ErrAuthenticationFailed, err)
+	assert.Nil(t, authcInfo)
+	_, err = jwtAuth.DoAuthenticate(jwtAuth.ExtractAuthenticationToken(createTestJWTRequest(ahttp.HeaderAuthorization, "Bearer "+token)))
+	assert.Equal(t, authc.ErrAuthenticationFailed, err)
+	token = createTestJWT(t, map[string]interface{}{"alg": "HS256"}, claims(map[string]interface{}{"sub": nil}), hs256)
+	_, err = jwtAuth.DoAuthenticate(&authc.AuthenticationToken{Credential: token})
+	assert.Equal(t, authc.ErrAuthenticationFailed, err)
+}
+
+func TestSchemeJWTAuthRS256ES256(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jwt_auth")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	ecKey2, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": b64(ecKey.X.Bytes()), "y": b64(ecKey.Y.Bytes())},
+			{"kty": "RSA", "kid": "enc-1", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+			{"kty": "OKP", "kid": "ed-1"},
+		},
+	})
+	jwksFile := filepath.Join(dir, "jwks.json")
+	assert.Nil(t, ioutil.WriteFile(jwksFile, jwks, 0600))
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(&ecKey2.PublicKey)
+	assert.Nil(t, err)
+	pubKeyFile := filepath.Join(dir, "public-key.pem")
+	assert.Nil(t, ioutil.WriteFile(pubKeyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0600))
+
+	jwtAuth := createTestJWTAuth(t, fmt.Sprintf(`
+	  header = "X-Auth-Token"
+	  key {
+	    jwks_file = "%s"
+	    public_key_file = "%s"
+	  }
+	  claims {
+	    principal = "email"
+	    roles = "groups"
+	  }
+	`, jwksFile, pubKeyFile))
+	assert.Equal(t, []string{"RS256", "ES256"}, jwtAuth.Algorithms)
+	assert.Equal(t, 3, len(jwtAuth.keys))
+
+	rs256 := func(key *rsa.PrivateKey) func([]byte) []byte {
+		return func(input []byte) []byte {
+			hashed := sha256.Sum256(input)
+			sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+			return sig
+		}
+	}
+	es256 := func(key *ecdsa.PrivateKey) func([]byte) []byte {
+		return func(input []byte) []byte {
+			hashed := sha256.Sum256(input)
+			r, s, _ := ecdsa.Sign(rand.Reader, key, hashed[:])
+			sig := make([]byte, 64)
+			r.FillBytes(sig[:32])
+			s.FillBytes(sig[32:])
+			return sig
+		}
+	}
+	claims := map[string]interface{}{
+		"email":  "jeeva@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"groups": []string{"admin"},
+	}
+
+	t.Log("RS256 and ES256 with JWKS and public key file")
+	for _, token := range []string{
+		createTestJWT(t, map[string]interface{}{"alg": "RS256", "kid": "rsa-1"}, claims, rs256(rsaKey)),
+		createTestJWT(t, map[string]interface{}{"alg": "RS256"}, claims, rs256(rsaKey)),
+		createTestJWT(t, map[string]interface{}{"alg": "ES256", "kid": "ec-1"}, claims, es256(ecKey)),
+		createTestJWT(t, map[string]interface{}{"alg": "ES256"}, claims, es256(ecKey2)),
+	} {
+		authcInfo, err := jwtAuth.DoAuthenticate(jwtAuth.ExtractAuthenticationToken(createTestJWTRequest("X-Auth-Token", token)))
+		assert.Nil(t, err)
+		assert.Equal(t, "jeeva@example.com", authcInfo.PrimaryPrincipal().Value)
+		assert.True(t, jwtAuth.DoAuthorizationInfo(authcInfo).HasRole("admin"))
+	}
+
+	t.Log("signature failures")
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	_, err = jwtAuth.Verify(createTestJWT(t, map[string]interface{}{"alg": "RS256"}, claims, rs256(otherRSAKey)))
+	assert.Equal(t, ErrJWTSignatureInvalid, err)
+	_, err = jwtAuth.Verify(createTestJWT(t, map[string]interface{}{"alg": "ES256", "kid": "rsa-1"}, claims, es256(ecKey)))
+	assert.Equal(t, ErrJWTSignatureInvalid, err)
+	_, err = jwtAuth.Verify(createTestJWT(t, map[string]interface{}{"alg": "RS256", "kid": "enc-1"}, claims, rs256(rsaKey)))
+	assert.Equal(t, ErrJWTKeyNotFound, err)
+}
+
+func TestSchemeJWTAuthAuthenticator(t *testing.T) {
+	jwtAuth := createTestJWTAuth(t, `
+	  key {
+	    hmac = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	  }
+	  validate {
+	    require_exp = false
+	  }
+	  claims {
+	    roles = "roles"
+	  }
+	`)
+	assert.False(t, jwtAuth.RequireExp)
+	ta := &testJWTAuthentication{}
+	assert.Nil(t, jwtAuth.SetAuthenticator(ta))
+
+	token := createTestJWT(t, map[string]interface{}{"alg": "HS256"}, map[string]interface{}{"sub": "jeeva", "roles": "admin"}, func(input []byte) []byte {
+		mac := hmac.New(sha256.New, []byte("eFWLXEewECptbDVXExokRTLONWxrTjfV"))
+		_, _ = mac.Write(input)
+		return mac.Sum(nil)
+	})
+	authcInfo, err := jwtAuth.DoAuthenticate(jwtAuth.ExtractAuthenticationToken(createTestJWTRequest(ahttp.HeaderAuthorization, "Bearer "+token)))
+	assert.Nil(t, err)
+	assert.Equal(t, "database", authcInfo.PrimaryPrincipal().Realm)
+	assert.Equal(t, "jeeva", authcInfo.PrimaryPrincipal().Value)
+	assert.Equal(t, "admin", ta.claims["roles"])
+	assert.True(t, jwtAuth.DoAuthorizationInfo(authcInfo).HasRole("admin"))
+
+	t.Log("authorizer is preferred over claims")
+	assert.Nil(t, jwtAuth.SetAuthorizer(ta))
+	authzInfo := jwtAuth.DoAuthorizationInfo(authcInfo)
+	assert.False(t, authzInfo.HasRole("admin"))
+	assert.True(t, authzInfo.HasRole("user"))
+
+	t.Log("subject does not exists")
+	ta.notExists = true
+	_, err = jwtAuth.DoAuthenticate(&authc.AuthenticationToken{Credential: token})
+	assert.Equal(t, authc.ErrSubjectNotExists, err)
+}
+
+func TestSchemeJWTAuthInitErrors(t *testing.T) {
+	testcases := []struct {
+		label  string
+		cfgStr string
+		err    error
+	}{
+		{"key required", ``, errors.New("jwt_auth: config 'security.auth_schemes.jwt_auth.key' is required")},
+		{"unsupported algorithm", `key { hmac = "secret"; } algorithms = ["HS512"];`,
+			errors.New("jwt_auth: 'security.auth_schemes.jwt_auth.algorithms' value 'HS512' is not supported")},
+		{"invalid clock skew", `key { hmac = "secret"; } validate { clock_skew = "1 minute"; }`,
+			errors.New(`jwt_auth: 'security.auth_schemes.jwt_auth.validate.clock_skew' value is invalid: time: unknown unit " minute" in duration "1 minute"`)},
+		{"jwks file not exists", `key { jwks_file = "testdata/not-exists.json"; }`,
+			errors.New("jwt_auth: open testdata/not-exists.json: no such file or directory")},
+		{"public key file not PEM", `key { public_key_file = "testdata/basic_auth_file_realm.conf"; }`,
+			errors.New("jwt_auth: jwt: public key file 'testdata/basic_auth_file_realm.conf' is not PEM encoded")},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			cfg, err := config.ParseString(`security { auth_schemes { jwt_auth { scheme = "jwt"; ` + tc.cfgStr + ` } } }`)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.err, New("jwt").Init(cfg, "jwt_auth"))
+		})
+	}
+}
+
+type testJWTAuthentication struct {
+	claims    map[string]interface{}
+	notExists bool
+}
+
+var (
+	_ authc.Authenticator = (*testJWTAuthentication)(nil)
+	_ authz.Authorizer    = (*testJWTAuthentication)(nil)
+)
+
+func (tj *testJWTAuthentication) Init(cfg *config.Config) error {
+	return nil
+}
+
+func (tj *testJWTAuthentication) GetAuthenticationInfo(authcToken *authc.AuthenticationToken) (*authc.AuthenticationInfo, error) {
+	if tj.notExists {
+		return nil, nil
+	}
+	tj.claims = authcToken.Values
+	authcInfo := authc.NewAuthenticationInfo()
+	authcInfo.Principals = append(authcInfo.Principals, &authc.Principal{Realm: "database", Value: authcToken.Identity, IsPrimary: true})
+	return authcInfo, nil
+}
+
+func (tj *testJWTAuthentication) GetAuthorizationInfo(authcInfo *authc.AuthenticationInfo) *authz.AuthorizationInfo {
+	return authz.NewAuthorizationInfo().AddRole("user")
+}
+
+func createTestJWTAuth(t *testing.T, cfgStr string) *JWTAuth {
+	cfg, err := config.ParseString(`
+	security {
+	  auth_schemes {
+	    jwt_auth {
+	      scheme = "jwt"
+	      ` + cfgStr + `
+	    }
+	  }
+	}`)
+	assert.Nil(t, err)
+
+	jwtAuth := New("jwt").(*JWTAuth)
+	assert.Nil(t, jwtAuth.Init(cfg, "jwt_auth"))
+	return jwtAuth
+}
+
+func createTestJWT(t *testing.T, header, claims map[string]interface{}, sign func([]byte) []byte) string {
+	h, err := json.Marshal(header)
+	assert.Nil(t, err)
+	c, err := json.Marshal(claims)
+	assert.Nil(t, err)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(c)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func createTestJWTRequest(hdr, value string) *ahttp.Request {
+	req, _ := http.NewRequest("GET", "http://localhost:8080/users/10010", nil)
+	req.Header.Set(hdr, value)
+	return ahttp.ParseRequest(req, &ahttp.Request{})
+}
@@ -6,6 +6,7 @@ package scheme
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
@@ -58,23 +59,58 @@ var (
 	ErrOAuth2InvalidState       = errors.New("oauth2: invalid state")
 	ErrOAuth2Exchange           = errors.New("oauth2: exchange failed, unable to get token")
 	ErrOAuth2TokenIsValid       = errors.New("oauth2: token is vaild")
+	ErrOAuth2MissingIDToken     = errors.New("oauth2: id_token is missing in the token response")
+	ErrOAuth2InvalidIDToken     = errors.New("oauth2: id_token is invalid")
+	ErrOAuth2InvalidNonce       = errors.New("oauth2: id_token nonce is invalid")
 )
 
+// KeyOIDCClaims key name is used to supply verified OpenID Connect ID token
+// claims to method `OAuth2.Principal` via `ess.Valuer`.
+const KeyOIDCClaims = "_aahOIDCClaims"
+
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // OAuth2 Auth Scheme
 //______________________________________________________________________________
 
 // OAuth2 auth scheme implementation for the aah framework.
+//
+// Optionally it supports PKCE (RFC 7636) and OpenID Connect. OpenID Connect
+// mode is enabled when `client.provider.oidc.issuer` is configured; provider
+// endpoints are read from discovery metadata and ID token is validated.
+//
+//	client {
+//	  # Default value is false.
+//	  pkce = true
+//
+//	  provider {
+//	    oidc {
+//	      issuer = "https://accounts.example.com"
+//
+//	      # Default value is true. Discovery metadata is read from
+//	      # `<issuer>/.well-known/openid-configuration`.
+//	      discovery = true
+//
+//	      # Required when discovery is disabled.
+//	      #jwks_url = "https://accounts.example.com/jwks"
+//
+//	      # Default value is `1m`.
+//	      clock_skew = "1m"
+//	    }
+//	  }
+//	}
 type OAuth2 struct {
 	BaseAuth
 	LoginURL    string
 	RedirectURL string
 	SuccessURL  string
+	PKCE        bool
+	Issuer      string
 
 	redirectUpdated bool
 	signSha         string
 	signKey         []byte
 	oauthCfg        *oauth2.Config
+	oidc            *oidcProvider
 }
 
 // Init method initialize the OAuth2 auth scheme during an application start.
@@ -103,8 +139,19 @@ func (o *OAuth2) Init(appCfg *config.Config, keyName string) error {
 	}
 
 	o.oauthCfg.Scopes, _ = o.AppConfig.StringList(o.ConfigKey("client.scopes"))
+	o.PKCE = o.AppConfig.BoolDefault(o.ConfigKey("client.pkce"), false)
+	o.Issuer = o.AppConfig.StringDefault(o.ConfigKey("client.provider.oidc.issuer"), "")
+	if o.IsOIDC() {
+		if err := o.initOIDC(); err != nil {
+			return err
+		}
+	}
+
 	provider := o.AppConfig.StringDefault(o.ConfigKey("client.provider.name"), "nil")
 	endpoint := inferEndpoint(provider)
+	if o.IsOIDC() && ess.IsStrEmpty(endpoint.AuthURL) {
+		endpoint = o.oidc.endpoint
+	}
 	if ess.IsStrEmpty(endpoint.AuthURL) && ess.IsStrEmpty(endpoint.TokenURL) {
 		authURL := o.AppConfig.StringDefault(o.ConfigKey("client.provider.url.auth"), "")
 		tokenURL := o.AppConfig.StringDefault(o.ConfigKey("client.provider.url.token"), "")
@@ -121,7 +168,7 @@ func (o *OAuth2) Init(appCfg *config.Config, keyName string) error {
 
 	principal := o.AppConfig.StringDefault(o.ConfigKey("principal"), "")
 	authorizer := o.AppConfig.StringDefault(o.ConfigKey("authorizer"), "")
-	if ess.IsStrEmpty(principal) || ess.IsStrEmpty(authorizer) {
+	if (ess.IsStrEmpty(principal) && !o.IsOIDC()) || ess.IsStrEmpty(authorizer) {
 		return fmt.Errorf("%s: '%s' and '%s' are required", o.KeyName, o.ConfigKey("principal"), o.ConfigKey("authorizer"))
 	}
 
@@ -172,12 +219,24 @@ func (o *OAuth2) ProviderAuthURL(r *ahttp.Request) (string, string) {
 	}
 
 	state, signedState := o.generateStateKey()
-	authURL := o.oauthCfg.AuthCodeURL(signedState)
+	opts := make([]oauth2.AuthCodeOption, 0)
+	if o.PKCE {
+		challenge := sha256.Sum256([]byte(o.deriveStateValue("code_verifier", state)))
+		opts = append(opts,
+			oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+	}
+	if o.IsOIDC() {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", o.deriveStateValue("nonce", state)))
+	}
+
+	authURL := o.oauthCfg.AuthCodeURL(signedState, opts...)
 	return state, authURL
 }
 
 // ValidateCallback method validates the incoming OAuth2 provider redirect request
-// and gets Access token from OAuth2 provider.
+// and gets Access token from OAuth2 provider. In OpenID Connect mode, ID token
+// have to be validated via method `VerifyIDToken`.
 func (o *OAuth2) ValidateCallback(state string, r *ahttp.Request) (*oauth2.Token, error) {
 	callbackState, code := r.FormValue("state"), r.FormValue("code")
 	if ess.IsStrEmpty(callbackState) || ess.IsStrEmpty(code) {
@@ -190,7 +249,11 @@ func (o *OAuth2) ValidateCallback(state string, r *ahttp.Request) (*oauth2.Token
 	}
 
 	// Now get the access token
-	token, err := o.oauthCfg.Exchange(context.TODO(), code)
+	opts := make([]oauth2.AuthCodeOption, 0)
+	if o.PKCE {
+		opts = append(opts, oauth2.SetAuthURLParam("code_verifier", o.deriveStateValue("code_verifier", state)))
+	}
+	token, err := o.oauthCfg.Exchange(context.TODO(), code, opts...)
 	if err != nil {
 		return nil, ErrOAuth2Exchange
 	}
@@ -199,8 +262,15 @@ func (o *OAuth2) ValidateCallback(state string, r *ahttp.Request) (*oauth2.Token
 }
 
 // Principal method calls the registered interface `SubjectPrincipalProvider`
-// to obtain Subject principals.
+// to obtain Subject principals. In OpenID Connect mode, if principal provider
+// is not registered then principals are populated from standard claims of
+// ID token.
 func (o *OAuth2) Principal(keyName string, v ess.Valuer) ([]*authc.Principal, error) {
+	if o.principalProvider == nil && o.IsOIDC() && v != nil {
+		if claims, ok := v.Get(KeyOIDCClaims).(map[string]interface{}); ok {
+			return oidcPrincipals(claims), nil
+		}
+	}
 	if o.principalProvider == nil {
 		return nil, fmt.Errorf("%s: '%s.provider.principal' not configured properly", o.Scheme(), o.KeyPrefix)
 	}
@@ -216,6 +286,12 @@ func (o *OAuth2) generateStateKey() (string, string) {
 	return state, base64.RawURLEncoding.EncodeToString(acrypto.Sign(o.signKey, []byte(state), o.signSha))
 }
 
+// deriveStateValue method derives the PKCE code verifier and OpenID Connect
+// nonce from state value, so it does not have to be stored separately.
+func (o *OAuth2) deriveStateValue(purpose, state string) string {
+	return base64.RawURLEncoding.EncodeToString(acrypto.Sign(o.signKey, []byte(purpose+":"+state), o.signSha))
+}
+
 func (o *OAuth2) validateStateKey(state, signedState string) bool {
 	b, err := base64.RawURLEncoding.DecodeString(signedState)
 	if err != nil {
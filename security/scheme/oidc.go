@@ -0,0 +1,247 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"aahframe.work/essentials"
+	"aahframe.work/log"
+	"aahframe.work/security/authc"
+	"golang.org/x/oauth2"
+)
+
+// oidcStandardClaims are mapped into subject principals in the same order,
+// claim `sub` is the primary principal.
+var oidcStandardClaims = []string{"email", "preferred_username", "name", "given_name", "family_name"}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// OAuth2 OpenID Connect methods
+//______________________________________________________________________________
+
+// IsOIDC method returns true if OAuth2 scheme is configured with OpenID
+// Connect provider otherwise false.
+func (o *OAuth2) IsOIDC() bool {
+	return !ess.IsStrEmpty(o.Issuer)
+}
+
+// VerifyIDToken method validates the ID token from given OAuth2 token response,
+// i.e. signature via provider JWKS, nonce, issuer, audience and expiry. `state`
+// is the value returned by method `ProviderAuthURL`. It returns verified ID
+// token claims on success.
+func (o *OAuth2) VerifyIDToken(state string, token *oauth2.Token) (map[string]interface{}, error) {
+	if !o.IsOIDC() || token == nil {
+		return nil, ErrOAuth2MissingIDToken
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if ess.IsStrEmpty(rawIDToken) {
+		return nil, ErrOAuth2MissingIDToken
+	}
+
+	claims, err := o.oidc.verify(rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// `sub` and `exp` are required claims of ID token
+	sub, _ := claims["sub"].(string)
+	if _, found := claims["exp"]; !found || ess.IsStrEmpty(sub) {
+		return nil, ErrOAuth2InvalidIDToken
+	}
+
+	if azp, found := claims["azp"]; found && azp != o.oauthCfg.ClientID {
+		return nil, ErrOAuth2InvalidIDToken
+	}
+
+	nonce, _ := claims["nonce"].(string)
+	if !hmac.Equal([]byte(nonce), []byte(o.deriveStateValue("nonce", state))) {
+		return nil, ErrOAuth2InvalidNonce
+	}
+
+	return claims, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+type oidcProvider struct {
+	issuer    string
+	clientID  string
+	jwksURL   string
+	clockSkew time.Duration
+	endpoint  oauth2.Endpoint
+	client    *http.Client
+
+	mu        sync.RWMutex
+	verifier  *JWTAuth
+	fetchedAt time.Time
+
+	// refreshMu serializes the JWKS refresh on unknown key id, attemptedAt
+	// limits it to once a minute even if the refresh fails.
+	refreshMu   sync.Mutex
+	attemptedAt time.Time
+}
+
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+func (o *OAuth2) initOIDC() error {
+	o.oidc = &oidcProvider{
+		issuer:   o.Issuer,
+		clientID: o.oauthCfg.ClientID,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+
+	var err error
+	skewKey := o.ConfigKey("client.provider.oidc.clock_skew")
+	if o.oidc.clockSkew, err = time.ParseDuration(o.AppConfig.StringDefault(skewKey, "1m")); err != nil {
+		return fmt.Errorf("%s: '%s' value is invalid: %v", o.KeyName, skewKey, err)
+	}
+
+	if o.AppConfig.BoolDefault(o.ConfigKey("client.provider.oidc.discovery"), true) {
+		if err = o.oidc.discover(); err != nil {
+			return fmt.Errorf("%s: %v", o.KeyName, err)
+		}
+	}
+
+	o.oidc.jwksURL = o.AppConfig.StringDefault(o.ConfigKey("client.provider.oidc.jwks_url"), o.oidc.jwksURL)
+	if ess.IsStrEmpty(o.oidc.jwksURL) {
+		return o.ConfigError("client.provider.oidc.jwks_url")
+	}
+
+	if err = o.oidc.refreshKeys(); err != nil {
+		return fmt.Errorf("%s: %v", o.KeyName, err)
+	}
+
+	if !isStringExists(o.oauthCfg.Scopes, "openid") {
+		o.oauthCfg.Scopes = append([]string{"openid"}, o.oauthCfg.Scopes...)
+	}
+
+	return nil
+}
+
+func (p *oidcProvider) discover() error {
+	var metadata oidcMetadata
+	if err := p.getJSON(strings.TrimSuffix(p.issuer, "/")+"/.well-known/openid-configuration", &metadata); err != nil {
+		return err
+	}
+
+	if metadata.Issuer != p.issuer {
+		return fmt.Errorf("oidc: discovery issuer '%s' does not match with configured issuer '%s'", metadata.Issuer, p.issuer)
+	}
+
+	p.endpoint = oauth2.Endpoint{AuthURL: metadata.AuthorizationEndpoint, TokenURL: metadata.TokenEndpoint}
+	p.jwksURL = metadata.JWKSURI
+	return nil
+}
+
+// refreshStaleKeys method refreshes the JWKS once for the concurrent callers,
+// unless keys are refreshed after given fetched time or refresh is attempted
+// within a minute.
+func (p *oidcProvider) refreshStaleKeys(fetchedAt time.Time) error {
+	p.refreshMu.Lock()
+	defer p.refreshMu.Unlock()
+
+	p.mu.RLock()
+	refreshed := p.fetchedAt.After(fetchedAt)
+	p.mu.RUnlock()
+	if refreshed || time.Since(p.attemptedAt) <= time.Minute {
+		return nil
+	}
+	p.attemptedAt = time.Now()
+	return p.refreshKeys()
+}
+
+func (p *oidcProvider) refreshKeys() error {
+	var raw json.RawMessage
+	if err := p.getJSON(p.jwksURL, &raw); err != nil {
+		return err
+	}
+
+	keys, err := parseJWKS(raw)
+	if err != nil {
+		return err
+	}
+
+	verifier := &JWTAuth{
+		Algorithms: []string{"RS256", "ES256"},
+		Issuer:     p.issuer,
+		Audience:   []string{p.clientID},
+		ClockSkew:  p.clockSkew,
+		keys:       keys,
+	}
+
+	p.mu.Lock()
+	p.verifier = verifier
+	p.fetchedAt = time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// verify method verifies the ID token, provider keys are fetched again on
+// unknown signing key (i.e. key rotation) at most once in a minute.
+func (p *oidcProvider) verify(rawIDToken string) (map[string]interface{}, error) {
+	p.mu.RLock()
+	verifier, fetchedAt := p.verifier, p.fetchedAt
+	p.mu.RUnlock()
+
+	claims, err := verifier.Verify(rawIDToken)
+	if err == ErrJWTKeyNotFound && time.Since(fetchedAt) > time.Minute {
+		if er := p.refreshStaleKeys(fetchedAt); er != nil {
+			log.Error(er)
+			return nil, err
+		}
+		p.mu.RLock()
+		verifier = p.verifier
+		p.mu.RUnlock()
+		claims, err = verifier.Verify(rawIDToken)
+	}
+	return claims, err
+}
+
+func (p *oidcProvider) getJSON(u string, v interface{}) error {
+	resp, err := p.client.Get(u)
+	if err != nil {
+		return fmt.Errorf("oidc: %v", err)
+	}
+	defer ess.CloseQuietly(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("oidc: unexpected response status '%s' from '%s'", resp.Status, u)
+	}
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("oidc: %v", err)
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("oidc: unable to parse response from '%s': %v", u, err)
+	}
+	return nil
+}
+
+func oidcPrincipals(claims map[string]interface{}) []*authc.Principal {
+	sub, _ := claims["sub"].(string)
+	principals := []*authc.Principal{{Realm: "OIDC", Claim: "sub", Value: sub, IsPrimary: true}}
+	for _, claim := range oidcStandardClaims {
+		if v, ok := claims[claim].(string); ok && !ess.IsStrEmpty(v) {
+			principals = append(principals, &authc.Principal{Realm: "OIDC", Claim: claim, Value: v})
+		}
+	}
+	return principals
+}
@@ -0,0 +1,331 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package scheme
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/ahttp"
+	"aahframe.work/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuth2OIDCLifeCycle(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	defer idp.Close()
+
+	oauth := createTestOIDCAuth(t, idp.URL, "", "", `pkce = true`)
+	assert.True(t, oauth.IsOIDC())
+	assert.True(t, oauth.PKCE)
+	assert.Equal(t, idp.URL+"/authorize", oauth.Config().Endpoint.AuthURL)
+	assert.Equal(t, idp.URL+"/token", oauth.Config().Endpoint.TokenURL)
+	assert.Equal(t, []string{"openid", "email"}, oauth.Config().Scopes)
+
+	t.Log("auth URL carries PKCE challenge and nonce")
+	state, authURL := oauth.ProviderAuthURL(ahttp.AcquireRequest(httptest.NewRequest("GET", "http://localhost:8080/login", nil)))
+	u, err := url.Parse(authURL)
+	assert.Nil(t, err)
+	assert.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	assert.Equal(t, 43, len(u.Query().Get("code_challenge")))
+	assert.Equal(t, oauth.deriveStateValue("nonce", state), u.Query().Get("nonce"))
+
+	callback := func(claims map[string]interface{}) (map[string]interface{}, error) {
+		idp.authorize(u.Query(), claims)
+		r := httptest.NewRequest("GET", authURL+"&code=authcode", nil)
+		token, err := oauth.ValidateCallback(state, ahttp.AcquireRequest(r))
+		if err != nil {
+			return nil, err
+		}
+		return oauth.VerifyIDToken(state, token)
+	}
+
+	t.Log("valid ID token")
+	claims, err := callback(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "248289761001", claims["sub"])
+	assert.Equal(t, idp.challenge, idp.verifierChallenge, "PKCE code verifier matches with challenge")
+
+	principals, err := oauth.Principal("oidc_auth", testValuer{KeyOIDCClaims: claims})
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(principals))
+	assert.True(t, principals[0].IsPrimary)
+	assert.Equal(t, "248289761001", principals[0].Value)
+	assert.Equal(t, "jeeva@example.com", principals[1].Value)
+	assert.Equal(t, "name", principals[2].Claim)
+
+	t.Log("ID token validation failures")
+	testcases := []struct {
+		label  string
+		claims map[string]interface{}
+		err    error
+	}{
+		{"invalid nonce", map[string]interface{}{"nonce": "other-nonce"}, ErrOAuth2InvalidNonce},
+		{"invalid issuer", map[string]interface{}{"iss": "https://other.example.com"}, ErrJWTInvalidIssuer},
+		{"invalid audience", map[string]interface{}{"aud": "other-client"}, ErrJWTInvalidAudience},
+		{"invalid authorized party", map[string]interface{}{"azp": "other-client"}, ErrOAuth2InvalidIDToken},
+		{"expired", map[string]interface{}{"exp": time.Now().Add(-2 * time.Minute).Unix()}, ErrJWTExpired},
+		{"missing expiry", map[string]interface{}{"exp": nil}, ErrOAuth2InvalidIDToken},
+		{"missing subject", map[string]interface{}{"sub": nil}, ErrOAuth2InvalidIDToken},
+		{"missing ID token", map[string]interface{}{"_omit": true}, ErrOAuth2MissingIDToken},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			_, err := callback(tc.claims)
+			assert.Equal(t, tc.err, err)
+		})
+	}
+
+	t.Log("provider key rotation")
+	idp.rotateKey(t)
+	_, err = callback(nil)
+	assert.Equal(t, ErrJWTKeyNotFound, err, "keys are not fetched again within a minute")
+	oauth.oidc.fetchedAt = time.Now().Add(-2 * time.Minute)
+	_, err = callback(nil)
+	assert.Nil(t, err)
+
+	t.Log("concurrent tokens of unknown key fetch keys once")
+	idp.rotateKey(t)
+	idp.mu.Lock()
+	idToken, hits := idp.idToken(), idp.jwksHits
+	idp.mu.Unlock()
+	oauth.oidc.fetchedAt = time.Now().Add(-2 * time.Minute)
+	oauth.oidc.attemptedAt = time.Time{}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := oauth.oidc.verify(idToken)
+			assert.Nil(t, err)
+		}()
+	}
+	wg.Wait()
+	idp.mu.Lock()
+	assert.Equal(t, hits+1, idp.jwksHits)
+	idp.mu.Unlock()
+
+	t.Log("unknown key id is not refreshed again within a minute")
+	idp.mu.Lock()
+	kid := idp.kid
+	idp.kid = "unknown"
+	idToken = idp.idToken()
+	idp.kid = kid
+	idp.mu.Unlock()
+	oauth.oidc.fetchedAt = time.Now().Add(-2 * time.Minute)
+	oauth.oidc.attemptedAt = time.Time{}
+	for i := 0; i < 3; i++ {
+		_, err = oauth.oidc.verify(idToken)
+		assert.Equal(t, ErrJWTKeyNotFound, err)
+	}
+	idp.mu.Lock()
+	assert.Equal(t, hits+2, idp.jwksHits)
+	idp.mu.Unlock()
+
+	t.Log("principal provider is not registered and claims not supplied")
+	_, err = oauth.Principal("oidc_auth", testValuer{})
+	assert.NotNil(t, err)
+}
+
+func TestOAuth2OIDCInitError(t *testing.T) {
+	idp := newTestIdentityProvider(t)
+	defer idp.Close()
+
+	testcases := []struct {
+		label, issuer, oidc string
+		err                 error
+	}{
+		{
+			label:  "discovery issuer mismatch",
+			issuer: idp.URL + "/",
+			err:    fmt.Errorf("oidc_auth: oidc: discovery issuer '%s' does not match with configured issuer '%s/'", idp.URL, idp.URL),
+		},
+		{
+			label:  "discovery not found",
+			issuer: idp.URL + "/tenant",
+			err:    fmt.Errorf("oidc_auth: oidc: unexpected response status '404 Not Found' from '%s/tenant/.well-known/openid-configuration'", idp.URL),
+		},
+		{
+			label:  "jwks url required",
+			issuer: idp.URL,
+			oidc:   `discovery = false`,
+			err:    errors.New("oidc_auth: config 'security.auth_schemes.oidc_auth.client.provider.oidc.jwks_url' is required"),
+		},
+		{
+			label:  "invalid clock skew",
+			issuer: idp.URL,
+			oidc:   `clock_skew = "1 minute"`,
+			err:    errors.New(`oidc_auth: 'security.auth_schemes.oidc_auth.client.provider.oidc.clock_skew' value is invalid: time: unknown unit " minute" in duration "1 minute"`),
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			cfg, err := config.ParseString(fmt.Sprintf(testOIDCConfig, tc.issuer, tc.oidc, "", ""))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.err, new(OAuth2).Init(cfg, "oidc_auth"))
+		})
+	}
+
+	t.Log("discovery disabled with explicit provider URLs")
+	oauth := createTestOIDCAuth(t, idp.URL,
+		fmt.Sprintf(`discovery = false; jwks_url = "%s/jwks"`, idp.URL),
+		fmt.Sprintf(`url { auth = "%[1]s/login"; token = "%[1]s/token"; }`, idp.URL), "")
+	assert.Equal(t, idp.URL+"/login", oauth.Config().Endpoint.AuthURL)
+	assert.False(t, oauth.PKCE)
+}
+
+const testOIDCConfig = `
+security {
+  auth_schemes {
+    oidc_auth {
+      scheme = "oauth2"
+      client {
+        id = "aah-client"
+        secret = "clientsecret"
+        sign_key = "5a977494319cde3203fbb49711f08ad2"
+        scopes = ["email"]
+        provider {
+          oidc {
+            issuer = "%s"
+            %s
+          }
+          %s
+        }
+        %s
+      }
+      authorizer = "security/AuthorizationProvider"
+    }
+  }
+}`
+
+func createTestOIDCAuth(t *testing.T, issuer, oidcCfg, providerCfg, clientCfg string) *OAuth2 {
+	cfg, err := config.ParseString(fmt.Sprintf(testOIDCConfig, issuer, oidcCfg, providerCfg, clientCfg))
+	assert.Nil(t, err)
+
+	oauth := new(OAuth2)
+	assert.Nil(t, oauth.Init(cfg, "oidc_auth"))
+	return oauth
+}
+
+type testValuer map[string]interface{}
+
+func (v testValuer) Get(key string) interface{}        { return v[key] }
+func (v testValuer) Set(key string, value interface{}) { v[key] = value }
+
+type testIdentityProvider struct {
+	*httptest.Server
+	mu                sync.Mutex
+	key               *rsa.PrivateKey
+	kid               string
+	nonce             string
+	challenge         string
+	verifierChallenge string
+	claims            map[string]interface{}
+	jwksHits          int
+}
+
+func newTestIdentityProvider(t *testing.T) *testIdentityProvider {
+	idp := &testIdentityProvider{}
+	idp.rotateKey(t)
+	idp.Server = httptest.NewServer(http.HandlerFunc(idp.serveHTTP))
+	return idp
+}
+
+func (idp *testIdentityProvider) rotateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.key = key
+	idp.kid = fmt.Sprintf("key-%d", time.Now().UnixNano())
+}
+
+func (idp *testIdentityProvider) authorize(query url.Values, claims map[string]interface{}) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.nonce = query.Get("nonce")
+	idp.challenge = query.Get("code_challenge")
+	idp.claims = claims
+}
+
+func (idp *testIdentityProvider) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	w.Header().Set(ahttp.HeaderContentType, "application/json")
+	switch r.URL.Path {
+	case "/.well-known/openid-configuration":
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	case "/jwks":
+		idp.jwksHits++
+		b64 := base64.RawURLEncoding.EncodeToString
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{"kty": "RSA", "kid": idp.kid, "use": "sig",
+				"n": b64(idp.key.N.Bytes()), "e": b64(big.NewInt(int64(idp.key.E)).Bytes())}},
+		})
+	case "/token":
+		_ = r.ParseForm()
+		challenge := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		idp.verifierChallenge = base64.RawURLEncoding.EncodeToString(challenge[:])
+
+		resp := map[string]interface{}{
+			"access_token": "EAACmZAkEPRWwBABp3pPRSAww7i4NSIbGHjwmGpR0tuqN29ZCXA2",
+			"token_type":   "bearer",
+			"expires_in":   3600,
+		}
+		if _, omit := idp.claims["_omit"]; !omit {
+			resp["id_token"] = idp.idToken()
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (idp *testIdentityProvider) idToken() string {
+	claims := map[string]interface{}{
+		"iss":   idp.URL,
+		"sub":   "248289761001",
+		"aud":   []string{"aah-client"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+		"iat":   time.Now().Unix(),
+		"nonce": idp.nonce,
+		"email": "jeeva@example.com",
+		"name":  "Jeeva",
+	}
+	for k, v := range idp.claims {
+		if v == nil {
+			delete(claims, k)
+			continue
+		}
+		claims[k] = v
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	h, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": idp.kid})
+	c, _ := json.Marshal(claims)
+	input := b64(h) + "." + b64(c)
+	hashed := sha256.Sum256([]byte(input))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hashed[:])
+	return input + "." + b64(sig)
+}
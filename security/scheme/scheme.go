@@ -29,7 +29,7 @@ type Schemer interface {
 	// For e.g: `security.auth_schemes.<keyname>`.
 	Key() string
 
-	// Scheme method returns auth scheme name. For e.g.: form, basic, oauth2, generic, etc.
+	// Scheme method returns auth scheme name. For e.g.: form, basic, oauth2, generic, jwt, apikey, etc.
 	Scheme() string
 
 	// DoAuthenticate method called by aah SecurityManager to get Subject authentication
@@ -60,6 +60,10 @@ func New(authSchemeType string) Schemer {
 		return &OAuth2{}
 	case "generic":
 		return &GenericAuth{}
+	case "jwt":
+		return &JWTAuth{}
+	case "apikey":
+		return &APIKeyAuth{}
 	}
 	return nil
 }
@@ -22,6 +22,7 @@ import (
 	"aahframe.work/security/authc"
 	"aahframe.work/security/scheme"
 	"aahframe.work/security/session"
+	"aahframe.work/security/throttle"
 )
 
 var (
@@ -81,6 +82,7 @@ type (
 		SessionManager *session.Manager
 		SecureHeaders  *SecureHeaders
 		AntiCSRF       *anticsrf.AntiCSRF
+		LoginThrottle  *throttle.Throttle
 		appCfg         *config.Config
 		authSchemes    map[string]scheme.Schemer
 	}
@@ -121,6 +123,11 @@ func (m *Manager) Init(appCfg *config.Config) error {
 		return err
 	}
 
+	// Initialize Login Throttle
+	if m.LoginThrottle, err = throttle.New(m.appCfg); err != nil {
+		return err
+	}
+
 	// Initialize Auth Schemes
 	keyPrefixAuthScheme := "security.auth_schemes"
 	for _, keyAuthScheme := range m.appCfg.KeysByPath(keyPrefixAuthScheme) {
@@ -284,4 +291,8 @@ func init() {
 	gob.Register(&authc.AuthenticationInfo{})
 	gob.Register(&authc.Principal{})
 	gob.Register(make([]authc.Principal, 0))
+
+	// JWT auth scheme supplies token claims via `AuthenticationToken.Values`
+	gob.Register(make(map[string]interface{}))
+	gob.Register(make([]interface{}, 0))
 }
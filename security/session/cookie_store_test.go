@@ -6,12 +6,15 @@ package session
 
 import (
 	"encoding/gob"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"aahframe.work/ahttp"
+	"aahframe.work/config"
 	"aahframe.work/essentials"
 	"github.com/stretchr/testify/assert"
 )
@@ -51,6 +54,121 @@ func TestSessionCookieStoreSave(t *testing.T) {
   `)
 }
 
+func TestSessionCookieStoreTooLarge(t *testing.T) {
+	m := createTestManager(t, `
+	security {
+	  session {
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+	assert.False(t, m.IsHybrid())
+	assert.Equal(t, 4096, m.maxSize)
+
+	s := m.NewSession()
+	s.Set("large", strings.Repeat("large value ", 500))
+	assert.Equal(t, ErrSessionTooLarge, m.SaveSession(httptest.NewRecorder(), s))
+}
+
+func TestSessionCookieStoreOverflow(t *testing.T) {
+	sessionDir := filepath.Join(getTestdataPath(), "session")
+	defer ess.DeleteFiles(sessionDir)
+
+	m := createTestManager(t, `
+	security {
+	  session {
+	    store {
+	      type = "cookie"
+	      overflow = "file"
+	      filepath = "testdata/session"
+	    }
+
+	    max_size = "16kb"
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+	assert.True(t, m.IsCookieStore())
+	assert.True(t, m.IsHybrid())
+	assert.False(t, m.IsIndexable())
+	assert.Equal(t, 16*1024, m.maxSize)
+
+	t.Log("small session stays in cookie")
+	s := m.NewSession()
+	s.Set("small", "small value")
+	w := httptest.NewRecorder()
+	assert.Nil(t, m.SaveSession(w, s))
+	assert.False(t, s.overflowed)
+	files, _ := ess.FilesPath(sessionDir, true)
+	assert.Equal(t, 0, len(files))
+	rs := m.GetSession(testSessionRequest(w))
+	assert.Equal(t, "small value", rs.GetString("small"))
+
+	t.Log("large session is spilled into overflow store")
+	large := strings.Repeat("large value ", 500)
+	rs.Set("large", large)
+	w = httptest.NewRecorder()
+	assert.Nil(t, m.SaveSession(w, rs))
+	assert.True(t, rs.overflowed)
+	assert.True(t, m.store.IsExists(rs.ID))
+	assert.True(t, strings.HasPrefix(w.Result().Cookies()[0].Value, overflowCookiePrefix))
+	assert.True(t, len(w.Result().Header.Get(ahttp.HeaderSetCookie)) < 512)
+	rs = m.GetSession(testSessionRequest(w))
+	assert.True(t, rs.overflowed)
+	assert.Equal(t, "small value", rs.GetString("small"))
+	assert.Equal(t, large, rs.GetString("large"))
+
+	t.Log("regenerated id moves the overflow entry")
+	oldID := rs.ID
+	w = httptest.NewRecorder()
+	assert.Nil(t, m.RegenerateID(w, rs))
+	assert.False(t, m.store.IsExists(oldID))
+	assert.True(t, m.store.IsExists(rs.ID))
+
+	t.Log("session fits into cookie again")
+	rs = m.GetSession(testSessionRequest(w))
+	rs.Del("large")
+	w = httptest.NewRecorder()
+	assert.Nil(t, m.SaveSession(w, rs))
+	assert.False(t, rs.overflowed)
+	assert.False(t, m.store.IsExists(rs.ID))
+	rs = m.GetSession(testSessionRequest(w))
+	assert.Equal(t, "small value", rs.GetString("small"))
+
+	t.Log("hard cap")
+	rs.Set("large", strings.Repeat(large, 4))
+	assert.Equal(t, ErrSessionTooLarge, m.SaveSession(httptest.NewRecorder(), rs))
+
+	t.Log("overflow store deleted with session")
+	rs.Set("large", large)
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), rs))
+	assert.True(t, m.store.IsExists(rs.ID))
+	rs.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), rs))
+	assert.False(t, m.store.IsExists(rs.ID))
+}
+
+func TestSessionCookieStoreOverflowErrors(t *testing.T) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	for _, c := range []struct {
+		cfg string
+		err string
+	}{
+		{`type = "file"; overflow = "redis";`, "session: store overflow is supported only from cookie store to server-side store"},
+		{`overflow = "cookie";`, "session: store overflow is supported only from cookie store to server-side store"},
+		{`overflow = "myoverflow";`, "session: store name 'myoverflow' not exists"},
+	} {
+		cfg, _ := config.ParseString(`security { session { store {
+			filepath = "testdata/session"; ` + c.cfg + `
+		} } }`)
+		_, err := NewManager(cfg)
+		assert.Equal(t, errors.New(c.err), err)
+	}
+}
+
 func testSessionStoreSave(t *testing.T, cfgStr string) {
 	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
 
@@ -7,8 +7,98 @@ package session
 import (
 	"bytes"
 	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
 )
 
+var (
+	// ErrSessionCodecIsNil returned when suppiled codec is nil.
+	ErrSessionCodecIsNil = errors.New("security/session: codec value is nil")
+
+	registerCodecs = map[string]Codec{
+		"gob":  &GobCodec{},
+		"json": &JSONCodec{},
+	}
+)
+
+// Codec is interface for implementing pluggable session value encoding, it is
+// chosen by config `security.session.codec`. Framework provides `gob` (default)
+// and `json` codec.
+type Codec interface {
+	Encode(v interface{}) ([]byte, error)
+	Decode(dst interface{}, src []byte) error
+}
+
+// AddCodec method allows you to add user created session codec
+// for aah framework application.
+func AddCodec(name string, codec Codec) error {
+	if codec == nil {
+		return ErrSessionCodecIsNil
+	}
+
+	if _, found := registerCodecs[name]; found {
+		return fmt.Errorf("session: codec name '%v' is already added, skip it", name)
+	}
+
+	registerCodecs[name] = codec
+	return nil
+}
+
+func codecByName(name string) (Codec, error) {
+	codec, found := registerCodecs[name]
+	if !found {
+		return nil, fmt.Errorf("session: codec name '%v' not exists", name)
+	}
+	return codec, nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Gob Codec
+//___________________________________
+
+// Codec interface comply
+var _ Codec = (*GobCodec)(nil)
+
+// GobCodec encodes session using `encoding/gob`. Custom types stored in
+// session have to be registered using `gob.Register(...)`.
+type GobCodec struct{}
+
+// Encode method encodes given value into gob bytes.
+func (GobCodec) Encode(v interface{}) ([]byte, error) {
+	return toBytes(v)
+}
+
+// Decode method decodes gob bytes into destination object.
+func (GobCodec) Decode(dst interface{}, src []byte) error {
+	return decodeGob(dst, src)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// JSON Codec
+//___________________________________
+
+// Codec interface comply
+var _ Codec = (*JSONCodec)(nil)
+
+// JSONCodec encodes session using `encoding/json`, so session data is readable
+// from non-Go services. Session values are decoded into generic JSON types,
+// numbers are decoded as `json.Number` and typed getters of `Session` handle
+// them.
+type JSONCodec struct{}
+
+// Encode method encodes given value into JSON bytes.
+func (JSONCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode method decodes JSON bytes into destination object.
+func (JSONCodec) Decode(dst interface{}, src []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	return dec.Decode(dst)
+}
+
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // Encode/Decode Gob methods
 //___________________________________
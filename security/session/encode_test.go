@@ -0,0 +1,125 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"aahframe.work/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionJSONCodec(t *testing.T) {
+	m := createTestManager(t, `
+	security {
+	  session {
+	    codec = "json"
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+	assert.Equal(t, &JSONCodec{}, m.codec)
+	assert.Equal(t, &GobCodec{}, m.prevCodec)
+
+	s := m.NewSession()
+	s.Set("int", 65454523452)
+	s.Set("int64", int64(9007199254740993))
+	s.Set("float32", float32(364.46))
+	s.Set("float64", float64(364534.4637))
+	s.Set("bool", true)
+	s.Set("string", "my key value 1")
+	s.Set("map", map[string]interface{}{"test1": "test1value"})
+	s.SetFlash("my-1", "my 1 flash value")
+
+	rs := testCodecRoundTrip(t, m, s)
+	assert.NotNil(t, rs)
+	assert.Equal(t, s.ID, rs.ID)
+	assert.Equal(t, 65454523452, rs.GetInt("int"))
+	assert.Equal(t, int64(9007199254740993), rs.GetInt64("int64"))
+	assert.Equal(t, float32(364.46), rs.GetFloat32("float32"))
+	assert.Equal(t, float64(364534.4637), rs.GetFloat64("float64"))
+	assert.Equal(t, int64(364534), rs.GetInt64("float64"))
+	assert.Equal(t, float64(65454523452), rs.GetFloat64("int"))
+	assert.True(t, rs.GetBool("bool"))
+	assert.Equal(t, "my key value 1", rs.GetString("string"))
+	assert.Equal(t, "test1value", rs.Get("map").(map[string]interface{})["test1"])
+	assert.Equal(t, "my 1 flash value", rs.GetFlash("my-1"))
+	assert.True(t, s.CreatedTime.Equal(*rs.CreatedTime))
+
+	b, err := m.cookieMgr.Decode(testEncodedSession(t, m, s))
+	assert.Nil(t, err)
+	assert.Contains(t, string(b), `"values":{`)
+}
+
+func TestSessionCodecFallback(t *testing.T) {
+	cfgStr := `
+	security {
+	  session {
+	    %s
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	  }
+	}`
+	gm := createTestManager(t, `security { session { sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; } }`)
+	assert.Equal(t, &GobCodec{}, gm.codec)
+	assert.Nil(t, gm.prevCodec)
+	s := gm.NewSession()
+	s.Set("int", 1024)
+	gobEncoded := testEncodedSession(t, gm, s)
+
+	t.Log("gob encoded session is read by json codec")
+	jm := createTestManager(t, fmt.Sprintf(cfgStr, `codec = "json"`))
+	rs, err := jm.DecodeToSession(gobEncoded)
+	assert.Nil(t, err)
+	assert.Equal(t, s.ID, rs.ID)
+	assert.Equal(t, 1024, rs.GetInt("int"))
+
+	t.Log("json encoded session is read by gob codec with previous codec")
+	jsonEncoded := testEncodedSession(t, jm, rs)
+	gm = createTestManager(t, fmt.Sprintf(cfgStr, `previous_codec = "json"`))
+	rs, err = gm.DecodeToSession(jsonEncoded)
+	assert.Nil(t, err)
+	assert.Equal(t, 1024, rs.GetInt("int"))
+
+	t.Log("previous codec is disabled")
+	jm = createTestManager(t, fmt.Sprintf(cfgStr, `codec = "json"; previous_codec = ""`))
+	assert.Nil(t, jm.prevCodec)
+	_, err = jm.DecodeToSession(gobEncoded)
+	assert.NotNil(t, err)
+}
+
+func TestSessionAddCodec(t *testing.T) {
+	assert.Equal(t, errors.New("session: codec name 'json' is already added, skip it"), AddCodec("json", &JSONCodec{}))
+	assert.Equal(t, ErrSessionCodecIsNil, AddCodec("custom", nil))
+	assert.Nil(t, AddCodec("json2", &JSONCodec{}))
+	defer delete(registerCodecs, "json2")
+
+	m := createTestManager(t, `security { session { codec = "json2"; } }`)
+	assert.Equal(t, &JSONCodec{}, m.codec)
+
+	for _, c := range []string{`codec = "msgpack";`, `previous_codec = "msgpack";`} {
+		cfg, _ := config.ParseString(`security { session { ` + c + ` } }`)
+		_, err := NewManager(cfg)
+		assert.Equal(t, errors.New("session: codec name 'msgpack' not exists"), err)
+	}
+}
+
+func testEncodedSession(t *testing.T, m *Manager, s *Session) string {
+	encoded, err := m.Encode(s)
+	assert.Nil(t, err)
+	return encoded
+}
+
+func testCodecRoundTrip(t *testing.T, m *Manager, s *Session) *Session {
+	w := httptest.NewRecorder()
+	assert.Nil(t, m.SaveSession(w, s))
+	header := http.Header{}
+	header.Add("Cookie", w.Result().Header.Get("Set-Cookie"))
+	return m.GetSession(&http.Request{Header: header})
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+const (
+	// EventOnSessionCreated is published when the new session is saved for the
+	// first time.
+	EventOnSessionCreated = "OnSessionCreated"
+
+	// EventOnSessionDestroyed is published when the session is deleted, i.e.
+	// logout, `Session.Clear()` or revoked by principal.
+	EventOnSessionDestroyed = "OnSessionDestroyed"
+
+	// EventOnSessionExpired is published when the expired session is found on
+	// request or removed by store cleanup.
+	EventOnSessionExpired = "OnSessionExpired"
+
+	// EventOnSessionRegenerated is published when the session ID is regenerated,
+	// `Event.OldID` holds the previous session ID.
+	EventOnSessionRegenerated = "OnSessionRegenerated"
+)
+
+// Event type holds the details of session lifecycle event. Fields `Session`
+// and `Principal` are empty when the session data is not available, for e.g.
+// expired session removed by store cleanup. Do not retain the `Session`
+// beyond event callback, it may be put back to pool.
+type Event struct {
+	Name      string
+	ID        string
+	OldID     string
+	Principal string
+	Session   *Session
+}
+
+// EventPublisher is the signature of session event publisher func. aah
+// application publishes these events into application `EventStore`.
+type EventPublisher func(e *Event)
+
+// SetEventPublisher method sets the session event publisher.
+func (m *Manager) SetEventPublisher(p EventPublisher) {
+	m.publisher = p
+}
+
+// PublishEvent method publishes the given session event via event publisher if
+// it's configured. Custom session store could publish `EventOnSessionExpired`
+// from `Storer.Cleanup`.
+func (m *Manager) PublishEvent(e *Event) {
+	if m.publisher == nil || e == nil {
+		return
+	}
+	if e.Session != nil {
+		if len(e.ID) == 0 {
+			e.ID = e.Session.ID
+		}
+		if len(e.Principal) == 0 {
+			e.Principal = e.Session.Principal
+		}
+	}
+	m.publisher(e)
+}
+
+func (m *Manager) publishEvent(name string, s *Session) {
+	m.PublishEvent(&Event{Name: name, Session: s})
+}
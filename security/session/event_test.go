@@ -0,0 +1,98 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"aahframe.work/essentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionEvents(t *testing.T) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	m := createTestManager(t, `
+	security {
+	  session {
+	    idle_timeout = "1h"
+	    store {
+	      type = "file"
+	      filepath = "testdata/session"
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+
+	t.Log("events are not published without publisher")
+	m.PublishEvent(&Event{Name: EventOnSessionCreated, ID: "id"})
+
+	var events []*Event
+	m.SetEventPublisher(func(e *Event) { events = append(events, e) })
+
+	t.Log("created is published once")
+	s := m.NewSession()
+	s.Principal = "jeeva"
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+	assert.Equal(t, 1, len(events))
+	assert.Equal(t, &Event{Name: EventOnSessionCreated, ID: s.ID, Principal: "jeeva", Session: s}, events[0])
+
+	t.Log("regenerated")
+	oldID := s.ID
+	assert.Nil(t, m.RegenerateID(httptest.NewRecorder(), s))
+	assert.Equal(t, 2, len(events))
+	assert.Equal(t, EventOnSessionRegenerated, events[1].Name)
+	assert.Equal(t, oldID, events[1].OldID)
+	assert.Equal(t, s.ID, events[1].ID)
+
+	t.Log("expired on request")
+	s.IsNew = false
+	past := time.Now().Add(-2 * time.Hour)
+	s.LastAccessTime = &past
+	w := httptest.NewRecorder()
+	assert.Nil(t, m.SaveSession(w, s))
+	assert.Equal(t, 2, len(events))
+	assert.Nil(t, m.GetSession(testSessionRequest(w)))
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, EventOnSessionExpired, events[2].Name)
+	assert.Equal(t, s.ID, events[2].ID)
+	assert.Equal(t, "jeeva", events[2].Principal)
+
+	t.Log("destroyed")
+	s.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+	assert.Equal(t, 4, len(events))
+	assert.Equal(t, EventOnSessionDestroyed, events[3].Name)
+	assert.Equal(t, s.ID, events[3].ID)
+
+	t.Log("destroyed is not published for unsaved session")
+	ns := m.NewSession()
+	ns.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), ns))
+	assert.Equal(t, 4, len(events))
+
+	t.Log("expired on store cleanup")
+	f := m.store.(*FileStore)
+	assert.Nil(t, f.Save("cleanup-id", "value"))
+	assert.Nil(t, os.Chtimes(f.sessionFile("cleanup-id"), past, past))
+	m.store.Cleanup(m)
+	assert.Equal(t, 5, len(events))
+	assert.Equal(t, &Event{Name: EventOnSessionExpired, ID: "cleanup-id"}, events[4])
+}
+
+func testSessionRequest(w *httptest.ResponseRecorder) *http.Request {
+	header := http.Header{}
+	header.Add("Cookie", w.Result().Header.Get("Set-Cookie"))
+	return &http.Request{Header: header}
+}
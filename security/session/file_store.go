@@ -5,11 +5,16 @@
 package session
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+	"time"
 
 	"aahframe.work/config"
 	"aahframe.work/essentials"
@@ -17,14 +22,65 @@ import (
 	"aahframe.work/security/cookie"
 )
 
-// Storer interface comply
-var _ Storer = (*FileStore)(nil)
+const (
+	fileStoreShards     = 256
+	fileStoreTmpPrefix  = ".tmp_"
+	fileStoreLockPrefix = ".lock_"
 
-// FileStore is the aah framework session store implementation.
+	// fileLockStale is the age of lock file, after that it is considered as
+	// left over by crashed process and removed.
+	fileLockStale   = 30 * time.Second
+	fileLockTimeout = 10 * time.Second
+	fileLockWait    = 5 * time.Millisecond
+)
+
+// ErrFileLockTimeout returned when file store is unable to acquire the lock
+// file of session within timeout.
+var ErrFileLockTimeout = errors.New("session: file store lock timeout")
+
+// Storer and SessionIndexer interface comply
+var (
+	_ Storer         = (*FileStore)(nil)
+	_ SessionIndexer = (*FileStore)(nil)
+)
+
+// FileStore is the aah framework session store implementation. Session files
+// are sharded into 256 subdirectories by hash of session id and written
+// atomically (write and rename), so reader never sees partially written file.
+// Each read, save and delete of same session is serialized within the
+// application instance by in-process lock. Save, delete and index updates
+// also take the advisory lock file `.lock_<name>` next to the session file,
+// created exclusively, so the multiple application instances sharing the
+// `filepath` do not interleave. Lock file older than 30 seconds is considered
+// stale and removed. The lock is not held across the request, so concurrent
+// requests of same session are last write wins. Session ids of principal are
+// indexed in the files under `<filepath>/index` directory.
+//
+//	security {
+//	  session {
+//	    store {
+//	      type = "file"
+//	      filepath = "sessions"
+//
+//	      # Cleanup stops after this time budget and resumes from there on
+//	      # next run. Default value is `5s`.
+//	      cleanup_time_budget = "5s"
+//	    }
+//	  }
+//	}
+//
+// Session files of earlier flat directory layout are read and cleaned up too,
+// they are moved into shard directory on save.
 type FileStore struct {
 	path       string
 	filePrefix string
-	m          sync.RWMutex
+	ttl        time.Duration
+	budget     time.Duration
+	locks      *keyLocks
+
+	cleanupMu    sync.Mutex
+	cleanupShard int
+	cleanupFile  string
 }
 
 // Init method initialize the file store using given application config.
@@ -51,9 +107,20 @@ func (f *FileStore) Init(cfg *config.Config) error {
 		}
 	}
 
+	var err error
+	if f.budget, err = parseDuration(cfg, "security.session.store.cleanup_time_budget", "5s"); err != nil {
+		return err
+	}
+	ttl, err := toSeconds(cfg.StringDefault("security.session.ttl", "0m"))
+	if err != nil {
+		return err
+	}
+	f.ttl = time.Duration(ttl) * time.Second
+
 	// session file prefix
 	f.filePrefix = cfg.StringDefault("security.session.prefix", "aah") + "_session"
-	f.m = sync.RWMutex{}
+	f.locks = &keyLocks{locks: make(map[string]*keyLock)}
+	f.cleanupShard, f.cleanupFile = 0, ""
 
 	log.Infof("Session file store is initialized at path: %v", filepath.FromSlash(f.path))
 	return nil
@@ -61,10 +128,11 @@ func (f *FileStore) Init(cfg *config.Config) error {
 
 // Read method reads the encoded cookie value from file.
 func (f *FileStore) Read(id string) string {
-	f.m.RLock()
-	defer f.m.RUnlock()
-	sessionFile := filepath.Join(f.path, f.filePrefix+"_"+id)
-	sdata, err := ioutil.ReadFile(sessionFile)
+	defer f.locks.rlock(id)()
+	sdata, err := ioutil.ReadFile(f.sessionFile(id))
+	if os.IsNotExist(err) {
+		sdata, err = ioutil.ReadFile(f.legacySessionFile(id))
+	}
 	if err != nil {
 		log.Errorf("session: file store - read error: %v", err)
 		return ""
@@ -75,53 +143,362 @@ func (f *FileStore) Read(id string) string {
 
 // Save method saves the given session id with encoded cookie value.
 func (f *FileStore) Save(id, value string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
-	sessionFile := filepath.Join(f.path, f.filePrefix+"_"+id)
-	return ioutil.WriteFile(sessionFile, []byte(value), 0600)
+	sessionFile := f.sessionFile(id)
+	unlock, err := f.lock(id, sessionFile)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := writeFileAtomic(sessionFile, []byte(value)); err != nil {
+		return err
+	}
+	return removeFile(f.legacySessionFile(id))
 }
 
 // Delete method deletes the session file for given id.
 func (f *FileStore) Delete(id string) error {
-	f.m.Lock()
-	defer f.m.Unlock()
-	sessionFile := filepath.Join(f.path, f.filePrefix+"_"+id)
-	if err := os.Remove(sessionFile); !os.IsNotExist(err) {
+	unlock, err := f.lock(id, f.sessionFile(id))
+	if err != nil {
 		return err
 	}
-	return nil
+	defer unlock()
+	if err := removeFile(f.sessionFile(id)); err != nil {
+		return err
+	}
+	return removeFile(f.legacySessionFile(id))
 }
 
 // IsExists method returns true if the session file exists otherwise false.
 func (f *FileStore) IsExists(id string) bool {
-	return ess.IsFileExists(filepath.Join(f.path, f.filePrefix+"_"+id))
+	defer f.locks.rlock(id)()
+	return ess.IsFileExists(f.sessionFile(id)) || ess.IsFileExists(f.legacySessionFile(id))
 }
 
-// Cleanup method deletes the expired session file.
+// Cleanup method deletes the expired session files incrementally, shard by
+// shard. It stops after `cleanup_time_budget` and next run resumes from the
+// file it stopped.
 func (f *FileStore) Cleanup(m *Manager) {
-	files, err := ess.FilesPath(f.path, false)
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+
+	start := time.Now()
+	scanned, cnt := 0, 0
+	// shards and one more position for flat directory of earlier layout
+	for i := 0; i <= fileStoreShards; i++ {
+		if i > 0 && f.budget > 0 && time.Since(start) > f.budget {
+			break
+		}
+		dir := f.path
+		if f.cleanupShard < fileStoreShards {
+			dir = filepath.Join(f.path, fmt.Sprintf("%02x", f.cleanupShard))
+		}
+		s, c, done := f.cleanupDir(m, dir, start)
+		scanned += s
+		cnt += c
+		if !done {
+			break
+		}
+		f.cleanupShard = (f.cleanupShard + 1) % (fileStoreShards + 1)
+		f.cleanupFile = ""
+	}
+
+	log.Infof("%v session files scanned, %v expired session files cleaned up in %v",
+		scanned, cnt, time.Since(start))
+}
+
+// AddIndex method adds the session id into principal's index file.
+func (f *FileStore) AddIndex(principal, id string) error {
+	unlock, err := f.lock(indexLockKey(principal), f.indexFile(principal))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	ids, err := f.readIndex(principal)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
+	}
+	if ess.IsSliceContainsString(ids, id) {
+		return nil
 	}
+	return f.writeIndex(principal, append(ids, id))
+}
 
-	log.Infof("%v session files found", len(files))
-	cnt := 0
+// RemoveIndex method removes the session id from principal's index file.
+func (f *FileStore) RemoveIndex(principal, id string) error {
+	unlock, err := f.lock(indexLockKey(principal), f.indexFile(principal))
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	ids, err := f.readIndex(principal)
+	if err != nil {
+		return err
+	}
+	result := make([]string, 0, len(ids))
+	for _, v := range ids {
+		if v != id {
+			result = append(result, v)
+		}
+	}
+	return f.writeIndex(principal, result)
+}
+
+// IndexedIDs method returns the session ids from principal's index file.
+func (f *FileStore) IndexedIDs(principal string) ([]string, error) {
+	defer f.locks.rlock(indexLockKey(principal))()
+	return f.readIndex(principal)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+func (f *FileStore) sessionFile(id string) string {
+	h := sha1.Sum([]byte(id))
+	return filepath.Join(f.path, hex.EncodeToString(h[:1]), f.filePrefix+"_"+id)
+}
+
+func (f *FileStore) legacySessionFile(id string) string {
+	return filepath.Join(f.path, f.filePrefix+"_"+id)
+}
+
+// cleanupDir method deletes the expired session files in the given directory
+// after the cleanup cursor. It returns false if time budget is exceeded before
+// completion.
+func (f *FileStore) cleanupDir(m *Manager, dir string, start time.Time) (int, int, bool) {
+	files, err := ess.FilesPath(dir, false)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Error(err)
+		}
+		return 0, 0, true
+	}
+
+	scanned, cnt := 0, 0
+	prefix := f.filePrefix + "_"
 	for _, sfile := range files {
-		if sdata, err := ioutil.ReadFile(sfile); err == nil {
-			if _, err := m.DecodeToSession(string(sdata)); err == cookie.ErrCookieTimestampIsExpired {
-				f.m.Lock()
-				if err := os.Remove(sfile); !os.IsNotExist(err) {
-					log.Error(err)
-				} else {
-					cnt++
-				}
-				f.m.Unlock()
+		name := filepath.Base(sfile)
+		if name <= f.cleanupFile {
+			continue
+		}
+		if scanned > 0 && f.budget > 0 && time.Since(start) > f.budget {
+			return scanned, cnt, false
+		}
+		f.cleanupFile = name
+
+		if strings.HasPrefix(name, fileStoreTmpPrefix) || strings.HasPrefix(name, fileStoreLockPrefix) {
+			// leftover of interrupted write or crashed process
+			if info, err := os.Stat(sfile); err == nil && time.Since(info.ModTime()) > time.Hour {
+				_ = os.Remove(sfile)
+			}
+			continue
+		}
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		scanned++
+		id := strings.TrimPrefix(name, prefix)
+		unlock, err := f.lock(id, f.sessionFile(id))
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if f.isExpired(m, sfile) {
+			if err := removeFile(sfile); err != nil {
+				log.Error(err)
+			} else {
+				cnt++
+				m.PublishEvent(&Event{Name: EventOnSessionExpired, ID: id})
 			}
 		}
+		unlock()
 	}
+	return scanned, cnt, true
+}
+
+// isExpired method checks the session expiry by file modification time, which
+// is the last save of session. Session is decoded only for absolute timeout.
+func (f *FileStore) isExpired(m *Manager, sfile string) bool {
+	info, err := os.Stat(sfile)
+	if err != nil {
+		return false
+	}
+	age := time.Since(info.ModTime())
+	if (f.ttl > 0 && age > f.ttl) || (m.idleTimeout > 0 && age > m.idleTimeout) {
+		return true
+	}
+	if m.absoluteTimeout > 0 {
+		sdata, err := ioutil.ReadFile(sfile)
+		if err != nil {
+			return false
+		}
+		s, err := m.DecodeToSession(string(sdata))
+		return err == cookie.ErrCookieTimestampIsExpired || (err == nil && m.IsExpired(s))
+	}
+	return false
+}
+
+func (f *FileStore) indexFile(principal string) string {
+	h := sha1.Sum([]byte(principal))
+	return filepath.Join(f.path, "index", hex.EncodeToString(h[:]))
+}
+
+func (f *FileStore) readIndex(principal string) ([]string, error) {
+	b, err := ioutil.ReadFile(f.indexFile(principal))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	return strings.Fields(string(b)), nil
+}
+
+func (f *FileStore) writeIndex(principal string, ids []string) error {
+	indexFile := f.indexFile(principal)
+	if len(ids) == 0 {
+		return removeFile(indexFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(indexFile), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(indexFile, []byte(strings.Join(ids, "\n")))
+}
+
+// lock method acquires the in-process lock of given key and then the advisory
+// lock file of given file, it returns the unlock func.
+func (f *FileStore) lock(key, name string) (func(), error) {
+	unlock := f.locks.lock(key)
+	unlockFile, err := acquireFileLock(filepath.Join(filepath.Dir(name), fileStoreLockPrefix+filepath.Base(name)))
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	return func() {
+		unlockFile()
+		unlock()
+	}, nil
+}
+
+// acquireFileLock method creates the lock file exclusively, it waits while
+// the lock file is held by other process upto `fileLockTimeout`. Stale lock
+// file is removed.
+func acquireFileLock(name string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(fileLockTimeout)
+	for {
+		lf, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_ = lf.Close()
+			return func() { _ = os.Remove(name) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(name); err == nil && time.Since(info.ModTime()) > fileLockStale {
+			log.Warnf("session: file store - removing stale lock file: %s", name)
+			_ = os.Remove(name)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrFileLockTimeout
+		}
+		time.Sleep(fileLockWait)
+	}
+}
 
-	log.Infof("%v expired session files cleaned up", cnt)
+func indexLockKey(principal string) string {
+	return "index:" + principal
+}
+
+// writeFileAtomic method writes the data into temporary file in the same
+// directory and renames it to given name, so readers never see partial data.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), fileStoreTmpPrefix)
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+func removeFile(name string) error {
+	if err := os.Remove(name); !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Per session lock
+//___________________________________
+
+// keyLocks holds the in-process lock per key, lock is released from map when
+// it is no longer in use.
+type keyLocks struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.RWMutex
+	refs int
+}
+
+func (k *keyLocks) acquire(key string) *keyLock {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, found := k.locks[key]
+	if !found {
+		l = &keyLock{}
+		k.locks[key] = l
+	}
+	l.refs++
+	return l
+}
+
+func (k *keyLocks) release(key string, l *keyLock) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if l.refs--; l.refs == 0 {
+		delete(k.locks, key)
+	}
+}
+
+// lock method acquires the write lock for given key and returns the unlock
+// func.
+func (k *keyLocks) lock(key string) func() {
+	l := k.acquire(key)
+	l.Lock()
+	return func() {
+		l.Unlock()
+		k.release(key, l)
+	}
+}
+
+// rlock method acquires the read lock for given key and returns the unlock
+// func.
+func (k *keyLocks) rlock(key string) func() {
+	l := k.acquire(key)
+	l.RLock()
+	return func() {
+		l.RUnlock()
+		k.release(key, l)
+	}
 }
 
 func init() {
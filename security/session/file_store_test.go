@@ -6,12 +6,16 @@ package session
 
 import (
 	"encoding/gob"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"aahframe.work/ahttp"
 	"aahframe.work/essentials"
@@ -72,6 +76,22 @@ func TestSessionFileStoreSave(t *testing.T) {
   `)
 }
 
+func TestSessionFileStoreIndexer(t *testing.T) {
+	testSessionIndexer(t, `
+	security {
+	  session {
+	    store {
+	      type = "file"
+	      filepath = "testdata/session"
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+}
+
 func TestSessionFileStoreDeleteAndCleanup(t *testing.T) {
 	sessionDir := filepath.Join(getTestdataPath(), "session")
 	defer ess.DeleteFiles(sessionDir)
@@ -111,7 +131,7 @@ func TestSessionFileStoreDeleteAndCleanup(t *testing.T) {
 	assert.False(t, result1.Header.Get(ahttp.HeaderSetCookie) == "")
 
 	// Check session in store
-	files, _ := ess.FilesPath(sessionDir, false)
+	files, _ := ess.FilesPath(sessionDir, true)
 	assert.Equal(t, 1, len(files))
 	assert.True(t, m.store.IsExists(sid))
 
@@ -134,7 +154,176 @@ func TestSessionFileStoreDeleteAndCleanup(t *testing.T) {
 	assert.True(t, strings.Contains(setCookieValue, "Thu, 01 Jan 1970 00:00:01 GMT"))
 
 	// Sesion data should be delete here
-	files, _ = ess.FilesPath(sessionDir, false)
+	files, _ = ess.FilesPath(sessionDir, true)
 	assert.Equal(t, 0, len(files))
 	assert.False(t, m.store.IsExists(sid))
 }
+
+func TestSessionFileStoreShardLayout(t *testing.T) {
+	sessionDir := filepath.Join(getTestdataPath(), "session")
+	defer ess.DeleteFiles(sessionDir)
+
+	m := createTestManager(t, testFileStoreConfig(""))
+	f := m.store.(*FileStore)
+
+	id := "SWkGHtLck_sv7kWKDvvN8mwSq3CPfmkoRkz1POMtnx8"
+	legacyFile := filepath.Join(sessionDir, f.filePrefix+"_"+id)
+	assert.Nil(t, ioutil.WriteFile(legacyFile, []byte("legacy value"), 0600))
+
+	t.Log("legacy flat file is read")
+	assert.True(t, f.IsExists(id))
+	assert.Equal(t, "legacy value", f.Read(id))
+
+	t.Log("save moves the session into shard directory")
+	assert.Nil(t, f.Save(id, "new value"))
+	assert.False(t, ess.IsFileExists(legacyFile))
+	sessionFile := f.sessionFile(id)
+	assert.Equal(t, 2, len(filepath.Base(filepath.Dir(sessionFile))))
+	assert.True(t, ess.IsFileExists(sessionFile))
+	assert.Equal(t, "new value", f.Read(id))
+
+	files, _ := ess.FilesPath(filepath.Dir(sessionFile), false)
+	assert.Equal(t, []string{sessionFile}, files)
+
+	assert.Nil(t, f.Delete(id))
+	assert.False(t, f.IsExists(id))
+	assert.Equal(t, "", f.Read(id))
+	assert.Nil(t, f.Delete(id))
+	assert.Equal(t, 0, len(f.locks.locks))
+}
+
+func TestSessionFileStoreIncrementalCleanup(t *testing.T) {
+	sessionDir := filepath.Join(getTestdataPath(), "session")
+	defer ess.DeleteFiles(sessionDir)
+
+	m := createTestManager(t, testFileStoreConfig(`cleanup_time_budget = "1ns";`))
+	f := m.store.(*FileStore)
+	assert.Equal(t, time.Nanosecond, f.budget)
+	assert.Equal(t, 30*time.Minute, f.ttl)
+
+	expired := time.Now().Add(-time.Hour)
+	for i := 0; i < 20; i++ {
+		id := fmt.Sprintf("session-id-%d", i)
+		assert.Nil(t, f.Save(id, "value"))
+		if i%2 == 0 {
+			assert.Nil(t, os.Chtimes(f.sessionFile(id), expired, expired))
+		}
+	}
+	legacyFile := filepath.Join(sessionDir, f.filePrefix+"_legacy-id")
+	assert.Nil(t, ioutil.WriteFile(legacyFile, []byte("value"), 0600))
+	assert.Nil(t, os.Chtimes(legacyFile, expired, expired))
+	tmpFile := filepath.Join(sessionDir, fileStoreTmpPrefix+"12345")
+	assert.Nil(t, ioutil.WriteFile(tmpFile, []byte("value"), 0600))
+	assert.Nil(t, os.Chtimes(tmpFile, expired, expired))
+
+	t.Log("cleanup stops on time budget and resumes on next run")
+	m.store.Cleanup(m)
+	files, _ := ess.FilesPath(sessionDir, true)
+	assert.True(t, len(files) > 10)
+	for i := 0; i <= fileStoreShards && len(files) > 10; i++ {
+		m.store.Cleanup(m)
+		files, _ = ess.FilesPath(sessionDir, true)
+	}
+	assert.Equal(t, 10, len(files))
+	for i := 0; i < 20; i++ {
+		assert.Equal(t, i%2 != 0, f.IsExists(fmt.Sprintf("session-id-%d", i)))
+	}
+	assert.False(t, ess.IsFileExists(legacyFile))
+	assert.False(t, ess.IsFileExists(tmpFile))
+}
+
+func TestSessionFileStoreConcurrentAccess(t *testing.T) {
+	sessionDir := filepath.Join(getTestdataPath(), "session")
+	defer ess.DeleteFiles(sessionDir)
+
+	m := createTestManager(t, testFileStoreConfig(""))
+	f := m.store.(*FileStore)
+
+	values := []string{strings.Repeat("a", 4096), strings.Repeat("b", 8192)}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.Nil(t, f.Save("concurrent-id", values[(i+j)%2]))
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if v := f.Read("concurrent-id"); v != "" {
+					assert.True(t, v == values[0] || v == values[1])
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	files, _ := ess.FilesPath(sessionDir, true)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, 0, len(f.locks.locks))
+}
+
+func TestSessionFileStoreLockFile(t *testing.T) {
+	sessionDir := filepath.Join(getTestdataPath(), "session")
+	defer ess.DeleteFiles(sessionDir)
+
+	m := createTestManager(t, testFileStoreConfig(""))
+	f := m.store.(*FileStore)
+
+	id := "lock-file-id"
+	sessionFile := f.sessionFile(id)
+	lockFile := filepath.Join(filepath.Dir(sessionFile), fileStoreLockPrefix+filepath.Base(sessionFile))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(lockFile), 0755))
+
+	t.Log("save waits for the lock file held by other process")
+	assert.Nil(t, ioutil.WriteFile(lockFile, []byte{}, 0600))
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = os.Remove(lockFile)
+	}()
+	start := time.Now()
+	assert.Nil(t, f.Save(id, "value"))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.False(t, ess.IsFileExists(lockFile), "lock file is removed on unlock")
+	assert.Equal(t, "value", f.Read(id))
+
+	t.Log("stale lock file is removed")
+	assert.Nil(t, ioutil.WriteFile(lockFile, []byte{}, 0600))
+	stale := time.Now().Add(-2 * fileLockStale)
+	assert.Nil(t, os.Chtimes(lockFile, stale, stale))
+	assert.Nil(t, f.Delete(id))
+	assert.False(t, f.IsExists(id))
+	assert.False(t, ess.IsFileExists(lockFile))
+
+	t.Log("index update takes the lock file too")
+	indexLock := filepath.Join(sessionDir, "index", fileStoreLockPrefix+filepath.Base(f.indexFile("jeeva")))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(indexLock), 0755))
+	assert.Nil(t, ioutil.WriteFile(indexLock, []byte{}, 0600))
+	assert.Nil(t, os.Chtimes(indexLock, stale, stale))
+	assert.Nil(t, f.AddIndex("jeeva", id))
+	ids, err := f.IndexedIDs("jeeva")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{id}, ids)
+	assert.False(t, ess.IsFileExists(indexLock))
+	assert.Equal(t, 0, len(f.locks.locks))
+}
+
+func testFileStoreConfig(storeCfg string) string {
+	return `
+	security {
+	  session {
+	    ttl = "30m"
+	    store {
+	      type = "file"
+	      filepath = "testdata/session"
+	      ` + storeCfg + `
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `
+}
@@ -3,7 +3,7 @@
 // license that can be found in the LICENSE file.
 
 // Package session provides HTTP state management library for aah framework.
-// Default store is `Cookie` and framework provides `FileStore` and extensible
+// Default store is `Cookie` and framework provides `FileStore`, `RedisStore`, `SQLStore` and extensible
 // `session.Storer` interface. Using store interface you can write any key-value
 // Database, NoSQL Database, and RDBMS for storing encoded session data.
 //
@@ -16,7 +16,41 @@
 // is transmitted over the wire in the Cookie. Please refer `session.FileStore` for
 // sample, its very easy.
 //
-// If you would like to store custom types in session then Register your custom
+// Cookie store can be configured with overflow store (hybrid mode), session
+// that exceeds max cookie size is stored into overflow store and only Session
+// ID is transmitted in the Cookie.
+//
+//	security {
+//	  session {
+//	    store {
+//	      type = "cookie"
+//	      overflow = "file"
+//	      filepath = "sessions"
+//	    }
+//
+//	    # Hard cap of encoded session data size. Default value is `4kb` and
+//	    # `64kb` in hybrid mode.
+//	    max_size = "64kb"
+//	  }
+//	}
+//
+// Session value can be transmitted via HTTP header instead of Cookie for
+// clients without cookie jar, it is configured per domain in `routes.conf`.
+//
+//	domains {
+//	  api {
+//	    session {
+//	      transport = "header"
+//
+//	      # Default value is `Authorization`, value is sent as `Bearer <value>`.
+//	      header = "X-Session-Token"
+//	    }
+//	  }
+//	}
+//
+// Session is encoded using `gob` by default, it can be changed to `json` or
+// custom `session.Codec` via config `security.session.codec`. If you would
+// like to store custom types in session with `gob` then Register your custom
 // types using `gob.Register(...)`.
 //
 // Secure cookie code is inspired from Gorilla secure cookie library.
@@ -28,13 +62,16 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"aahframe.work/ahttp"
 	"aahframe.work/config"
 	"aahframe.work/essentials"
 	"aahframe.work/log"
+	"aahframe.work/security/authc"
 	"aahframe.work/security/cookie"
 )
 
@@ -42,6 +79,22 @@ var (
 	// ErrSessionStoreIsNil returned when suppiled store is nil.
 	ErrSessionStoreIsNil = errors.New("security/session: store value is nil")
 
+	// ErrSessionIndexerNotSupported returned when session store does not
+	// implement `SessionIndexer`.
+	ErrSessionIndexerNotSupported = errors.New("session: store does not support session indexer")
+
+	// ErrSessionNotFound returned when session is not found for the principal.
+	ErrSessionNotFound = errors.New("session: session not found")
+
+	// ErrSessionTooLarge returned when encoded session data exceeds the
+	// `max_size` or max cookie size without overflow store.
+	ErrSessionTooLarge = errors.New("session: session data exceeds the max size")
+
+	// overflowCookiePrefix marks the cookie value that holds Session ID of
+	// session spilled into overflow store, it is not part of base64 alphabet.
+	overflowCookiePrefix = "~"
+	bearerPrefix         = "Bearer "
+
 	registerStores = make(map[string]Storer)
 	sessionPool    = sync.Pool{New: func() interface{} { return &Session{Values: make(map[string]interface{})} }}
 )
@@ -56,6 +109,15 @@ type Storer interface {
 	Cleanup(m *Manager)
 }
 
+// SessionIndexer is an optional interface of session store, it maps the
+// subject's primary principal to session IDs. It enables listing and
+// revocation of sessions by principal, see `Manager.Sessions`.
+type SessionIndexer interface {
+	AddIndex(principal, id string) error
+	RemoveIndex(principal, id string) error
+	IndexedIDs(principal string) ([]string, error)
+}
+
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // Package methods
 //___________________________________
@@ -96,9 +158,44 @@ func NewManager(appCfg *config.Config) (*Manager, error) {
 		if err = m.store.Init(m.cfg); err != nil {
 			return nil, err
 		}
+		m.indexer, _ = store.(SessionIndexer)
+	}
+
+	// Hybrid mode, cookie store spills the session that exceeds max cookie
+	// size into overflow store
+	if overflowName := m.cfg.StringDefault(keyPrefix+".store.overflow", ""); len(overflowName) > 0 {
+		if !m.IsCookieStore() || overflowName == "cookie" {
+			return nil, errors.New("session: store overflow is supported only from cookie store to server-side store")
+		}
+		store, found := registerStores[overflowName]
+		if !found {
+			return nil, fmt.Errorf("session: store name '%v' not exists", overflowName)
+		}
+		m.store = store
+		if err = m.store.Init(m.cfg); err != nil {
+			return nil, err
+		}
+		m.hybrid = true
+	}
+
+	// Codec, previous codec is used for reading the session encoded before
+	// codec change
+	codecName := m.cfg.StringDefault(keyPrefix+".codec", "gob")
+	if m.codec, err = codecByName(codecName); err != nil {
+		return nil, err
+	}
+	prevCodecName := "gob"
+	if codecName == prevCodecName {
+		prevCodecName = ""
+	}
+	if prevCodecName = m.cfg.StringDefault(keyPrefix+".previous_codec", prevCodecName); len(prevCodecName) > 0 && prevCodecName != codecName {
+		if m.prevCodec, err = codecByName(prevCodecName); err != nil {
+			return nil, err
+		}
 	}
 
 	m.idLength = m.cfg.IntDefault(keyPrefix+".id_length", 32)
+	m.maxConcurrentSessions = m.cfg.IntDefault(keyPrefix+".max_concurrent_sessions", 0)
 
 	// Cookie Options
 	opts := &cookie.Options{
@@ -126,13 +223,32 @@ func NewManager(appCfg *config.Config) (*Manager, error) {
 		return nil, err
 	}
 
+	// Hard cap of encoded session data size
+	maxSizeStr := "4kb"
+	if m.hybrid {
+		maxSizeStr = "64kb"
+	}
+	maxSize, err := ess.StrToBytes(m.cfg.StringDefault(keyPrefix+".max_size", maxSizeStr))
+	if err != nil {
+		return nil, err
+	}
+	m.maxSize = int(maxSize)
+
+	// Idle (sliding) and absolute timeout, `0s` means not enforced
+	if m.idleTimeout, err = parseDuration(m.cfg, keyPrefix+".idle_timeout", "0s"); err != nil {
+		return nil, err
+	}
+	if m.absoluteTimeout, err = parseDuration(m.cfg, keyPrefix+".absolute_timeout", "0s"); err != nil {
+		return nil, err
+	}
+
 	// Cleanup
 	if m.cleanupInterval, err = toSeconds(m.cfg.StringDefault(keyPrefix+".cleanup_interval", "30m")); err != nil {
 		return nil, err
 	}
 
 	// Schedule cleanup
-	if !m.IsCookieStore() {
+	if !m.IsCookieStore() || m.hybrid {
 		go func(sm *Manager) {
 			ticker := time.NewTicker(time.Duration(sm.cleanupInterval) * time.Second)
 			for {
@@ -153,12 +269,22 @@ func NewManager(appCfg *config.Config) (*Manager, error) {
 // Manager is a session manager to manage sessions.
 type Manager struct {
 	idLength        int
+	maxSize         int
+	hybrid          bool
 	cleanupInterval int64
+	idleTimeout     time.Duration
+	absoluteTimeout time.Duration
 	mode            string
 	storeName       string
 	store           Storer
+	indexer         SessionIndexer
+	codec           Codec
+	prevCodec       Codec
 	cfg             *config.Config
 	cookieMgr       *cookie.Manager
+	publisher       EventPublisher
+
+	maxConcurrentSessions int
 }
 
 // NewSession method creates a new session for the request.
@@ -168,6 +294,7 @@ func (m *Manager) NewSession() *Session {
 	s.IsNew = true
 	t := time.Now()
 	s.CreatedTime = &t
+	s.LastAccessTime = &t
 	return s
 }
 
@@ -178,85 +305,223 @@ func (m *Manager) GetSession(r *http.Request) *Session {
 	if err == http.ErrNoCookie {
 		return nil
 	}
+	return m.readWireValue(scookie.Value)
+}
 
-	encodedStr := scookie.Value
-	if !m.IsCookieStore() {
-		if id, er := m.DecodeToString(encodedStr); er == nil {
-			encodedStr = m.store.Read(id)
-		} else {
-			log.Error(err)
+// GetSessionFromHeader method returns the session from given request header
+// otherwise it returns nil. It is used by clients that do not have cookie
+// jar, for e.g. mobile and SPA clients. Value of header `Authorization` is
+// read as `Bearer <value>`.
+func (m *Manager) GetSessionFromHeader(r *http.Request, header string) *Session {
+	value := r.Header.Get(header)
+	if strings.EqualFold(header, ahttp.HeaderAuthorization) {
+		if len(value) <= len(bearerPrefix) || !strings.EqualFold(value[:len(bearerPrefix)], bearerPrefix) {
 			return nil
 		}
+		value = value[len(bearerPrefix):]
 	}
+	if len(value) == 0 {
+		return nil
+	}
+	return m.readWireValue(value)
+}
 
-	if ess.IsStrEmpty(encodedStr) {
+// SaveSession method saves the given session into store.
+// Add writes the cookie into response.
+func (m *Manager) SaveSession(w http.ResponseWriter, s *Session) error {
+	if s.maxAge == -1 {
+		return m.DeleteSession(w, s)
+	}
+
+	value, err := m.writeWireValue(s)
+	if err != nil {
+		return err
+	}
+	m.cookieMgr.Write(w, value)
+	m.publishCreated(s)
+	return nil
+}
+
+// SaveSessionToHeader method saves the given session into store and writes
+// the session value into given response header instead of cookie. Value of
+// header `Authorization` is written as `Bearer <value>`. Header is not written
+// for deleted session.
+func (m *Manager) SaveSessionToHeader(w http.ResponseWriter, s *Session, header string) error {
+	if s.maxAge == -1 {
+		m.deleteSession(s)
 		return nil
 	}
 
-	session, err := m.DecodeToSession(encodedStr)
+	value, err := m.writeWireValue(s)
 	if err != nil {
-		log.Error(err)
+		return err
+	}
+	if strings.EqualFold(header, ahttp.HeaderAuthorization) {
+		value = bearerPrefix + value
+	}
+	w.Header().Set(header, value)
+	m.publishCreated(s)
+	return nil
+}
 
-		// clean expried session
-		if err == cookie.ErrCookieTimestampIsExpired && !m.IsCookieStore() {
-			if id, err := m.DecodeToString(scookie.Value); err == nil {
-				log.Debugf("Cleaning expried session: %s", id)
-				_ = m.store.Delete(id)
+// RegenerateID method assigns a new ID to the given session while keeping its
+// data and deletes the old session entry from the store. It prevents session
+// fixation, typically called after successful login.
+//
+// Session cookie is written into the response if `w` is not nil, otherwise
+// it is written by `SaveSession` later on. For cookie store, cookie value
+// carries the data itself so old cookie value cannot be revoked.
+func (m *Manager) RegenerateID(w http.ResponseWriter, s *Session) error {
+	oldID, overflowed := s.ID, s.overflowed
+	s.ID = ess.SecureRandomString(m.idLength)
+	if w != nil {
+		if err := m.SaveSession(w, s); err != nil {
+			s.ID = oldID
+			return err
+		}
+	} else if !m.IsCookieStore() {
+		encoded, err := m.encodeSession(s)
+		if err == nil {
+			err = m.store.Save(s.ID, encoded)
+		}
+		if err != nil {
+			s.ID = oldID
+			return err
+		}
+	}
+
+	if m.indexer != nil && len(s.Principal) > 0 {
+		if err := m.indexer.AddIndex(s.Principal, s.ID); err != nil {
+			return err
+		}
+		m.removeIndex(s.Principal, oldID)
+	}
+
+	if (!m.IsCookieStore() && !s.IsNew) || overflowed {
+		if err := m.store.Delete(oldID); err != nil {
+			return err
+		}
+	}
+	m.PublishEvent(&Event{Name: EventOnSessionRegenerated, OldID: oldID, Session: s})
+	return nil
+}
+
+// IndexSession method maps the given session to the primary principal of
+// authentication info and records the request client IP and user agent on
+// session. If `max_concurrent_sessions` is configured then oldest sessions of
+// the principal are revoked to stay within the limit.
+func (m *Manager) IndexSession(r *ahttp.Request, s *Session, authcInfo *authc.AuthenticationInfo) error {
+	if m.indexer == nil {
+		return ErrSessionIndexerNotSupported
+	}
+	p := authcInfo.PrimaryPrincipal()
+	if p == nil {
+		return errors.New("session: primary principal is not found")
+	}
+
+	s.Principal = p.Value
+	if r != nil {
+		s.ClientIP = r.ClientIP()
+		s.UserAgent = r.UserAgent()
+	}
+
+	// evict before indexing, given session might not be saved into store yet
+	if m.maxConcurrentSessions > 0 {
+		sessions, err := m.Sessions(s.Principal)
+		if err != nil {
+			return err
+		}
+		others := make([]*Session, 0, len(sessions))
+		for _, other := range sessions {
+			if other.ID != s.ID {
+				others = append(others, other)
 			}
 		}
-		return nil
+		for len(others) >= m.maxConcurrentSessions {
+			log.Infof("Max concurrent sessions reached for principal '%s', revoking oldest session", s.Principal)
+			if err = m.RevokeSession(s.Principal, others[0].ID); err != nil {
+				return err
+			}
+			others = others[1:]
+		}
 	}
 
-	session.IsNew = false
-	return session
+	return m.indexer.AddIndex(s.Principal, s.ID)
 }
 
-// SaveSession method saves the given session into store.
-// Add writes the cookie into response.
-func (m *Manager) SaveSession(w http.ResponseWriter, s *Session) error {
-	if s.maxAge == -1 {
-		return m.DeleteSession(w, s)
+// Sessions method returns the active sessions of given principal ordered by
+// created time, oldest first. Index entries of deleted or expired sessions are
+// removed along the way.
+func (m *Manager) Sessions(principal string) ([]*Session, error) {
+	if m.indexer == nil {
+		return nil, ErrSessionIndexerNotSupported
+	}
+	ids, err := m.indexer.IndexedIDs(principal)
+	if err != nil {
+		return nil, err
 	}
 
-	var (
-		encodedStr string
-		err        error
-	)
+	sessions := make([]*Session, 0, len(ids))
+	for _, id := range ids {
+		s := m.readSession(id)
+		if s == nil {
+			m.removeIndex(principal, id)
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return createdUnixNano(sessions[i]) < createdUnixNano(sessions[j])
+	})
+	return sessions, nil
+}
 
-	if m.IsCookieStore() {
-		encodedStr, err = m.Encode(s)
-	} else {
-		encodedStr, err = m.Encode(s.ID)
+// RevokeSession method deletes the given session of principal from the store,
+// it returns `ErrSessionNotFound` if session ID does not belong to principal.
+func (m *Manager) RevokeSession(principal, id string) error {
+	if m.indexer == nil {
+		return ErrSessionIndexerNotSupported
 	}
-	// any error while encode
+	ids, err := m.indexer.IndexedIDs(principal)
 	if err != nil {
 		return err
 	}
+	if !ess.IsSliceContainsString(ids, id) {
+		return ErrSessionNotFound
+	}
+	if err = m.store.Delete(id); err != nil {
+		return err
+	}
+	m.PublishEvent(&Event{Name: EventOnSessionDestroyed, ID: id, Principal: principal})
+	return m.indexer.RemoveIndex(principal, id)
+}
 
-	if !m.IsCookieStore() {
-		// Encode session object send it to store
-		encoded, err := m.Encode(s)
-		if err != nil {
+// RevokeSessions method deletes all the sessions of given principal from the
+// store, for e.g. force logout of the user.
+func (m *Manager) RevokeSessions(principal string) error {
+	if m.indexer == nil {
+		return ErrSessionIndexerNotSupported
+	}
+	ids, err := m.indexer.IndexedIDs(principal)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err = m.store.Delete(id); err != nil {
 			return err
 		}
-		if err = m.store.Save(s.ID, encoded); err != nil {
+		m.PublishEvent(&Event{Name: EventOnSessionDestroyed, ID: id, Principal: principal})
+		if err = m.indexer.RemoveIndex(principal, id); err != nil {
 			return err
 		}
 	}
-
-	m.cookieMgr.Write(w, encodedStr)
 	return nil
 }
 
 // DeleteSession method deletes the session from store and sets deletion
 // for browser cookie.
 func (m *Manager) DeleteSession(w http.ResponseWriter, s *Session) error {
-	if !m.IsCookieStore() {
-		if err := m.store.Delete(s.ID); err != nil {
-			// store delete had an error, log it and go forward to clean the cookie
-			log.Error(err)
-		}
-	}
+	m.deleteSession(s)
 
 	opts := *m.cookieMgr.Options
 	opts.MaxAge = -1
@@ -285,10 +550,10 @@ func (m *Manager) DecodeToSession(encodedStr string) (*Session, error) {
 // Encode method encodes given value with name.
 //
 // It performs:
-//   1) Encodes the value using `Gob`
+//   1) Encodes the value using configured `Codec`
 //   2) Encodes value into Base64 (encrypt, sign, cookie size check)
 func (m *Manager) Encode(value interface{}) (string, error) {
-	b, err := toBytes(value)
+	b, err := m.codec.Encode(value)
 	if err != nil {
 		return "", err
 	}
@@ -299,13 +564,42 @@ func (m *Manager) Encode(value interface{}) (string, error) {
 //
 // It performs:
 //   1) Decrypts the value (size check, decode base64, sign verify, timestamp verify, decrypt)
-//   2) Decode into result object using `Gob`
+//   2) Decode into result object using configured `Codec`, falls back to
+//      previous codec on error
 func (m *Manager) Decode(value string, dst interface{}) error {
-	b, err := m.cookieMgr.Decode(value)
+	maxSize := m.cookieMgr.MaxCookieSize()
+	if m.maxSize > maxSize {
+		maxSize = m.maxSize
+	}
+	b, err := m.cookieMgr.DecodeWithMaxSize(value, maxSize)
 	if err != nil {
 		return err
 	}
-	return decodeGob(dst, b)
+	if err = m.codec.Decode(dst, b); err != nil && m.prevCodec != nil {
+		if m.prevCodec.Decode(dst, b) == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// IsExpired method returns true if the given session exceeds the configured
+// `idle_timeout` since last access or `absolute_timeout` since creation.
+func (m *Manager) IsExpired(s *Session) bool {
+	now := time.Now()
+	if m.absoluteTimeout > 0 && s.CreatedTime != nil && now.Sub(*s.CreatedTime) > m.absoluteTimeout {
+		return true
+	}
+	if m.idleTimeout > 0 {
+		lastAccess := s.LastAccessTime
+		if lastAccess == nil {
+			lastAccess = s.CreatedTime
+		}
+		if lastAccess != nil && now.Sub(*lastAccess) > m.idleTimeout {
+			return true
+		}
+	}
+	return false
 }
 
 // IsStateful methdo returns true if session mode is stateful otherwise false.
@@ -318,6 +612,18 @@ func (m *Manager) IsCookieStore() bool {
 	return m.storeName == "cookie"
 }
 
+// IsIndexable method returns true if session store supports `SessionIndexer`
+// otherwise false.
+func (m *Manager) IsIndexable() bool {
+	return m.indexer != nil
+}
+
+// IsHybrid method returns true if cookie store is configured with overflow
+// store otherwise false.
+func (m *Manager) IsHybrid() bool {
+	return m.hybrid
+}
+
 // IsPath method returns true if session cookie config 'path' is prefix of request path.
 func (m *Manager) IsPath(p string) bool {
 	return strings.HasPrefix(p, m.cookieMgr.Options.Path)
@@ -330,3 +636,173 @@ func ReleaseSession(s *Session) {
 		sessionPool.Put(s)
 	}
 }
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+// readSession method reads the session from store, it returns nil if session
+// does not exists or expired.
+func (m *Manager) readSession(id string) *Session {
+	if !m.store.IsExists(id) {
+		return nil
+	}
+	encodedStr := m.store.Read(id)
+	if ess.IsStrEmpty(encodedStr) {
+		return nil
+	}
+	s, err := m.DecodeToSession(encodedStr)
+	if err != nil || m.IsExpired(s) {
+		return nil
+	}
+	return s
+}
+
+// deleteSession method deletes the session from store.
+func (m *Manager) deleteSession(s *Session) {
+	if !m.IsCookieStore() {
+		if err := m.store.Delete(s.ID); err != nil {
+			// store delete had an error, log it and go forward
+			log.Error(err)
+		}
+		m.removeIndex(s.Principal, s.ID)
+	} else if s.overflowed {
+		if err := m.store.Delete(s.ID); err != nil {
+			log.Error(err)
+		}
+	}
+
+	if !s.IsNew || s.created {
+		m.publishEvent(EventOnSessionDestroyed, s)
+	}
+}
+
+// readWireValue method returns the session for given cookie or header value
+// otherwise nil.
+func (m *Manager) readWireValue(value string) *Session {
+	encodedStr, idStr := value, value
+	overflowed := m.hybrid && strings.HasPrefix(idStr, overflowCookiePrefix)
+	if overflowed {
+		idStr = idStr[len(overflowCookiePrefix):]
+	}
+	if !m.IsCookieStore() || overflowed {
+		if id, er := m.DecodeToString(idStr); er == nil {
+			encodedStr = m.store.Read(id)
+		} else {
+			log.Error(er)
+			return nil
+		}
+	}
+
+	if ess.IsStrEmpty(encodedStr) {
+		return nil
+	}
+
+	session, err := m.DecodeToSession(encodedStr)
+	if err != nil {
+		log.Error(err)
+
+		// clean expried session
+		if err == cookie.ErrCookieTimestampIsExpired && (!m.IsCookieStore() || overflowed) {
+			if id, err := m.DecodeToString(idStr); err == nil {
+				log.Debugf("Cleaning expried session: %s", id)
+				_ = m.store.Delete(id)
+				m.PublishEvent(&Event{Name: EventOnSessionExpired, ID: id})
+			}
+		}
+		return nil
+	}
+
+	session.overflowed = overflowed
+	if m.IsExpired(session) {
+		log.Debugf("Session is expired by idle or absolute timeout: %s", session.ID)
+		if !m.IsCookieStore() || overflowed {
+			_ = m.store.Delete(session.ID)
+			m.removeIndex(session.Principal, session.ID)
+		}
+		m.publishEvent(EventOnSessionExpired, session)
+		return nil
+	}
+
+	session.IsNew = false
+	t := time.Now()
+	session.LastAccessTime = &t
+	return session
+}
+
+// writeWireValue method saves the given session into store if need be and
+// returns the value for cookie or header.
+func (m *Manager) writeWireValue(s *Session) (string, error) {
+	encoded, err := m.encodeSession(s)
+	if err != nil {
+		return "", err
+	}
+
+	value := encoded
+	switch {
+	case !m.IsCookieStore():
+		if value, err = m.Encode(s.ID); err != nil {
+			return "", err
+		}
+		if err = m.store.Save(s.ID, encoded); err != nil {
+			return "", err
+		}
+	case len(encoded) > m.cookieMgr.MaxCookieSize():
+		if !m.hybrid {
+			return "", ErrSessionTooLarge
+		}
+		// session exceeds max cookie size, spill it into overflow store
+		if value, err = m.Encode(s.ID); err != nil {
+			return "", err
+		}
+		if err = m.store.Save(s.ID, encoded); err != nil {
+			return "", err
+		}
+		value = overflowCookiePrefix + value
+		s.overflowed = true
+	case s.overflowed:
+		// session fits into cookie again
+		if err = m.store.Delete(s.ID); err != nil {
+			log.Error(err)
+		}
+		s.overflowed = false
+	}
+
+	return value, nil
+}
+
+func (m *Manager) publishCreated(s *Session) {
+	if s.IsNew && !s.created {
+		s.created = true
+		m.publishEvent(EventOnSessionCreated, s)
+	}
+}
+
+// encodeSession method encodes the session data with hard cap of `max_size`.
+func (m *Manager) encodeSession(s *Session) (string, error) {
+	b, err := m.codec.Encode(s)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := m.cookieMgr.EncodeWithMaxSize(b, m.maxSize)
+	if err == cookie.ErrCookieValueIsTooLarge {
+		return "", ErrSessionTooLarge
+	}
+	return encoded, err
+}
+
+func (m *Manager) removeIndex(principal, id string) {
+	if m.indexer == nil || len(principal) == 0 {
+		return
+	}
+	if err := m.indexer.RemoveIndex(principal, id); err != nil {
+		log.Error(err)
+	}
+}
+
+func createdUnixNano(s *Session) int64 {
+	if s.CreatedTime == nil {
+		return 0
+	}
+	return s.CreatedTime.UnixNano()
+}
@@ -7,12 +7,19 @@ package session
 import (
 	"encoding/gob"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"aahframe.work/ahttp"
 	"aahframe.work/config"
 	"aahframe.work/essentials"
+	"aahframe.work/security/authc"
 	"aahframe.work/security/cookie"
 	"github.com/stretchr/testify/assert"
 )
@@ -166,6 +173,279 @@ func TestSessionManagerMisc(t *testing.T) {
 	assert.Equal(t, float64(0.0), es.GetFloat64("not-exists"))
 }
 
+func TestSessionManagerRegenerateID(t *testing.T) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	m := createTestManager(t, `
+	security {
+	  session {
+	    store {
+	      type = "file"
+	      filepath = "testdata/session"
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	  }
+	}
+  `)
+
+	s := m.NewSession()
+	s.Set("my-key-1", "my key value 1")
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+	s.IsNew = false
+	oldID := s.ID
+
+	t.Log("without response writer")
+	assert.Nil(t, m.RegenerateID(nil, s))
+	assert.NotEqual(t, oldID, s.ID)
+	assert.Equal(t, len(oldID), len(s.ID))
+	assert.False(t, m.store.IsExists(oldID))
+	assert.True(t, m.store.IsExists(s.ID))
+
+	t.Log("with response writer, data moved to new ID")
+	oldID = s.ID
+	w := httptest.NewRecorder()
+	assert.Nil(t, m.RegenerateID(w, s))
+	assert.False(t, m.store.IsExists(oldID))
+
+	header := http.Header{}
+	header.Add("Cookie", w.Result().Header.Get("Set-Cookie"))
+	rs := m.GetSession(&http.Request{Header: header})
+	assert.NotNil(t, rs)
+	assert.Equal(t, s.ID, rs.ID)
+	assert.Equal(t, "my key value 1", rs.GetString("my-key-1"))
+
+	t.Log("cookie store")
+	cm := createTestManager(t, `security { session { sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; } }`)
+	cs := cm.NewSession()
+	oldID = cs.ID
+	w = httptest.NewRecorder()
+	assert.Nil(t, cm.RegenerateID(w, cs))
+	assert.NotEqual(t, oldID, cs.ID)
+	assert.NotEqual(t, "", w.Result().Header.Get("Set-Cookie"))
+}
+
+func TestSessionManagerHeaderTransport(t *testing.T) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	for _, cfgStr := range []string{
+		`security { session { sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"; } }`,
+		`security { session { store { type = "file"; filepath = "testdata/session"; } sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; } }`,
+	} {
+		m := createTestManager(t, cfgStr)
+		s := m.NewSession()
+		s.Set("my-key-1", "my key value 1")
+
+		t.Log("authorization header")
+		w := httptest.NewRecorder()
+		assert.Nil(t, m.SaveSessionToHeader(w, s, ahttp.HeaderAuthorization))
+		assert.Equal(t, "", w.Header().Get(ahttp.HeaderSetCookie))
+		value := w.Header().Get(ahttp.HeaderAuthorization)
+		assert.True(t, strings.HasPrefix(value, "Bearer "))
+
+		r := &http.Request{Header: http.Header{}}
+		r.Header.Set(ahttp.HeaderAuthorization, "bearer "+value[7:])
+		rs := m.GetSessionFromHeader(r, ahttp.HeaderAuthorization)
+		assert.NotNil(t, rs)
+		assert.Equal(t, s.ID, rs.ID)
+		assert.False(t, rs.IsNew)
+		assert.Equal(t, "my key value 1", rs.GetString("my-key-1"))
+		assert.Nil(t, m.GetSession(r))
+
+		t.Log("authorization header without bearer")
+		r.Header.Set(ahttp.HeaderAuthorization, "Basic "+value[7:])
+		assert.Nil(t, m.GetSessionFromHeader(r, ahttp.HeaderAuthorization))
+		r.Header.Set(ahttp.HeaderAuthorization, "Bearer ")
+		assert.Nil(t, m.GetSessionFromHeader(r, ahttp.HeaderAuthorization))
+
+		t.Log("custom header")
+		w = httptest.NewRecorder()
+		assert.Nil(t, m.SaveSessionToHeader(w, rs, "X-Session-Token"))
+		r.Header.Set("X-Session-Token", w.Header().Get("X-Session-Token"))
+		assert.Equal(t, s.ID, m.GetSessionFromHeader(r, "X-Session-Token").ID)
+		assert.Nil(t, m.GetSessionFromHeader(r, "X-Other-Token"))
+
+		t.Log("deleted session")
+		rs.Clear()
+		w = httptest.NewRecorder()
+		assert.Nil(t, m.SaveSessionToHeader(w, rs, "X-Session-Token"))
+		assert.Equal(t, "", w.Header().Get("X-Session-Token"))
+		if !m.IsCookieStore() {
+			assert.False(t, m.store.IsExists(s.ID))
+			assert.Nil(t, m.GetSessionFromHeader(r, "X-Session-Token"))
+		}
+	}
+}
+
+func TestSessionManagerTimeouts(t *testing.T) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	getSession := func(m *Manager, s *Session) *Session {
+		w := httptest.NewRecorder()
+		assert.Nil(t, m.SaveSession(w, s))
+		header := http.Header{}
+		header.Add("Cookie", w.Result().Header.Get("Set-Cookie"))
+		return m.GetSession(&http.Request{Header: header})
+	}
+
+	for _, storeCfg := range []string{"", `store { type = "file"; filepath = "testdata/session"; }`} {
+		m := createTestManager(t, fmt.Sprintf(`
+		security {
+		  session {
+		    %s
+		    idle_timeout = "15m"
+		    absolute_timeout = "8h"
+		    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+		  }
+		}`, storeCfg))
+		assert.Equal(t, 15*time.Minute, m.idleTimeout)
+		assert.Equal(t, 8*time.Hour, m.absoluteTimeout)
+
+		t.Log("active session, last access time is refreshed")
+		s := m.NewSession()
+		s.Set("my-key-1", "my key value 1")
+		past := time.Now().Add(-10 * time.Minute)
+		s.LastAccessTime = &past
+		rs := getSession(m, s)
+		assert.NotNil(t, rs)
+		assert.False(t, rs.IsNew)
+		assert.True(t, time.Since(*rs.LastAccessTime) < time.Minute)
+		assert.Equal(t, "my key value 1", rs.GetString("my-key-1"))
+
+		t.Log("idle timeout")
+		past = time.Now().Add(-16 * time.Minute)
+		s.LastAccessTime = &past
+		assert.True(t, m.IsExpired(s))
+		assert.Nil(t, getSession(m, s))
+		if !m.IsCookieStore() {
+			assert.False(t, m.store.IsExists(s.ID))
+		}
+
+		t.Log("absolute timeout, regardless of activity")
+		s = m.NewSession()
+		created := time.Now().Add(-9 * time.Hour)
+		s.CreatedTime = &created
+		assert.True(t, m.IsExpired(s))
+		assert.Nil(t, getSession(m, s))
+
+		t.Log("no last access time, falls back to created time")
+		s.LastAccessTime = nil
+		created = time.Now().Add(-20 * time.Minute)
+		assert.True(t, m.IsExpired(s))
+	}
+
+	t.Log("timeouts are not enforced by default")
+	m := createTestManager(t, `security { session { sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; } }`)
+	s := m.NewSession()
+	created := time.Now().Add(-1000 * time.Hour)
+	s.CreatedTime, s.LastAccessTime = &created, &created
+	assert.False(t, m.IsExpired(s))
+
+	cfg, _ := config.ParseString(`security { session { idle_timeout = "-15m"; } }`)
+	_, err := NewManager(cfg)
+	assert.Equal(t, errors.New("session: 'security.session.idle_timeout' value is not a valid duration"), err)
+	cfg, _ = config.ParseString(`security { session { absolute_timeout = "8d"; } }`)
+	_, err = NewManager(cfg)
+	assert.Equal(t, errors.New("session: 'security.session.absolute_timeout' value is not a valid duration"), err)
+}
+
+func TestSessionManagerIndexerNotSupported(t *testing.T) {
+	m := createTestManager(t, `security { session { sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"; } }`)
+	assert.False(t, m.IsIndexable())
+	assert.Equal(t, ErrSessionIndexerNotSupported, m.IndexSession(nil, m.NewSession(), authc.NewAuthenticationInfo()))
+	_, err := m.Sessions("jeeva")
+	assert.Equal(t, ErrSessionIndexerNotSupported, err)
+	assert.Equal(t, ErrSessionIndexerNotSupported, m.RevokeSession("jeeva", "id1"))
+	assert.Equal(t, ErrSessionIndexerNotSupported, m.RevokeSessions("jeeva"))
+}
+
+func testSessionIndexer(t *testing.T, cfgStr string) {
+	defer ess.DeleteFiles(filepath.Join(getTestdataPath(), "session"))
+
+	m := createTestManager(t, cfgStr)
+	assert.True(t, m.IsIndexable())
+	m.maxConcurrentSessions = 2
+
+	authcInfo := authc.NewAuthenticationInfo()
+	authcInfo.Principals = append(authcInfo.Principals, &authc.Principal{Realm: "database", Value: "jeeva", IsPrimary: true})
+	newSession := func(userAgent string, age time.Duration) *Session {
+		r := httptest.NewRequest("POST", "http://localhost:8080/login", nil)
+		r.Header.Set(ahttp.HeaderUserAgent, userAgent)
+		s := m.NewSession()
+		created := time.Now().Add(-age)
+		s.CreatedTime = &created
+		assert.Nil(t, m.IndexSession(ahttp.AcquireRequest(r), s, authcInfo))
+		assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+		return s
+	}
+	sessionIDs := func() []string {
+		sessions, err := m.Sessions("jeeva")
+		assert.Nil(t, err)
+		var ids []string
+		for _, s := range sessions {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	t.Log("list sessions of principal")
+	s1 := newSession("browser-1", 3*time.Minute)
+	s2 := newSession("browser-2", 2*time.Minute)
+	sessions, err := m.Sessions("jeeva")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(sessions))
+	assert.Equal(t, s1.ID, sessions[0].ID)
+	assert.Equal(t, "jeeva", sessions[0].Principal)
+	assert.Equal(t, "192.0.2.1", sessions[0].ClientIP)
+	assert.Equal(t, "browser-1", sessions[0].UserAgent)
+	assert.Equal(t, "browser-2", sessions[1].UserAgent)
+
+	t.Log("max concurrent sessions evicts the oldest")
+	s3 := newSession("browser-3", time.Minute)
+	assert.False(t, m.store.IsExists(s1.ID))
+	assert.Equal(t, []string{s2.ID, s3.ID}, sessionIDs())
+
+	t.Log("revoke session")
+	assert.Equal(t, ErrSessionNotFound, m.RevokeSession("jeeva", "unknown-id"))
+	assert.Equal(t, ErrSessionNotFound, m.RevokeSession("other", s2.ID))
+	assert.Nil(t, m.RevokeSession("jeeva", s2.ID))
+	assert.False(t, m.store.IsExists(s2.ID))
+	assert.Equal(t, []string{s3.ID}, sessionIDs())
+
+	t.Log("stale index entry is removed")
+	assert.Nil(t, m.indexer.AddIndex("jeeva", "stale-id"))
+	assert.Equal(t, []string{s3.ID}, sessionIDs())
+	ids, err := m.indexer.IndexedIDs("jeeva")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{s3.ID}, ids)
+
+	t.Log("regenerated id is indexed")
+	oldID := s3.ID
+	s3.IsNew = false
+	assert.Nil(t, m.RegenerateID(nil, s3))
+	assert.NotEqual(t, oldID, s3.ID)
+	assert.Equal(t, []string{s3.ID}, sessionIDs())
+
+	t.Log("logout removes index")
+	s3.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s3))
+	ids, err = m.indexer.IndexedIDs("jeeva")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(ids))
+
+	t.Log("revoke all sessions")
+	s4 := newSession("browser-4", 2*time.Minute)
+	s5 := newSession("browser-5", time.Minute)
+	assert.Equal(t, []string{s4.ID, s5.ID}, sessionIDs())
+	assert.Nil(t, m.RevokeSessions("jeeva"))
+	assert.False(t, m.store.IsExists(s4.ID))
+	assert.False(t, m.store.IsExists(s5.ID))
+	assert.Equal(t, 0, len(sessionIDs()))
+
+	assert.NotNil(t, m.IndexSession(nil, m.NewSession(), authc.NewAuthenticationInfo()))
+}
+
 func assertSessionValue(t *testing.T, s *Session) {
 	t.Logf("Session: %v", s)
 	assert.NotNil(t, s)
@@ -0,0 +1,186 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/resp"
+	"aahframe.work/log"
+)
+
+// Storer and SessionIndexer interface comply
+var (
+	_ Storer         = (*RedisStore)(nil)
+	_ SessionIndexer = (*RedisStore)(nil)
+)
+
+// RedisStore is the aah framework session store implementation backed by
+// Redis, so that sessions can be shared across application instances. It
+// talks RESP protocol with connection pooling and it is registered in the
+// name of `redis`.
+//
+//	security {
+//	  session {
+//	    store {
+//	      type = "redis"
+//	      redis {
+//	        address = "localhost:6379"
+//	        password = ""
+//	        db = 0
+//	        dial_timeout = "5s"
+//	        read_timeout = "3s"
+//	        write_timeout = "3s"
+//	        # Default value is `<app name>:session:`.
+//	        key_prefix = "myapp:session:"
+//	        # Key expiry for `ttl` value `0m`, when session `idle_timeout`
+//	        # and `absolute_timeout` are not set. Default value is `24h`.
+//	        browser_session_ttl = "24h"
+//	        pool {
+//	          max_idle = 10
+//	          idle_timeout = "5m"
+//	        }
+//	      }
+//	    }
+//	  }
+//	}
+//
+// Session `ttl` is applied as Redis key expiry, so `Cleanup` does nothing.
+// For `ttl` value `0m` (browser session cookie) key expiry is session
+// `idle_timeout`, otherwise `absolute_timeout`, otherwise `browser_session_ttl`.
+// Expiry is extended on every save.
+//
+// It implements `SessionIndexer`, session ids of principal are kept in the set
+// `<key_prefix>principal:<principal>`.
+type RedisStore struct {
+	keyPrefix string
+	ttl       time.Duration
+	pool      *resp.Pool
+}
+
+// Init method initializes the Redis store using given application config.
+// It verifies the connectivity to Redis server.
+func (r *RedisStore) Init(cfg *config.Config) error {
+	keyPrefix := "security.session.store.redis."
+	opts := &resp.Options{
+		Address:  cfg.StringDefault(keyPrefix+"address", "localhost:6379"),
+		Password: cfg.StringDefault(keyPrefix+"password", ""),
+		DB:       cfg.IntDefault(keyPrefix+"db", 0),
+		MaxIdle:  cfg.IntDefault(keyPrefix+"pool.max_idle", 10),
+	}
+	var err error
+	if opts.DialTimeout, err = parseDuration(cfg, keyPrefix+"dial_timeout", "5s"); err != nil {
+		return err
+	}
+	if opts.ReadTimeout, err = parseDuration(cfg, keyPrefix+"read_timeout", "3s"); err != nil {
+		return err
+	}
+	if opts.WriteTimeout, err = parseDuration(cfg, keyPrefix+"write_timeout", "3s"); err != nil {
+		return err
+	}
+	if opts.IdleTimeout, err = parseDuration(cfg, keyPrefix+"pool.idle_timeout", "5m"); err != nil {
+		return err
+	}
+
+	if r.ttl, err = storeTTL(cfg, keyPrefix); err != nil {
+		return err
+	}
+	r.keyPrefix = cfg.StringDefault(keyPrefix+"key_prefix", cfg.StringDefault("name", "aah")+":session:")
+
+	if r.pool != nil {
+		_ = r.pool.Close()
+	}
+	r.pool = resp.NewPool(opts)
+	if _, err = r.pool.Do("PING"); err != nil {
+		return fmt.Errorf("session: redis store: %s", err)
+	}
+
+	log.Infof("Session redis store is initialized at address: %v", opts.Address)
+	return nil
+}
+
+// Read method reads the encoded cookie value from Redis.
+func (r *RedisStore) Read(id string) string {
+	v, err := r.pool.Do("GET", r.keyPrefix+id)
+	if err != nil {
+		log.Errorf("session: redis store - read error: %v", err)
+		return ""
+	}
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return ""
+}
+
+// Save method saves the given session id with encoded cookie value, session
+// `ttl` is set as key expiry.
+func (r *RedisStore) Save(id, value string) error {
+	_, err := r.pool.Do("SET", r.keyPrefix+id, value, "EX", int64(r.ttl/time.Second))
+	return err
+}
+
+// Delete method deletes the session for given id from Redis.
+func (r *RedisStore) Delete(id string) error {
+	_, err := r.pool.Do("DEL", r.keyPrefix+id)
+	return err
+}
+
+// IsExists method returns true if the session exists in Redis otherwise false.
+func (r *RedisStore) IsExists(id string) bool {
+	v, err := r.pool.Do("EXISTS", r.keyPrefix+id)
+	if err != nil {
+		log.Errorf("session: redis store - exists error: %v", err)
+		return false
+	}
+	n, _ := v.(int64)
+	return n > 0
+}
+
+// Cleanup method does nothing, Redis expires the session keys on its own. So
+// `EventOnSessionExpired` is not published for them.
+func (r *RedisStore) Cleanup(m *Manager) {}
+
+// AddIndex method adds the session id into principal's set, set expiry is
+// extended by session `ttl`.
+func (r *RedisStore) AddIndex(principal, id string) error {
+	key := r.indexKey(principal)
+	if _, err := r.pool.Do("SADD", key, id); err != nil {
+		return err
+	}
+	_, err := r.pool.Do("EXPIRE", key, int64(r.ttl/time.Second))
+	return err
+}
+
+// RemoveIndex method removes the session id from principal's set.
+func (r *RedisStore) RemoveIndex(principal, id string) error {
+	_, err := r.pool.Do("SREM", r.indexKey(principal), id)
+	return err
+}
+
+// IndexedIDs method returns the session ids from principal's set.
+func (r *RedisStore) IndexedIDs(principal string) ([]string, error) {
+	v, err := r.pool.Do("SMEMBERS", r.indexKey(principal))
+	if err != nil {
+		return nil, err
+	}
+	members, _ := v.([]interface{})
+	ids := make([]string, 0, len(members))
+	for _, m := range members {
+		if b, ok := m.([]byte); ok {
+			ids = append(ids, string(b))
+		}
+	}
+	return ids, nil
+}
+
+func (r *RedisStore) indexKey(principal string) string {
+	return r.keyPrefix + "principal:" + principal
+}
+
+func init() {
+	_ = AddStore("redis", &RedisStore{})
+}
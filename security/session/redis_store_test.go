@@ -0,0 +1,130 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/internal/resp/resptest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionRedisStoreSave(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+
+	testSessionStoreSave(t, redisStoreTestConfig(srv, "", `ttl = "30m"`))
+	keys := srv.Keys()
+	assert.Equal(t, 1, len(keys))
+	assert.True(t, strings.HasPrefix(keys[0], "myapp:session:"))
+	ttl := srv.TTL(keys[0])
+	assert.True(t, ttl > 29*time.Minute && ttl <= 30*time.Minute)
+}
+
+func TestSessionRedisStoreDeleteAndCleanup(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+	srv.SetPassword("s3cr3t")
+
+	m := createTestManager(t, redisStoreTestConfig(srv, `password = "s3cr3t"; key_prefix = "app1:"`, ""))
+	session := m.NewSession()
+	session.Set("my-key-1", "my key value 1")
+	sid := session.ID
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), session))
+	assert.Equal(t, []string{"app1:" + sid}, srv.Keys())
+	ttl := srv.TTL("app1:" + sid)
+	assert.True(t, ttl > 23*time.Hour && ttl <= 24*time.Hour, "ttl 0m expires by browser_session_ttl")
+	assert.True(t, m.store.IsExists(sid))
+	assert.NotEqual(t, "", m.store.Read(sid))
+
+	m.store.Cleanup(m)
+	assert.True(t, m.store.IsExists(sid))
+
+	session.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), session))
+	assert.False(t, m.store.IsExists(sid))
+	assert.Equal(t, "", m.store.Read(sid))
+	assert.Equal(t, 0, len(srv.Keys()))
+}
+
+func TestSessionRedisStoreIndexer(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+
+	testSessionIndexer(t, redisStoreTestConfig(srv, "", `ttl = "30m"`))
+	ttl := srv.TTL("myapp:session:principal:jeeva")
+	assert.Equal(t, time.Duration(-2), ttl, "empty set is removed")
+}
+
+func TestSessionRedisStoreBrowserSessionTTL(t *testing.T) {
+	srv := resptest.NewServer()
+	defer srv.Close()
+
+	testcases := []struct {
+		storeCfg, sessionCfg string
+		ttl                  time.Duration
+	}{
+		{"", `idle_timeout = "20m"; absolute_timeout = "8h"`, 20 * time.Minute},
+		{"", `absolute_timeout = "8h"`, 8 * time.Hour},
+		{`browser_session_ttl = "2h"`, "", 2 * time.Hour},
+		{`browser_session_ttl = "2h"`, `ttl = "30m"; idle_timeout = "20m"`, 30 * time.Minute},
+	}
+	for _, tc := range testcases {
+		cfg, err := config.ParseString(redisStoreTestConfig(srv, tc.storeCfg, tc.sessionCfg))
+		assert.Nil(t, err)
+		store := &RedisStore{}
+		assert.Nil(t, store.Init(cfg))
+		assert.Equal(t, tc.ttl, store.ttl)
+	}
+
+	cfg, _ := config.ParseString(redisStoreTestConfig(srv, `browser_session_ttl = "0s"`, ""))
+	assert.Equal(t, errors.New("session: 'security.session.store.redis.browser_session_ttl' value must be atleast 1s"), new(RedisStore).Init(cfg))
+}
+
+func TestSessionRedisStoreErrors(t *testing.T) {
+	srv := resptest.NewServer()
+	store := &RedisStore{}
+
+	cfg, _ := config.ParseString(redisStoreTestConfig(srv, `read_timeout = "3"`, ""))
+	assert.Equal(t, errors.New("session: 'security.session.store.redis.read_timeout' value is not a valid duration"), store.Init(cfg))
+
+	cfg, _ = config.ParseString(redisStoreTestConfig(srv, "", `ttl = "30s"`))
+	assert.Equal(t, errors.New("unsupported time unit '30s' on 'session.ttl'"), store.Init(cfg))
+
+	cfg, _ = config.ParseString(redisStoreTestConfig(srv, "", ""))
+	assert.Nil(t, store.Init(cfg))
+	srv.Close()
+	assert.Equal(t, "", store.Read("id1"))
+	assert.False(t, store.IsExists("id1"))
+	assert.NotNil(t, store.Save("id1", "value1"))
+	assert.NotNil(t, store.Init(cfg))
+}
+
+func redisStoreTestConfig(srv *resptest.Server, storeCfg, sessionCfg string) string {
+	return fmt.Sprintf(`
+	name = "myapp"
+	security {
+	  session {
+	    store {
+	      type = "redis"
+	      redis {
+	        address = "%s"
+	        %s
+	      }
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	    %s
+	  }
+	}
+  `, srv.Addr, storeCfg, sessionCfg)
+}
@@ -5,6 +5,7 @@
 package session
 
 import (
+	"encoding/json"
 	"fmt"
 	"time"
 )
@@ -21,23 +22,37 @@ type Session struct {
 	// creation. ID length is 32.
 	//
 	//Note: Do not use this value for any/derving user relation, not recommended.
-	ID string
+	ID string `json:"id"`
 
 	// Values is values that stored in session object.
-	Values map[string]interface{}
+	Values map[string]interface{} `json:"values"`
 
 	// IsNew indicates whether sesison is newly created or restore from the
 	// request which was already created.
-	IsNew bool
+	IsNew bool `json:"is_new"`
 
 	// IsAuthenticated is helpful to identify user session already authenicated or
 	// not. Don't forget to set it true after successful authentication.
-	IsAuthenticated bool
+	IsAuthenticated bool `json:"is_authenticated"`
 
 	// CreatedTime is when the session was created.
-	CreatedTime *time.Time
+	CreatedTime *time.Time `json:"created_time"`
 
-	maxAge int
+	// LastAccessTime is when the session was last accessed by the request, it
+	// is used for idle timeout.
+	LastAccessTime *time.Time `json:"last_access_time"`
+
+	// Principal is the primary principal value of authenticated subject, it is
+	// set when session is indexed by `Manager.IndexSession`.
+	Principal string `json:"principal"`
+
+	// ClientIP and UserAgent of the request that authenticated the session.
+	ClientIP  string `json:"client_ip"`
+	UserAgent string `json:"user_agent"`
+
+	maxAge     int
+	created    bool
+	overflowed bool
 }
 
 // Get method returns the value for given key otherwise nil.
@@ -105,7 +120,10 @@ func (s *Session) GetBool(key string) bool {
 // GetInt method returns the `int` value from session otherwise 0.
 func (s *Session) GetInt(key string) int {
 	if value := s.Get(key); value != nil {
-		return value.(int)
+		if v, ok := value.(int); ok {
+			return v
+		}
+		return int(toInt64(value))
 	}
 	return 0
 }
@@ -113,7 +131,7 @@ func (s *Session) GetInt(key string) int {
 // GetInt64 method returns the `int64` value from session otherwise 0.
 func (s *Session) GetInt64(key string) int64 {
 	if value := s.Get(key); value != nil {
-		return value.(int64)
+		return toInt64(value)
 	}
 	return 0
 }
@@ -129,7 +147,10 @@ func (s *Session) GetString(key string) string {
 // GetFloat32 method returns the `float32` value from session otherwise 0.
 func (s *Session) GetFloat32(key string) float32 {
 	if value := s.Get(key); value != nil {
-		return value.(float32)
+		if v, ok := value.(float32); ok {
+			return v
+		}
+		return float32(toFloat64(value))
 	}
 	return 0
 }
@@ -137,27 +158,72 @@ func (s *Session) GetFloat32(key string) float32 {
 // GetFloat64 method returns the `float64` value from session otherwise 0.
 func (s *Session) GetFloat64(key string) float64 {
 	if value := s.Get(key); value != nil {
-		return value.(float64)
+		return toFloat64(value)
 	}
 	return 0
 }
 
 // String method is stringer interface implementation.
 func (s Session) String() string {
-	return fmt.Sprintf("session(id:%s createdat:%s isnew:%v isauthenticated:%v values:%v)",
-		s.ID, s.CreatedTime, s.IsNew, s.IsAuthenticated, s.Values)
+	return fmt.Sprintf("session(id:%s createdat:%s lastaccessat:%s isnew:%v isauthenticated:%v values:%v)",
+		s.ID, s.CreatedTime, s.LastAccessTime, s.IsNew, s.IsAuthenticated, s.Values)
 }
 
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
 // Unexported methods
 //___________________________________
 
+// toInt64 method converts the session number value into int64, it handles
+// the `json.Number` and `float64` values of JSON codec.
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return int64(f)
+	case float64:
+		return int64(v)
+	}
+	return value.(int64)
+}
+
+// toFloat64 method converts the session number value into float64, it handles
+// the `json.Number` values of JSON codec.
+func toFloat64(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return value.(float64)
+}
+
 // Reset method resets the instance values for repurpose.
 func (s *Session) Reset() {
 	s.ID = ""
 	s.Values = make(map[string]interface{})
 	s.IsNew = false
 	s.CreatedTime = nil
+	s.LastAccessTime = nil
+	s.Principal = ""
+	s.ClientIP = ""
+	s.UserAgent = ""
 	s.IsAuthenticated = false
 	s.maxAge = 0
+	s.created = false
+	s.overflowed = false
 }
@@ -0,0 +1,450 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package session
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"aahframe.work/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionSQLStoreSave(t *testing.T) {
+	fakeSQLDBs.reset()
+	testSessionStoreSave(t, sqlStoreTestConfig("save", `ttl = "30m"`))
+	db := fakeSQLDBs.get("save")
+	assert.Equal(t, 1, len(db.tables["aah_sessions"]))
+	for _, r := range db.tables["aah_sessions"] {
+		d := time.Until(time.Unix(r.expiresAt, 0))
+		assert.True(t, d > 29*time.Minute && d <= 30*time.Minute)
+	}
+}
+
+func TestSessionSQLStoreBrowserSessionTTL(t *testing.T) {
+	fakeSQLDBs.reset()
+	cfg, err := config.ParseString(sqlStoreTestConfig("browser", `browser_session_ttl = "2h"`))
+	assert.Nil(t, err)
+	store := &SQLStore{}
+	assert.Nil(t, store.Init(cfg))
+	assert.Equal(t, 2*time.Hour, store.ttl)
+
+	assert.Nil(t, store.Save("id1", "data1"))
+	d := time.Until(time.Unix(fakeSQLDBs.get("browser").tables["aah_sessions"]["id1"].expiresAt, 0))
+	assert.True(t, d > 119*time.Minute && d <= 2*time.Hour)
+
+	cfg, _ = config.ParseString(sqlStoreTestConfig("browser", `browser_session_ttl = "0s"`))
+	assert.Equal(t, errors.New("session: 'security.session.store.sql.browser_session_ttl' value must be atleast 1s"), new(SQLStore).Init(cfg))
+}
+
+func TestSessionSQLStoreDeleteAndCleanup(t *testing.T) {
+	fakeSQLDBs.reset()
+	m := createTestManager(t, sqlStoreTestConfig("cleanup", `table = "my_sessions"
+	placeholder = "dollar"
+	cleanup_batch_size = 2`))
+	db := fakeSQLDBs.get("cleanup")
+	db.changedRowsOnly = true
+
+	var ids []string
+	for i := 0; i < 6; i++ {
+		s := m.NewSession()
+		s.Set("index", i)
+		assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+		ids = append(ids, s.ID)
+	}
+	assert.Equal(t, 6, len(db.tables["my_sessions"]))
+	d := time.Until(time.Unix(db.tables["my_sessions"][ids[0]].expiresAt, 0))
+	assert.True(t, d > 23*time.Hour && d <= 24*time.Hour, "ttl 0m expires by browser_session_ttl")
+
+	t.Log("unchanged row is saved again")
+	data := m.store.Read(ids[0])
+	assert.NotEqual(t, "", data)
+	assert.Nil(t, m.store.Save(ids[0], data))
+	assert.Nil(t, m.store.Save(ids[0], "new data"))
+	assert.Equal(t, "new data", m.store.Read(ids[0]))
+
+	t.Log("expired sessions are deleted in batches")
+	past := time.Now().Add(-time.Minute).Unix()
+	for _, id := range ids[:5] {
+		db.tables["my_sessions"][id].expiresAt = past
+	}
+	assert.False(t, m.store.IsExists(ids[0]))
+	assert.Equal(t, "", m.store.Read(ids[0]))
+	m.store.Cleanup(m)
+	assert.Equal(t, 1, len(db.tables["my_sessions"]))
+	assert.True(t, m.store.IsExists(ids[5]))
+	assert.Equal(t, 3, db.count("DELETE FROM my_sessions WHERE id IN"))
+	assert.Equal(t, 3, db.count("SELECT data FROM my_sessions WHERE id = $1 AND (expires_at = 0 OR expires_at > $2)"))
+	assert.Equal(t, 2, db.count("DELETE FROM my_sessions WHERE id IN ($1, $2)"))
+
+	s := m.NewSession()
+	s.ID = ids[5]
+	s.Clear()
+	assert.Nil(t, m.SaveSession(httptest.NewRecorder(), s))
+	assert.False(t, m.store.IsExists(ids[5]))
+	assert.Equal(t, 0, len(db.tables["my_sessions"]))
+}
+
+func TestSessionSQLStoreIndexer(t *testing.T) {
+	fakeSQLDBs.reset()
+	testSessionIndexer(t, sqlStoreTestConfig("indexer", ""))
+	db := fakeSQLDBs.get("indexer")
+	assert.Equal(t, 0, len(db.tables["aah_sessions_principals"]))
+}
+
+func TestSessionSQLStoreCleanupQuery(t *testing.T) {
+	fakeSQLDBs.reset()
+	m := createTestManager(t, sqlStoreTestConfig("cleanupquery",
+		`cleanup_query = "SELECT TOP {batch_size} id FROM {table} WHERE expires_at > 0 AND expires_at <= ?"`))
+	db := fakeSQLDBs.get("cleanupquery")
+	db.tables["aah_sessions"]["id1"] = &fakeSQLRow{data: "data1", expiresAt: time.Now().Add(-time.Minute).Unix()}
+	m.store.Cleanup(m)
+	assert.Equal(t, 0, len(db.tables["aah_sessions"]))
+	assert.Equal(t, 1, db.count("SELECT TOP 500 id FROM aah_sessions WHERE expires_at > 0 AND expires_at <= ?"))
+}
+
+func TestSessionSQLStoreErrors(t *testing.T) {
+	testcases := []struct {
+		label, storeCfg string
+		err             error
+	}{
+		{
+			label: "driver missing",
+			err:   errors.New("session: sql store driver name is not provided"),
+		},
+		{
+			label:    "unknown driver",
+			storeCfg: `driver = "nodriver"`,
+			err:      errors.New(`session: sql store: sql: unknown driver "nodriver" (forgotten import?)`),
+		},
+		{
+			label:    "invalid table name",
+			storeCfg: `driver = "aahfakesql"; table = "sessions; DROP TABLE users"`,
+			err:      errors.New("session: sql store table name 'sessions; DROP TABLE users' is not valid"),
+		},
+		{
+			label:    "invalid placeholder",
+			storeCfg: `driver = "aahfakesql"; placeholder = "colon"`,
+			err:      errors.New("session: sql store placeholder 'colon' is not supported"),
+		},
+		{
+			label:    "invalid batch size",
+			storeCfg: `driver = "aahfakesql"; cleanup_batch_size = 0`,
+			err:      errors.New("session: sql store 'cleanup_batch_size' must be greater than zero"),
+		},
+		{
+			label:    "ping failure",
+			storeCfg: `driver = "aahfakesql"; dsn = "down"`,
+			err:      errors.New("session: sql store: fakesql: database is down"),
+		},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.label, func(t *testing.T) {
+			cfg, err := config.ParseString(fmt.Sprintf("security { session { store { sql {\n%s\n} } } }", tc.storeCfg))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.err, (&SQLStore{}).Init(cfg))
+		})
+	}
+
+	t.Log("given DB is used, table is not created")
+	db, err := sql.Open("aahfakesql", "nocreate")
+	assert.Nil(t, err)
+	store := &SQLStore{DB: db}
+	cfg, err := config.ParseString(`security { session { store { sql { create_table = false; } } } }`)
+	assert.Nil(t, err)
+	assert.Nil(t, store.Init(cfg))
+	assert.Equal(t, errors.New("fakesql: no such table: aah_sessions"), store.Save("id1", "value1"))
+	assert.Equal(t, "", store.Read("id1"))
+	assert.False(t, store.IsExists("id1"))
+	assert.NotNil(t, store.Delete("id1"))
+	store.Cleanup(nil)
+}
+
+func sqlStoreTestConfig(dsn, storeCfg string) string {
+	return fmt.Sprintf(`
+	security {
+	  session {
+	    store {
+	      type = "sql"
+	      sql {
+	        driver = "aahfakesql"
+	        dsn = "%s"
+	        %s
+	      }
+	    }
+
+	    sign_key = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+	    enc_key = "KYqklJsgeclPpZutTeQKNOTWlpksRBwA"
+	    %s
+	  }
+	}
+  `, dsn, storeCfg, map[bool]string{true: storeCfg, false: ""}[strings.HasPrefix(storeCfg, "ttl")])
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Fake SQL driver
+//___________________________________
+
+// fakeSQLDriver understands only the queries issued by `SQLStore`, each DSN
+// is separate in-memory database.
+type fakeSQLDriver struct {
+	mu  sync.Mutex
+	dbs map[string]*fakeSQLDB
+}
+
+type fakeSQLDB struct {
+	mu              sync.Mutex
+	tables          map[string]map[string]*fakeSQLRow
+	queries         []string
+	changedRowsOnly bool
+}
+
+type fakeSQLRow struct {
+	data      string
+	expiresAt int64
+}
+
+var (
+	fakeSQLDBs       = &fakeSQLDriver{dbs: make(map[string]*fakeSQLDB)}
+	fakeSQLTableName = regexp.MustCompile(`(?:FROM|INTO|UPDATE|EXISTS) ([A-Za-z0-9_.]+)`)
+	fakeSQLDollar    = regexp.MustCompile(`\$\d+`)
+)
+
+func init() {
+	sql.Register("aahfakesql", fakeSQLDBs)
+}
+
+func (d *fakeSQLDriver) get(dsn string) *fakeSQLDB {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	db, found := d.dbs[dsn]
+	if !found {
+		db = &fakeSQLDB{tables: make(map[string]map[string]*fakeSQLRow)}
+		d.dbs[dsn] = db
+	}
+	return db
+}
+
+// reset method drops the in-memory databases, so that test does not see the
+// data of previous test run.
+func (d *fakeSQLDriver) reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.dbs = make(map[string]*fakeSQLDB)
+}
+
+func (d *fakeSQLDriver) Open(dsn string) (driver.Conn, error) {
+	if dsn == "down" {
+		return nil, errors.New("fakesql: database is down")
+	}
+	return &fakeSQLConn{db: d.get(dsn)}, nil
+}
+
+func (db *fakeSQLDB) count(prefix string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	cnt := 0
+	for _, q := range db.queries {
+		if strings.HasPrefix(q, prefix) {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+type fakeSQLConn struct {
+	db *fakeSQLDB
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("fakesql: transactions are not supported")
+}
+
+type fakeSQLStmt struct {
+	db    *fakeSQLDB
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.exec(args)
+	return driver.RowsAffected(n), err
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.exec(args)
+	if err != nil {
+		return nil, err
+	}
+	return &fakeSQLRows{values: rows}, nil
+}
+
+func (s *fakeSQLStmt) exec(args []driver.Value) ([]string, int64, error) {
+	db := s.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, s.query)
+	q := fakeSQLDollar.ReplaceAllString(s.query, "?")
+	name := fakeSQLTableName.FindStringSubmatch(q)[1]
+	if strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS ") {
+		if _, found := db.tables[name]; !found {
+			db.tables[name] = make(map[string]*fakeSQLRow)
+		}
+		return nil, 0, nil
+	}
+	table, found := db.tables[name]
+	if !found {
+		return nil, 0, errors.New("fakesql: no such table: " + name)
+	}
+	if strings.HasSuffix(name, "_principals") {
+		return execIndex(q, args, table)
+	}
+
+	alive := func(r *fakeSQLRow) bool { return r.expiresAt == 0 || r.expiresAt > args[1].(int64) }
+	switch {
+	case strings.HasPrefix(q, "SELECT data FROM "):
+		if r, found := table[args[0].(string)]; found && alive(r) {
+			return []string{r.data}, 0, nil
+		}
+	case strings.HasPrefix(q, "SELECT 1 FROM "):
+		if r, found := table[args[0].(string)]; found && (len(args) == 1 || alive(r)) {
+			return []string{"1"}, 0, nil
+		}
+	case strings.HasPrefix(q, "SELECT id FROM "), strings.HasPrefix(q, "SELECT TOP "):
+		var ids []string
+		for id, r := range table {
+			if r.expiresAt > 0 && r.expiresAt <= args[0].(int64) {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		var limit int
+		if idx := strings.Index(q, "LIMIT "); idx > 0 {
+			_, _ = fmt.Sscanf(q[idx:], "LIMIT %d", &limit)
+		} else {
+			_, _ = fmt.Sscanf(q, "SELECT TOP %d", &limit)
+		}
+		if len(ids) > limit {
+			ids = ids[:limit]
+		}
+		return ids, 0, nil
+	case strings.HasPrefix(q, "UPDATE "):
+		r, found := table[args[2].(string)]
+		if !found {
+			return nil, 0, nil
+		}
+		if db.changedRowsOnly && r.data == args[0].(string) {
+			return nil, 0, nil
+		}
+		r.data, r.expiresAt = args[0].(string), args[1].(int64)
+		return nil, 1, nil
+	case strings.HasPrefix(q, "INSERT INTO "):
+		if _, found := table[args[0].(string)]; found {
+			return nil, 0, errors.New("fakesql: duplicate key")
+		}
+		table[args[0].(string)] = &fakeSQLRow{data: args[1].(string), expiresAt: args[2].(int64)}
+		return nil, 1, nil
+	case strings.HasPrefix(q, "DELETE FROM "):
+		var n int64
+		for _, arg := range args {
+			if _, found := table[arg.(string)]; found {
+				delete(table, arg.(string))
+				n++
+			}
+		}
+		return nil, n, nil
+	default:
+		return nil, 0, errors.New("fakesql: unsupported query: " + q)
+	}
+	return nil, 0, nil
+}
+
+// execIndex method handles principal index table queries, rows are keyed by
+// `<principal> <id>`.
+func execIndex(q string, args []driver.Value, table map[string]*fakeSQLRow) ([]string, int64, error) {
+	switch {
+	case strings.HasPrefix(q, "SELECT 1 FROM "):
+		if _, found := table[args[0].(string)+" "+args[1].(string)]; found {
+			return []string{"1"}, 0, nil
+		}
+	case strings.HasPrefix(q, "SELECT id FROM "):
+		var ids []string
+		for key, r := range table {
+			if r.data == args[0].(string) {
+				ids = append(ids, key[len(r.data)+1:])
+			}
+		}
+		sort.Strings(ids)
+		return ids, 0, nil
+	case strings.HasPrefix(q, "INSERT INTO "):
+		key := args[0].(string) + " " + args[1].(string)
+		if _, found := table[key]; found {
+			return nil, 0, errors.New("fakesql: duplicate key")
+		}
+		table[key] = &fakeSQLRow{data: args[0].(string)}
+		return nil, 1, nil
+	case strings.Contains(q, " WHERE principal = "):
+		delete(table, args[0].(string)+" "+args[1].(string))
+		return nil, 1, nil
+	case strings.Contains(q, " WHERE id IN "):
+		for key, r := range table {
+			for _, arg := range args {
+				if key[len(r.data)+1:] == arg.(string) {
+					delete(table, key)
+				}
+			}
+		}
+		return nil, 0, nil
+	default:
+		return nil, 0, errors.New("fakesql: unsupported query: " + q)
+	}
+	return nil, 0, nil
+}
+
+type fakeSQLRows struct {
+	values []string
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	return []string{"value"}
+}
+
+func (r *fakeSQLRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"fmt"
 	"strings"
 	"time"
+
+	"aahframe.work/config"
 )
 
 // toBytes method encodes into byte slice.
@@ -31,3 +33,40 @@ func toSeconds(value string) (int64, error) {
 	}
 	return 0, fmt.Errorf("unsupported time unit '%s' on 'session.ttl'", value)
 }
+
+// parseDuration method parses the duration value of given config key.
+func parseDuration(cfg *config.Config, key, defaultValue string) (time.Duration, error) {
+	d, err := time.ParseDuration(cfg.StringDefault(key, defaultValue))
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("session: '%s' value is not a valid duration", key)
+	}
+	return d, nil
+}
+
+// storeTTL method returns the session expiry in the store. Session cookie `ttl`
+// value `0m` is browser session, its store expiry is derived from session
+// `idle_timeout`, otherwise `absolute_timeout`, otherwise store config
+// `browser_session_ttl`, so that stored sessions do not live forever.
+func storeTTL(cfg *config.Config, keyPrefix string) (time.Duration, error) {
+	ttl, err := toSeconds(cfg.StringDefault("security.session.ttl", "0m"))
+	if err != nil {
+		return 0, err
+	}
+	if ttl > 0 {
+		return time.Duration(ttl) * time.Second, nil
+	}
+	for _, key := range []string{"security.session.idle_timeout", "security.session.absolute_timeout"} {
+		d, err := parseDuration(cfg, key, "0s")
+		if err != nil {
+			return 0, err
+		}
+		if d >= time.Second {
+			return d, nil
+		}
+	}
+	d, err := parseDuration(cfg, keyPrefix+"browser_session_ttl", "24h")
+	if err == nil && d < time.Second {
+		err = fmt.Errorf("session: '%sbrowser_session_ttl' value must be atleast 1s", keyPrefix)
+	}
+	return d, err
+}
@@ -0,0 +1,221 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package throttle
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"aahframe.work/cache"
+)
+
+// ErrStoreBusy error is returned when cache store is unable to acquire the
+// lock of attempt within `cacheLockRetries`.
+var ErrStoreBusy = errors.New("security/throttle: store is busy")
+
+// Store interface is used to implement the storage of login failure counters.
+// Method `Get` returns nil, nil if the key does not exist or expired.
+//
+// Method `Incr` records one failure for given key atomically and returns the
+// updated attempt. Counter is reset if the key does not exist or its lockout
+// expired; key gets locked for `ttl` once failures reaches `maxFailures`,
+// `0` never locks. Entry expires after `ttl`.
+type Store interface {
+	Get(key string) (*Attempt, error)
+	Incr(key string, maxFailures int, ttl time.Duration) (*Attempt, error)
+	Delete(key string) error
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// In-memory store
+//___________________________________
+
+var _ Store = (*MemoryStore)(nil)
+
+// MemoryStore struct is the default in-memory store of login throttle.
+// Expired entries are removed on every `sweepEvery` writes.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	writes  int
+}
+
+type memoryEntry struct {
+	attempt   Attempt
+	expiresAt time.Time
+}
+
+const sweepEvery = 256
+
+// NewMemoryStore method creates the in-memory throttle store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+// Get method returns the attempt for given key.
+func (m *MemoryStore) Get(key string) (*Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, found := m.entries[key]
+	if !found || time.Now().After(e.expiresAt) {
+		return nil, nil
+	}
+	a := e.attempt
+	return &a, nil
+}
+
+// Incr method records the failure for given key under single lock.
+func (m *MemoryStore) Incr(key string, maxFailures int, ttl time.Duration) (*Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var a *Attempt
+	if e, found := m.entries[key]; found && !now.After(e.expiresAt) {
+		a = &e.attempt
+	}
+	a = nextAttempt(a, maxFailures, ttl, now)
+	m.entries[key] = memoryEntry{attempt: *a, expiresAt: now.Add(ttl)}
+
+	m.writes++
+	if m.writes%sweepEvery == 0 {
+		for k, e := range m.entries {
+			if now.After(e.expiresAt) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	return a, nil
+}
+
+// Delete method deletes the attempt for given key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	return nil
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Cache store
+//___________________________________
+
+var _ Store = (*CacheStore)(nil)
+
+const (
+	cacheLockTTL     = 5 * time.Second
+	cacheLockRetries = 100
+	cacheLockWait    = 10 * time.Millisecond
+)
+
+// CacheStore struct stores the login throttle counters into aah cache, it is
+// used to share the counters across application instances, i.e. Redis or
+// Memcache.
+type CacheStore struct {
+	c cache.Cache
+}
+
+// NewCacheStore method creates the throttle store for given cache.
+func NewCacheStore(c cache.Cache) (*CacheStore, error) {
+	if c == nil {
+		return nil, ErrStoreIsNil
+	}
+	return &CacheStore{c: c}, nil
+}
+
+// Get method returns the attempt for given key. Entry is absent for a moment
+// while `Incr` replaces it, so on miss it is read again under the lock of key.
+func (s *CacheStore) Get(key string) (*Attempt, error) {
+	if a := s.get(key); a != nil {
+		return a, nil
+	}
+	unlock, err := s.lock(key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return s.get(key), nil
+}
+
+// Incr method records the failure for given key. `cache.Cache` does not
+// provide compare-and-swap, so the update is guarded by lock entry
+// `<key>:lock` acquired via `GetOrPut`, which is atomic across application
+// instances. Lock entry expires after `cacheLockTTL` if the holder dies.
+func (s *CacheStore) Incr(key string, maxFailures int, ttl time.Duration) (*Attempt, error) {
+	unlock, err := s.lock(key)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	a := nextAttempt(s.get(key), maxFailures, ttl, time.Now())
+
+	// cache entry is replaced, since `cache.Cache.Put` does not overwrite
+	// existing entry
+	if err = s.c.Delete(key); err != nil {
+		return nil, err
+	}
+	v := *a
+	if err = s.c.Put(key, &v, ttl); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Delete method deletes the attempt for given key.
+func (s *CacheStore) Delete(key string) error {
+	return s.c.Delete(key)
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+// nextAttempt method returns the attempt with failure recorded at given time.
+func nextAttempt(a *Attempt, maxFailures int, ttl time.Duration, now time.Time) *Attempt {
+	if a == nil || (!a.LockedUntil.IsZero() && now.After(a.LockedUntil)) {
+		a = &Attempt{}
+	}
+	a.Failures++
+	a.LastFailure = now
+	if a.LockedUntil.IsZero() && maxFailures > 0 && a.Failures >= maxFailures {
+		a.LockedUntil = now.Add(ttl)
+	}
+	return a
+}
+
+func (s *CacheStore) get(key string) *Attempt {
+	if a, ok := s.c.Get(key).(*Attempt); ok {
+		v := *a
+		return &v
+	}
+	return nil
+}
+
+func (s *CacheStore) lock(key string) (func(), error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	lockKey, token := key+":lock", hex.EncodeToString(b)
+	for i := 0; i < cacheLockRetries; i++ {
+		v, err := s.c.GetOrPut(lockKey, token, cacheLockTTL)
+		if err != nil {
+			return nil, err
+		}
+		if v == token {
+			return func() {
+				if s.c.Get(lockKey) == token {
+					_ = s.c.Delete(lockKey)
+				}
+			}, nil
+		}
+		time.Sleep(cacheLockWait)
+	}
+	return nil, ErrStoreBusy
+}
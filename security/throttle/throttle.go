@@ -0,0 +1,257 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package throttle provides the login brute-force guard for aah framework
+// auth schemes. Failed login attempts are counted per principal and per client
+// IP, successive attempts are delayed with exponential backoff and temporarily
+// locked out after configured failures.
+//
+//	security {
+//	  login_throttle {
+//	    # Default value is true, if section exists.
+//	    enable = true
+//
+//	    # Failures per principal before lockout, `0` disables it.
+//	    # Default value is 5.
+//	    max_failures = 5
+//
+//	    # Failures per client IP before lockout, `0` disables it.
+//	    # Default value is 20.
+//	    ip_max_failures = 20
+//
+//	    # Lockout duration, failure counters are also reset after this
+//	    # duration of no failures. Default value is `15m`.
+//	    lockout = "15m"
+//
+//	    # Backoff delay after first failure, doubled on every failure
+//	    # upto `max_delay`. Default values are `1s` and `30s`.
+//	    base_delay = "1s"
+//	    max_delay = "30s"
+//
+//	    # Optional, name of the cache from `cache.conf` to store the
+//	    # failure counters. Default is in-memory store.
+//	    cache = "login_throttle"
+//	  }
+//	}
+package throttle
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"aahframe.work/config"
+	"aahframe.work/log"
+)
+
+// ErrStoreIsNil error is returned when given throttle store is nil.
+var ErrStoreIsNil = errors.New("security/throttle: store is nil")
+
+type (
+	// Throttle struct holds the login brute-force guard implementation.
+	Throttle struct {
+		Enabled         bool
+		MaxFailures     int
+		IPMaxFailures   int
+		LockoutDuration time.Duration
+		BaseDelay       time.Duration
+		MaxDelay        time.Duration
+		CacheName       string
+		store           Store
+	}
+
+	// Attempt struct holds the login failure counter of principal or client IP.
+	Attempt struct {
+		Failures    int
+		LastFailure time.Time
+		LockedUntil time.Time
+	}
+
+	// Status struct holds the details of throttled login attempt.
+	Status struct {
+		Key        string
+		Locked     bool
+		RetryAfter time.Duration
+	}
+
+	// Lockout struct holds the details of lockout, it is the event data of
+	// aah event `OnAuthLockout`.
+	Lockout struct {
+		Key       string
+		Principal string
+		IP        string
+		Failures  int
+		Until     time.Time
+	}
+)
+
+// New method creates the login throttle based on security configuration
+// `security.login_throttle { ... }`.
+func New(cfg *config.Config) (*Throttle, error) {
+	keyPrefix := "security.login_throttle"
+	if !cfg.IsExists(keyPrefix) {
+		return &Throttle{Enabled: false}, nil
+	}
+
+	t := &Throttle{
+		Enabled:       cfg.BoolDefault(keyPrefix+".enable", true),
+		MaxFailures:   cfg.IntDefault(keyPrefix+".max_failures", 5),
+		IPMaxFailures: cfg.IntDefault(keyPrefix+".ip_max_failures", 20),
+		CacheName:     cfg.StringDefault(keyPrefix+".cache", ""),
+		store:         NewMemoryStore(),
+	}
+
+	var err error
+	for k, v := range map[string]*time.Duration{
+		"lockout":    &t.LockoutDuration,
+		"base_delay": &t.BaseDelay,
+		"max_delay":  &t.MaxDelay,
+	} {
+		if *v, err = time.ParseDuration(cfg.StringDefault(keyPrefix+"."+k, defaultDurations[k])); err != nil {
+			return nil, fmt.Errorf("security/throttle: '%s.%s' value is invalid: %v", keyPrefix, k, err)
+		}
+	}
+
+	return t, nil
+}
+
+// SetStore method sets the given store for failure counters.
+func (t *Throttle) SetStore(store Store) error {
+	if store == nil {
+		return ErrStoreIsNil
+	}
+	t.store = store
+	return nil
+}
+
+// Check method returns the throttle status if login attempt of given principal
+// or client IP is locked or within backoff delay, otherwise nil.
+// Attempt being recorded by too many concurrent failures, i.e. store is busy,
+// is also throttled.
+func (t *Throttle) Check(principal, ip string) *Status {
+	if !t.Enabled {
+		return nil
+	}
+
+	now := time.Now()
+	var status *Status
+	for _, key := range t.keys(principal, ip) {
+		a, err := t.store.Get(key)
+		if err != nil {
+			log.Errorf("security/throttle: unable to get attempt '%s': %v", key, err)
+		}
+
+		st := &Status{Key: key}
+		if err == ErrStoreBusy {
+			// failures of key are recorded heavily at the moment
+			st.RetryAfter = busyRetryAfter
+		} else if a == nil {
+			continue
+		} else if now.Before(a.LockedUntil) {
+			st.Locked, st.RetryAfter = true, a.LockedUntil.Sub(now)
+		} else if next := a.LastFailure.Add(t.delay(a.Failures)); a.LockedUntil.IsZero() && now.Before(next) {
+			st.RetryAfter = next.Sub(now)
+		} else {
+			continue
+		}
+
+		if status == nil || st.RetryAfter > status.RetryAfter {
+			status = st
+		}
+	}
+	return status
+}
+
+// Fail method records the failed login attempt of given principal and client
+// IP. It returns lockout details if this attempt locks either one of them,
+// otherwise nil.
+func (t *Throttle) Fail(principal, ip string) *Lockout {
+	if !t.Enabled {
+		return nil
+	}
+
+	var lockout *Lockout
+	for _, key := range t.keys(principal, ip) {
+		max := t.maxFailures(key)
+		a, err := t.store.Incr(key, max, t.LockoutDuration)
+		if err != nil {
+			log.Errorf("security/throttle: unable to store attempt '%s': %v", key, err)
+			continue
+		}
+
+		// counter is incremented atomically, so only one concurrent attempt
+		// reaches the max failures
+		if lockout == nil && max > 0 && a.Failures == max {
+			lockout = &Lockout{Key: key, Principal: principal, IP: ip, Failures: a.Failures, Until: a.LockedUntil}
+		}
+	}
+	return lockout
+}
+
+// Succeed method resets the failure counter of given principal on successful
+// login. Client IP counter is not reset, so that the valid account does not
+// unlock the brute-force attempts from same IP.
+func (t *Throttle) Succeed(principal string) {
+	if !t.Enabled || t.MaxFailures <= 0 || len(principal) == 0 {
+		return
+	}
+	if err := t.store.Delete(principalKey(principal)); err != nil {
+		log.Errorf("security/throttle: unable to reset attempt '%s': %v", principalKey(principal), err)
+	}
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+var defaultDurations = map[string]string{"lockout": "15m", "base_delay": "1s", "max_delay": "30s"}
+
+const (
+	keyPrefixPrincipal = "principal:"
+	keyPrefixIP        = "ip:"
+	busyRetryAfter     = time.Second
+)
+
+func principalKey(principal string) string {
+	return keyPrefixPrincipal + strings.ToLower(principal)
+}
+
+func (t *Throttle) keys(principal, ip string) []string {
+	keys := make([]string, 0, 2)
+	if t.MaxFailures > 0 && len(principal) > 0 {
+		keys = append(keys, principalKey(principal))
+	}
+	if t.IPMaxFailures > 0 && len(ip) > 0 {
+		keys = append(keys, keyPrefixIP+ip)
+	}
+	return keys
+}
+
+func (t *Throttle) maxFailures(key string) int {
+	if strings.HasPrefix(key, keyPrefixIP) {
+		return t.IPMaxFailures
+	}
+	return t.MaxFailures
+}
+
+// delay method returns exponential backoff delay for given failures.
+func (t *Throttle) delay(failures int) time.Duration {
+	if failures <= 0 || t.BaseDelay <= 0 {
+		return 0
+	}
+	d := t.BaseDelay
+	for i := 1; i < failures && d < t.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > t.MaxDelay {
+		return t.MaxDelay
+	}
+	return d
+}
+
+func init() {
+	gob.Register(&Attempt{})
+}
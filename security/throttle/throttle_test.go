@@ -0,0 +1,247 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package throttle
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"aahframe.work/cache"
+	"aahframe.work/config"
+	"aahframe.work/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleConfig(t *testing.T) {
+	th, err := New(config.NewEmpty())
+	assert.Nil(t, err)
+	assert.False(t, th.Enabled)
+	assert.Nil(t, th.Check("jeeva", "10.0.0.1"))
+	assert.Nil(t, th.Fail("jeeva", "10.0.0.1"))
+
+	th = createTestThrottle(t, "")
+	assert.True(t, th.Enabled)
+	assert.Equal(t, 5, th.MaxFailures)
+	assert.Equal(t, 20, th.IPMaxFailures)
+	assert.Equal(t, 15*time.Minute, th.LockoutDuration)
+	assert.Equal(t, time.Second, th.BaseDelay)
+	assert.Equal(t, 30*time.Second, th.MaxDelay)
+	assert.Equal(t, "", th.CacheName)
+	assert.Equal(t, ErrStoreIsNil, th.SetStore(nil))
+
+	cfg, _ := config.ParseString(`security { login_throttle { lockout = "15 minutes"; } }`)
+	_, err = New(cfg)
+	assert.Equal(t, errors.New(`security/throttle: 'security.login_throttle.lockout' value is invalid: time: unknown unit " minutes" in duration "15 minutes"`), err)
+}
+
+func TestThrottleBackoffAndLockout(t *testing.T) {
+	th := createTestThrottle(t, `max_failures = 3; base_delay = "40ms"; max_delay = "60ms"; lockout = "200ms";`)
+
+	assert.Nil(t, th.Check("Jeeva", "10.0.0.1"))
+	assert.Nil(t, th.Fail("Jeeva", "10.0.0.1"))
+
+	t.Log("backoff after first failure")
+	st := th.Check("jeeva", "10.0.0.2")
+	assert.NotNil(t, st)
+	assert.Equal(t, "principal:jeeva", st.Key)
+	assert.False(t, st.Locked)
+	assert.True(t, st.RetryAfter > 0 && st.RetryAfter <= 40*time.Millisecond)
+	assert.Nil(t, th.Check("other", "10.0.0.2"))
+
+	time.Sleep(45 * time.Millisecond)
+	assert.Nil(t, th.Check("jeeva", "10.0.0.1"))
+	assert.Nil(t, th.Fail("jeeva", "10.0.0.1"))
+
+	t.Log("backoff is capped at max delay")
+	st = th.Check("jeeva", "10.0.0.1")
+	assert.True(t, st.RetryAfter > 40*time.Millisecond && st.RetryAfter <= 60*time.Millisecond)
+
+	time.Sleep(65 * time.Millisecond)
+	lockout := th.Fail("jeeva", "10.0.0.1")
+	assert.NotNil(t, lockout)
+	assert.Equal(t, "principal:jeeva", lockout.Key)
+	assert.Equal(t, "jeeva", lockout.Principal)
+	assert.Equal(t, "10.0.0.1", lockout.IP)
+	assert.Equal(t, 3, lockout.Failures)
+
+	st = th.Check("jeeva", "")
+	assert.True(t, st.Locked)
+	assert.True(t, st.RetryAfter > 60*time.Millisecond)
+
+	t.Log("successful login resets principal counter")
+	th.Succeed("JEEVA")
+	assert.Nil(t, th.Check("jeeva", ""))
+
+	t.Log("lockout expires")
+	for i := 0; i < 3; i++ {
+		lockout = th.Fail("jeeva", "")
+	}
+	assert.NotNil(t, lockout)
+	time.Sleep(210 * time.Millisecond)
+	assert.Nil(t, th.Check("jeeva", ""))
+	assert.Nil(t, th.Fail("jeeva", ""))
+}
+
+func TestThrottleClientIP(t *testing.T) {
+	th := createTestThrottle(t, `max_failures = 0; ip_max_failures = 2; base_delay = "0s";`)
+
+	assert.Nil(t, th.Fail("user1", "10.0.0.1"))
+	assert.Nil(t, th.Check("user2", "10.0.0.1"), "no backoff delay")
+
+	lockout := th.Fail("user2", "10.0.0.1")
+	assert.NotNil(t, lockout)
+	assert.Equal(t, "ip:10.0.0.1", lockout.Key)
+
+	st := th.Check("user3", "10.0.0.1")
+	assert.True(t, st.Locked)
+	assert.Nil(t, th.Check("user3", "10.0.0.2"))
+
+	t.Log("successful login does not reset client IP counter")
+	th.Succeed("user3")
+	assert.NotNil(t, th.Check("user3", "10.0.0.1"))
+}
+
+func TestThrottleCacheStore(t *testing.T) {
+	_, err := NewCacheStore(nil)
+	assert.Equal(t, ErrStoreIsNil, err)
+
+	mgr := createTestCacheManager(t)
+	store, err := NewCacheStore(mgr.Cache("login_throttle"))
+	assert.Nil(t, err)
+
+	th := createTestThrottle(t, `max_failures = 2; cache = "login_throttle";`)
+	assert.Equal(t, "login_throttle", th.CacheName)
+	assert.Nil(t, th.SetStore(store))
+
+	assert.Nil(t, th.Fail("jeeva", "10.0.0.1"))
+	assert.NotNil(t, th.Fail("jeeva", "10.0.0.1"), "existing cache entry is replaced")
+	a, err := store.Get("principal:jeeva")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, a.Failures)
+	assert.True(t, th.Check("jeeva", "").Locked)
+
+	th.Succeed("jeeva")
+	a, err = store.Get("principal:jeeva")
+	assert.Nil(t, err)
+	assert.Nil(t, a)
+	assert.Nil(t, mgr.Close())
+}
+
+func TestThrottleMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	for i := 0; i < sweepEvery; i++ {
+		_, err := store.Incr("expired", 0, -time.Second)
+		assert.Nil(t, err)
+	}
+	a, err := store.Get("expired")
+	assert.Nil(t, err)
+	assert.Nil(t, a)
+	assert.Equal(t, 0, len(store.entries), "expired entries are removed")
+
+	a, err = store.Incr("key1", 2, time.Minute)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, a.Failures)
+	assert.True(t, a.LockedUntil.IsZero())
+	a.Failures = 10
+	a, _ = store.Get("key1")
+	assert.Equal(t, 1, a.Failures, "store returns copy")
+
+	a, _ = store.Incr("key1", 2, time.Minute)
+	assert.Equal(t, 2, a.Failures)
+	assert.False(t, a.LockedUntil.IsZero())
+}
+
+func TestThrottleConcurrentFailures(t *testing.T) {
+	mgr := createTestCacheManager(t)
+	defer func() { assert.Nil(t, mgr.Close()) }()
+	cacheStore, err := NewCacheStore(mgr.Cache("login_throttle"))
+	assert.Nil(t, err)
+
+	for _, store := range []Store{NewMemoryStore(), cacheStore} {
+		th := createTestThrottle(t, `max_failures = 5; ip_max_failures = 0;`)
+		assert.Nil(t, th.SetStore(store))
+
+		var (
+			wg       sync.WaitGroup
+			lockouts int32
+		)
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if th.Fail("jeeva", "10.0.0.1") != nil {
+					atomic.AddInt32(&lockouts, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(1), lockouts, "lockout fires exactly once")
+		a, err := store.Get("principal:jeeva")
+		assert.Nil(t, err)
+		assert.Equal(t, 20, a.Failures, "no failure is lost")
+		assert.True(t, th.Check("jeeva", "").Locked)
+	}
+}
+
+func TestThrottleCacheStoreConcurrentCheck(t *testing.T) {
+	mgr := createTestCacheManager(t)
+	defer func() { assert.Nil(t, mgr.Close()) }()
+	store, err := NewCacheStore(mgr.Cache("login_throttle"))
+	assert.Nil(t, err)
+
+	th := createTestThrottle(t, `max_failures = 1000; ip_max_failures = 0; base_delay = "1m";`)
+	assert.Nil(t, th.SetStore(store))
+	assert.Nil(t, th.Fail("jeeva", ""))
+
+	t.Log("check never sees the entry absent while it is replaced")
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				th.Fail("jeeva", "")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.NotNil(t, th.Check("jeeva", ""))
+			}
+		}()
+	}
+	wg.Wait()
+
+	t.Log("busy store is throttled")
+	assert.Nil(t, mgr.Cache("login_throttle").Put("principal:other:lock", "token", time.Minute))
+	_, err = store.Get("principal:other")
+	assert.Equal(t, ErrStoreBusy, err)
+	st := th.Check("other", "")
+	assert.False(t, st.Locked)
+	assert.Equal(t, busyRetryAfter, st.RetryAfter)
+}
+
+func createTestThrottle(t *testing.T, extra string) *Throttle {
+	cfg, err := config.ParseString("security {\n login_throttle {\n" + extra + "\n}\n}")
+	assert.Nil(t, err)
+	th, err := New(cfg)
+	assert.Nil(t, err)
+	return th
+}
+
+func createTestCacheManager(t *testing.T) *cache.Manager {
+	mgr := cache.NewManager()
+	assert.Nil(t, mgr.AddProvider("inmemory", new(cache.InMemoryProvider)))
+	l, _ := log.New(config.NewEmpty())
+	l.SetWriter(ioutil.Discard)
+	assert.Nil(t, mgr.InitProviders(config.NewEmpty(), l))
+	assert.Nil(t, mgr.CreateCache(&cache.Config{Name: "login_throttle", ProviderName: "inmemory"}))
+	return mgr
+}
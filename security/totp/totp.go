@@ -0,0 +1,205 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+// Package totp provides the time-based one-time password (RFC 6238) for
+// two-factor authentication, secret and recovery code generation and
+// provisioning URI for authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TOTP errors
+var (
+	ErrInvalidSecret = errors.New("security/totp: invalid secret")
+	ErrInvalidCode   = errors.New("security/totp: invalid code")
+)
+
+const (
+	// SecretSize is default secret size in bytes, recommended by RFC 4226.
+	SecretSize = 20
+
+	recoveryCodeChars = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTP struct holds the time-based one-time password parameters. Algorithm is
+// HMAC-SHA1, which is supported by widely used authenticator apps.
+type TOTP struct {
+	// Issuer is the name of application or organization shown in the
+	// authenticator app.
+	Issuer string
+
+	// Digits is the length of code, valid values are 6 to 8. Default is 6.
+	Digits int
+
+	// Period is the time step of code. Default is 30 seconds.
+	Period time.Duration
+
+	// Skew is the number of time steps accepted before and after the current
+	// time step, to allow clock drift. Default is 0.
+	Skew int
+}
+
+// New method creates TOTP with default values for given issuer.
+func New(issuer string) *TOTP {
+	return &TOTP{Issuer: issuer, Digits: 6, Period: 30 * time.Second}
+}
+
+// Code method returns the one-time password of given secret for the time.
+func (t *TOTP) Code(secret string, at time.Time) (string, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	return t.code(key, t.step(at)), nil
+}
+
+// Validate method validates given code with secret for the time. It returns
+// the matched time step on success, which can be used to prevent the reuse of
+// code within its validity.
+func (t *TOTP) Validate(secret, code string, at time.Time) (int64, error) {
+	key, err := decodeSecret(secret)
+	if err != nil {
+		return 0, err
+	}
+
+	code = strings.Replace(strings.TrimSpace(code), " ", "", -1)
+	if len(code) != t.digits() {
+		return 0, ErrInvalidCode
+	}
+
+	step := t.step(at)
+	for i := -t.Skew; i <= t.Skew; i++ {
+		if subtle.ConstantTimeCompare([]byte(t.code(key, step+int64(i))), []byte(code)) == 1 {
+			return step + int64(i), nil
+		}
+	}
+	return 0, ErrInvalidCode
+}
+
+// ProvisioningURI method returns the `otpauth://` URI for given secret and
+// account name, typically rendered as QR code for the authenticator apps.
+func (t *TOTP) ProvisioningURI(secret, account string) string {
+	label := url.PathEscape(account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	if len(t.Issuer) > 0 {
+		label = url.PathEscape(t.Issuer) + ":" + label
+		params.Set("issuer", t.Issuer)
+	}
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", strconv.Itoa(t.digits()))
+	params.Set("period", strconv.Itoa(int(t.period().Seconds())))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// GenerateSecret method generates the random secret of `SecretSize` bytes and
+// returns it in base32 encoding without padding.
+func GenerateSecret() (string, error) {
+	key := make([]byte, SecretSize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(key), nil
+}
+
+// GenerateRecoveryCodes method generates `n` random recovery codes in the
+// format of `xxxxx-xxxxx`. Recovery codes are one-time use, store them as
+// hash (i.e. using password encoder) and remove it once used.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	// random bytes beyond the multiple of alphabet size are skipped to avoid
+	// modulo bias
+	limit := byte(256 - 256%len(recoveryCodeChars))
+	codes := make([]string, 0, n)
+	b := make([]byte, 1)
+	for i := 0; i < n; i++ {
+		code := make([]byte, 0, 10)
+		for len(code) < 10 {
+			if _, err := io.ReadFull(rand.Reader, b); err != nil {
+				return nil, err
+			}
+			if b[0] < limit {
+				code = append(code, recoveryCodeChars[int(b[0])%len(recoveryCodeChars)])
+			}
+		}
+		codes = append(codes, string(code[:5])+"-"+string(code[5:]))
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode method returns the recovery code in generated format,
+// i.e. lower case with separator, so user input can be compared with stored
+// value.
+func NormalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(code)))
+	if len(code) != 10 {
+		return code
+	}
+	return code[:5] + "-" + code[5:]
+}
+
+//‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
+// Unexported methods
+//___________________________________
+
+func (t *TOTP) digits() int {
+	if t.Digits <= 0 {
+		return 6
+	}
+	return t.Digits
+}
+
+func (t *TOTP) period() time.Duration {
+	if t.Period <= 0 {
+		return 30 * time.Second
+	}
+	return t.Period
+}
+
+func (t *TOTP) step(at time.Time) int64 {
+	return at.Unix() / int64(t.period().Seconds())
+}
+
+// code method computes HOTP value (RFC 4226) for given counter.
+func (t *TOTP) code(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	digits := t.digits()
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, value%mod)
+}
+
+func decodeSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.Replace(strings.TrimSpace(secret), " ", "", -1))
+	key, err := b32.DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, ErrInvalidSecret
+	}
+	return key, nil
+}
@@ -0,0 +1,111 @@
+// Copyright (c) Jeevanandam M. (https://github.com/jeevatkm)
+// Source code and usage is governed by a MIT style
+// license that can be found in the LICENSE file.
+
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	otp := &TOTP{Digits: 8, Period: 30 * time.Second}
+
+	testcases := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tc := range testcases {
+		code, err := otp.Code(secret, time.Unix(tc.unix, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.code, code)
+	}
+
+	_, err := otp.Code("invalid secret!", time.Now())
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestTOTPValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.Nil(t, err)
+	assert.Equal(t, 32, len(secret))
+
+	otp := New("aah")
+	now := time.Unix(1500000000, 0)
+	code, err := otp.Code(secret, now)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(code))
+
+	step, err := otp.Validate(secret, code, now)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50000000), step)
+
+	step, err = otp.Validate(strings.ToLower(secret), code[:3]+" "+code[3:], now)
+	assert.Nil(t, err, "secret is case insensitive and code spaces are ignored")
+	assert.Equal(t, int64(50000000), step)
+
+	t.Log("clock skew")
+	_, err = otp.Validate(secret, code, now.Add(30*time.Second))
+	assert.Equal(t, ErrInvalidCode, err)
+	otp.Skew = 1
+	step, err = otp.Validate(secret, code, now.Add(30*time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(50000000), step)
+	_, err = otp.Validate(secret, code, now.Add(60*time.Second))
+	assert.Equal(t, ErrInvalidCode, err)
+
+	t.Log("invalid inputs")
+	_, err = otp.Validate(secret, "12345", now)
+	assert.Equal(t, ErrInvalidCode, err)
+	_, err = otp.Validate("", code, now)
+	assert.Equal(t, ErrInvalidSecret, err)
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	otp := New("aah Corp")
+	u, err := url.Parse(otp.ProvisioningURI("JBSWY3DPEHPK3PXP", "jeeva@example.com"))
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", u.Scheme)
+	assert.Equal(t, "totp", u.Host)
+	assert.Equal(t, "/aah Corp:jeeva@example.com", u.Path)
+	assert.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	assert.Equal(t, "aah Corp", u.Query().Get("issuer"))
+	assert.Equal(t, "SHA1", u.Query().Get("algorithm"))
+	assert.Equal(t, "6", u.Query().Get("digits"))
+	assert.Equal(t, "30", u.Query().Get("period"))
+
+	otp = &TOTP{}
+	assert.Equal(t, "otpauth://totp/jeeva?algorithm=SHA1&digits=6&period=30&secret=JBSWY3DPEHPK3PXP",
+		otp.ProvisioningURI("JBSWY3DPEHPK3PXP", "jeeva"))
+}
+
+func TestTOTPRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(codes))
+
+	unique := make(map[string]bool)
+	for _, code := range codes {
+		assert.Equal(t, 11, len(code))
+		assert.Equal(t, byte('-'), code[5])
+		assert.Equal(t, code, NormalizeRecoveryCode(strings.ToUpper(strings.Replace(code, "-", " ", 1))))
+		unique[code] = true
+	}
+	assert.Equal(t, 10, len(unique))
+
+	assert.Equal(t, "abc", NormalizeRecoveryCode(" ABC "))
+}
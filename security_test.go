@@ -5,8 +5,12 @@
 package aah
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/cookiejar"
 	"net/http/httptest"
@@ -25,6 +29,8 @@ import (
 	"aahframe.work/security/authz"
 	"aahframe.work/security/scheme"
 	"aahframe.work/security/session"
+	"aahframe.work/security/throttle"
+	"aahframe.work/security/totp"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/net/publicsuffix"
 	"golang.org/x/oauth2"
@@ -41,6 +47,84 @@ func TestSecuritySessionStore(t *testing.T) {
 	assert.Equal(t, errors.New("security/session: store value is nil"), err)
 }
 
+func TestSecuritySessionAuthenticationInfo(t *testing.T) {
+	s := &session.Session{Values: make(map[string]interface{})}
+	assert.Nil(t, sessionAuthenticationInfo(s))
+
+	authcInfo := testGetAuthenticationInfo()
+	s.Set(KeyViewArgAuthcInfo, authcInfo)
+	assert.Equal(t, authcInfo, sessionAuthenticationInfo(s))
+
+	// session decoded by json codec
+	var m map[string]interface{}
+	b, _ := json.Marshal(authcInfo)
+	assert.Nil(t, json.Unmarshal(b, &m))
+	s.Set(KeyViewArgAuthcInfo, m)
+	result := sessionAuthenticationInfo(s)
+	assert.NotNil(t, result)
+	assert.Equal(t, "jeeva", result.PrimaryPrincipal().Value)
+	assert.Equal(t, "database", result.PrimaryPrincipal().Realm)
+
+	s.Set(KeyViewArgAuthcInfo, map[string]interface{}{"Principals": "invalid"})
+	assert.Nil(t, sessionAuthenticationInfo(s))
+}
+
+func TestSecuritySessionTooLarge(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security Session Too Large]: %s", ts.URL)
+
+	cfg, _ := config.ParseString(`security { session { mode = "stateful"; } }`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+
+	ctx := ts.app.he.newContext()
+	ctx.Req = ahttp.AcquireRequest(httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	ctx.Res = ahttp.AcquireResponseWriter(httptest.NewRecorder())
+	ctx.Reply().Redirect("/login")
+	ctx.Session().Set("large", strings.Repeat("large value ", 500))
+	ctx.writeCookies()
+
+	assert.False(t, ctx.Reply().redirect)
+	assert.Equal(t, http.StatusInternalServerError, ctx.Reply().Code)
+	assert.Equal(t, ErrSessionTooLarge, ctx.Reply().err.Reason)
+	assert.Equal(t, "", ctx.Res.Header().Get(ahttp.HeaderSetCookie))
+}
+
+func TestSecuritySessionHeaderTransport(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security Session Header Transport]: %s", ts.URL)
+
+	cfg, _ := config.ParseString(`security { session { mode = "stateful"; } }`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+
+	ctx := ts.app.he.newContext()
+	ctx.Req = ahttp.AcquireRequest(httptest.NewRequest("GET", "http://localhost:8080/", nil))
+	ctx.Res = ahttp.AcquireResponseWriter(httptest.NewRecorder())
+	assert.Equal(t, "", ctx.sessionHeader())
+
+	ctx.domain = &router.Domain{SessionHeader: "X-Session-Token"}
+	assert.Equal(t, "X-Session-Token", ctx.sessionHeader())
+	ctx.Session().Set("my-key", "my value")
+	ctx.writeCookies()
+	assert.Equal(t, "", ctx.Res.Header().Get(ahttp.HeaderSetCookie))
+	token := ctx.Res.Header().Get("X-Session-Token")
+	assert.NotEqual(t, "", token)
+
+	r := httptest.NewRequest("GET", "http://localhost:8080/", nil)
+	r.Header.Set("X-Session-Token", token)
+	s := ts.app.SessionManager().GetSessionFromHeader(r, ctx.sessionHeader())
+	assert.NotNil(t, s)
+	assert.Equal(t, ctx.Session().ID, s.ID)
+	assert.Equal(t, "my value", s.GetString("my-key"))
+}
+
 func TestSecuritySessionTemplateFuns(t *testing.T) {
 	importPath := filepath.Join(testdataBaseDir(), "webapp1")
 	ts := newTestServer(t, importPath)
@@ -196,7 +280,188 @@ func TestSecurityHandleFormAuthcAndAuthz(t *testing.T) {
 	r3 := httptest.NewRequest("POST", "http://localhost:8080/login", strings.NewReader("username=jeeva&password=welcome123"))
 	r3.Header.Set(ahttp.HeaderContentType, "application/x-www-form-urlencoded")
 	ctx.Req = ahttp.AcquireRequest(r3)
+	sid := ctx.Session().ID
+	var regenerated *session.Event
+	ts.app.OnSessionRegenerated(func(e *Event) { regenerated = e.Data.(*session.Event) })
+	AuthcAuthzMiddleware(ctx, &Middleware{})
+	assert.True(t, ctx.Session().IsAuthenticated)
+	assert.NotEqual(t, sid, ctx.Session().ID, "session id regenerated on login")
+	assert.NotNil(t, regenerated)
+	assert.Equal(t, sid, regenerated.OldID)
+	assert.Equal(t, ctx.Session().ID, regenerated.ID)
+}
+
+func TestSecuritySessionRegenerateIDFailure(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security Session Regenerate ID Failure]: %s", ts.URL)
+
+	_ = session.AddStore("failing", &testFailingSessionStore{})
+	cfg, _ := config.ParseString(`
+		security {
+		  session {
+		    mode = "stateful"
+		    store {
+		      type = "failing"
+		    }
+		  }
+		  auth_schemes {
+		    form_auth {
+		      scheme = "form"
+		      authenticator = "security/Authentication"
+		      authorizer = "security/Authorization"
+		    }
+		  }
+		}
+	`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+
+	formAuth := ts.app.SecurityManager().AuthScheme("form_auth").(*scheme.FormAuth)
+	assert.Nil(t, formAuth.SetAuthenticator(&testFormAuthentication{}))
+	assert.Nil(t, formAuth.SetAuthorizer(&testFormAuthentication{}))
+
+	r := httptest.NewRequest("POST", "http://localhost:8080/login", strings.NewReader("username=jeeva&password=welcome123"))
+	r.Header.Set(ahttp.HeaderContentType, "application/x-www-form-urlencoded")
+	ctx := ts.app.he.newContext()
+	ctx.Req = ahttp.AcquireRequest(r)
+	ctx.Res = ahttp.AcquireResponseWriter(httptest.NewRecorder())
+	ctx.route = &router.Route{Path: ctx.Req.Path, Auth: "form_auth"}
+	ctx.reply = newReply(ctx)
+	sid := ctx.Session().ID
 	AuthcAuthzMiddleware(ctx, &Middleware{})
+
+	assert.False(t, ctx.Subject().IsAuthenticated(), "login does not continue on old session id")
+	assert.Equal(t, sid, ctx.Session().ID)
+	assert.False(t, ctx.Session().IsKeyExists(KeyViewArgAuthcInfo))
+	assert.Equal(t, http.StatusInternalServerError, ctx.Reply().Code)
+	assert.Equal(t, ErrSessionRegenerateID, ctx.Reply().err.Reason)
+	assert.Equal(t, "", ctx.Reply().path, "no redirect to target URL")
+}
+
+type testFailingSessionStore struct{}
+
+func (s *testFailingSessionStore) Init(appCfg *config.Config) error { return nil }
+func (s *testFailingSessionStore) Read(id string) string            { return "" }
+func (s *testFailingSessionStore) Save(id, value string) error {
+	return errors.New("session store is unavailable")
+}
+func (s *testFailingSessionStore) Delete(id string) error     { return nil }
+func (s *testFailingSessionStore) IsExists(id string) bool    { return false }
+func (s *testFailingSessionStore) Cleanup(m *session.Manager) {}
+
+type testTwoFactorProvider struct {
+	secret string
+}
+
+var _ scheme.TwoFactorProvider = (*testTwoFactorProvider)(nil)
+
+func (tp *testTwoFactorProvider) Init(cfg *config.Config) error { return nil }
+func (tp *testTwoFactorProvider) TOTPSecret(authcInfo *authc.AuthenticationInfo) (string, error) {
+	return tp.secret, nil
+}
+func (tp *testTwoFactorProvider) UseRecoveryCode(authcInfo *authc.AuthenticationInfo, code string) (bool, error) {
+	return code == "abcde-fghjk", nil
+}
+
+func TestSecurityFormAuthTwoFactor(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security Form Auth Two-Factor]: %s", ts.URL)
+
+	cfg, _ := config.ParseString(`
+		security {
+		  auth_schemes {
+		    form_auth {
+		      scheme = "form"
+		      authenticator = "security/Authentication"
+		      authorizer = "security/Authorization"
+		      two_factor {
+		        enable = true
+		        max_attempts = 2
+		      }
+		    }
+		  }
+		}
+	`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+
+	secret, err := totp.GenerateSecret()
+	assert.Nil(t, err)
+	formAuth := ts.app.SecurityManager().AuthScheme("form_auth").(*scheme.FormAuth)
+	assert.Nil(t, formAuth.SetAuthenticator(&testFormAuthentication{}))
+	assert.Nil(t, formAuth.SetAuthorizer(&testFormAuthentication{}))
+	assert.Nil(t, formAuth.SetTwoFactorProvider(&testTwoFactorProvider{secret: secret}))
+
+	ctx := ts.app.he.newContext()
+	ctx.Res = ahttp.AcquireResponseWriter(httptest.NewRecorder())
+	request := func(method, path, body string) {
+		r := httptest.NewRequest(method, "http://localhost:8080"+path, strings.NewReader(body))
+		r.Header.Set(ahttp.HeaderContentType, "application/x-www-form-urlencoded")
+		ctx.Req = ahttp.AcquireRequest(r)
+		ctx.route = &router.Route{Path: ctx.Req.Path, Auth: "form_auth"}
+		ctx.reply = newReply(ctx)
+		AuthcAuthzMiddleware(ctx, &Middleware{})
+	}
+
+	t.Log("password verified, subject is partially authenticated")
+	request("POST", "/login", "username=jeeva&password=welcome123&_rt=/dashboard")
+	assert.False(t, ctx.Subject().IsAuthenticated())
+	assert.Equal(t, "/login/otp.html?_rt=%2Fdashboard", ctx.Reply().path)
+	assert.Equal(t, "jeeva", ctx.Session().GetString(keyTwoFactorIdentity))
+
+	t.Log("protected page sends to OTP page")
+	request("GET", "/dashboard", "")
+	assert.False(t, ctx.Subject().IsAuthenticated())
+	assert.Equal(t, "/login/otp.html?_rt=http%3A%2F%2Flocalhost%3A8080%2Fdashboard", ctx.Reply().path)
+
+	t.Log("invalid code")
+	request("POST", "/login/otp", "otp=000000&_rt=/dashboard")
+	assert.False(t, ctx.Subject().IsAuthenticated())
+	assert.Equal(t, "/login/otp.html?error=true&_rt=%2Fdashboard", ctx.Reply().path)
+	assert.Equal(t, 1, ctx.Session().GetInt(keyTwoFactorAttempts))
+
+	t.Log("valid code")
+	code, err := formAuth.TOTP.Code(secret, time.Now())
+	assert.Nil(t, err)
+	request("POST", "/login/otp", "otp="+code+"&_rt=/dashboard")
+	assert.True(t, ctx.Subject().IsAuthenticated())
+	assert.Equal(t, "jeeva", ctx.Subject().PrimaryPrincipal().Value)
+	assert.Equal(t, "/dashboard", ctx.Reply().path)
+	assert.False(t, ctx.Session().IsKeyExists(keyTwoFactorAuthcInfo))
+	assert.False(t, ctx.Session().IsKeyExists(keyTwoFactorAttempts))
+
+	t.Log("max attempts exceeded, login again with password")
+	ctx.Subject().Session = nil
+	request("POST", "/login", "username=jeeva&password=welcome123")
+	request("POST", "/login/otp", "otp=000000")
+	request("POST", "/login/otp", "otp=abcde")
+	assert.Equal(t, "/login.html?error=true&_rt=", ctx.Reply().path)
+	assert.False(t, ctx.Session().IsKeyExists(keyTwoFactorAuthcInfo))
+	request("POST", "/login/otp", "otp=abcde-fghjk")
+	assert.False(t, ctx.Subject().IsAuthenticated())
+
+	t.Log("recovery code")
+	request("POST", "/login", "username=jeeva&password=welcome123")
+	request("POST", "/login/otp", "otp=ABCDE-FGHJK")
+	assert.True(t, ctx.Subject().IsAuthenticated())
+	assert.Equal(t, "/", ctx.Reply().path)
+
+	t.Log("subject is not enrolled into two-factor")
+	ctx.Subject().Session = nil
+	assert.Nil(t, formAuth.SetTwoFactorProvider(&testTwoFactorProvider{}))
+	request("POST", "/login", "username=jeeva&password=welcome123")
+	assert.True(t, ctx.Subject().IsAuthenticated())
+
+	t.Log("two-factor cache not exists")
+	assert.Nil(t, ts.app.initTwoFactorStepStore(), "cache is not configured")
+	formAuth.TwoFactorCacheName = "totp_steps"
+	assert.Equal(t, errors.New("security: two-factor cache 'totp_steps' not exists"), ts.app.initTwoFactorStepStore())
 }
 
 //‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾‾
@@ -393,7 +658,138 @@ func TestSecurityHandleBasicAuthcAndAuthz(t *testing.T) {
 	assert.Nil(t, err)
 	r3.SetBasicAuth("jeeva", "welcome123")
 	ctx1.Req = ahttp.AcquireRequest(r3)
+	sid := ctx1.Session().ID
 	AuthcAuthzMiddleware(ctx1, &Middleware{})
+	assert.True(t, ctx1.Session().IsAuthenticated)
+	assert.NotEqual(t, sid, ctx1.Session().ID, "session id regenerated on login")
+}
+
+func TestSecurityLoginThrottle(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security Login Throttle]: %s", ts.URL)
+
+	cfg, _ := config.ParseString(`
+		security {
+		  auth_schemes {
+		    basic_auth {
+		      scheme = "basic"
+		      authenticator = "security/Authentication"
+		      authorizer = "security/Authorization"
+		    }
+		  }
+		  login_throttle {
+		    max_failures = 2
+		    base_delay = "0s"
+		  }
+		}
+	`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+	assert.Nil(t, ts.app.initLoginThrottleStore())
+
+	basicAuth := ts.app.SecurityManager().AuthScheme("basic_auth").(*scheme.BasicAuth)
+	assert.Nil(t, basicAuth.SetAuthenticator(&testBasicAuth{}))
+	assert.Nil(t, basicAuth.SetAuthorizer(&testBasicAuth{}))
+
+	var lockout *throttle.Lockout
+	ts.app.OnAuthLockout(func(e *Event) { lockout = e.Data.(*throttle.Lockout) })
+
+	login := func(password string) (*Context, *httptest.ResponseRecorder) {
+		r, err := http.NewRequest(ahttp.MethodGet, "http://localhost:8080/doc/v0.3/mydoc.html", nil)
+		assert.Nil(t, err)
+		r.SetBasicAuth("jeeva", password)
+		w := httptest.NewRecorder()
+		ctx := ts.app.he.newContext()
+		ctx.Req = ahttp.AcquireRequest(r)
+		ctx.Res = ahttp.AcquireResponseWriter(w)
+		ctx.route = &router.Route{Auth: "basic_auth"}
+		AuthcAuthzMiddleware(ctx, &Middleware{})
+		return ctx, w
+	}
+
+	ctx, _ := login("welcome")
+	assert.Equal(t, http.StatusUnauthorized, ctx.Reply().Code)
+	assert.Nil(t, lockout)
+
+	t.Log("login attempts locked out")
+	ctx, _ = login("welcome")
+	assert.Equal(t, http.StatusUnauthorized, ctx.Reply().Code)
+	assert.NotNil(t, lockout)
+	assert.Equal(t, "principal:jeeva", lockout.Key)
+	assert.Equal(t, 2, lockout.Failures)
+
+	t.Log("valid credentials are rejected while locked")
+	ctx, w := login("welcome123")
+	assert.False(t, ctx.Session().IsAuthenticated)
+	assert.Equal(t, http.StatusTooManyRequests, ctx.Reply().Code)
+	assert.Equal(t, ErrAuthThrottled, ctx.Reply().err.Reason)
+	assert.Equal(t, "900", w.Header().Get(ahttp.HeaderRetryAfter))
+	reasons := ctx.Reply().err.Data.([]*authz.Reason)
+	assert.Equal(t, "loginthrottle", reasons[0].Func)
+	assert.Equal(t, "retry-after=900s", reasons[0].Expected)
+	assert.Equal(t, "locked", reasons[0].Got)
+
+	t.Log("login throttle cache not exists")
+	ts.app.SecurityManager().LoginThrottle.CacheName = "login_throttle"
+	assert.Equal(t, errors.New("security: login throttle cache 'login_throttle' not exists"), ts.app.initLoginThrottleStore())
+}
+
+func TestSecurityHandleJWTAuth(t *testing.T) {
+	importPath := filepath.Join(testdataBaseDir(), "webapp1")
+	ts := newTestServer(t, importPath)
+	defer ts.Close()
+
+	t.Logf("Test Server URL [Security JWT Auth]: %s", ts.URL)
+
+	cfg, _ := config.ParseString(`
+		security {
+		  auth_schemes {
+		    jwt_auth {
+		      scheme = "jwt"
+		      key {
+		        hmac = "eFWLXEewECptbDVXExokRTLONWxrTjfV"
+		      }
+		      claims {
+		        roles = "roles"
+		      }
+		    }
+		  }
+		}
+	`)
+	assert.Nil(t, ts.app.Config().Merge(cfg))
+	assert.Nil(t, ts.app.initSecurity())
+
+	t.Log("bearer token is not supplied")
+	r1, err := http.NewRequest(ahttp.MethodGet, "http://localhost:8080/doc/v0.3/mydoc.html", nil)
+	assert.Nil(t, err)
+	w1 := httptest.NewRecorder()
+	ctx1 := ts.app.he.newContext()
+	ctx1.Req = ahttp.AcquireRequest(r1)
+	ctx1.Res = ahttp.AcquireResponseWriter(w1)
+	ctx1.route = &router.Route{Auth: "jwt_auth"}
+	AuthcAuthzMiddleware(ctx1, &Middleware{})
+	assert.Equal(t, http.StatusUnauthorized, ctx1.Reply().Code)
+	assert.Equal(t, `Bearer realm="Authentication Required"`, w1.Header().Get(ahttp.HeaderWWWAuthenticate))
+
+	t.Log("valid bearer token")
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"jeeva","roles":["admin"],"exp":%d}`, time.Now().Add(time.Hour).Unix())))
+	mac := hmac.New(sha256.New, []byte("eFWLXEewECptbDVXExokRTLONWxrTjfV"))
+	_, _ = mac.Write([]byte(payload))
+	r2, err := http.NewRequest(ahttp.MethodGet, "http://localhost:8080/doc/v0.3/mydoc.html", nil)
+	assert.Nil(t, err)
+	r2.Header.Set(ahttp.HeaderAuthorization, "Bearer "+payload+"."+base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+	ctx2 := ts.app.he.newContext()
+	ctx2.Req = ahttp.AcquireRequest(r2)
+	ctx2.Res = ahttp.AcquireResponseWriter(httptest.NewRecorder())
+	ctx2.route = &router.Route{Auth: "jwt_auth"}
+	AuthcAuthzMiddleware(ctx2, &Middleware{})
+	assert.True(t, ctx2.Subject().IsAuthenticated())
+	assert.Equal(t, "jeeva", ctx2.Subject().PrimaryPrincipal().Value)
+	assert.True(t, ctx2.Subject().HasRole("admin"))
 }
 
 func TestSecurityAntiCSRF(t *testing.T) {
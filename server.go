@@ -136,6 +136,7 @@ func (a *Application) Start() {
 //
 // Method performs:
 //    - Graceful server shutdown with timeout by `server.timeout.grace_shutdown`
+//    - Closes the cache providers, see `cache.Manager.Close`
 //    - Publishes `OnPostShutdown` event
 //    - Exits program with code 0
 func (a *Application) Shutdown() {
@@ -152,6 +153,10 @@ func (a *Application) Shutdown() {
 	a.shutdownRedirectServer()
 	a.Log().Info("aah go server shutdown successfully")
 
+	if err := a.CacheManager().Close(); err != nil {
+		a.Log().Error(err)
+	}
+
 	// Publish `OnPostShutdown` event
 	a.EventStore().sortAndPublishSync(&Event{Name: EventOnPostShutdown})
 }